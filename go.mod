@@ -2,12 +2,12 @@ module github.com/romanzipp/linke-calendar
 
 go 1.25.4
 
+require github.com/mattn/go-sqlite3 v1.14.32
+
 require (
 	github.com/PuerkitoBio/goquery v1.11.0 // indirect
 	github.com/andybalholm/cascadia v1.3.3 // indirect
-	github.com/arran4/golang-ical v0.3.2 // indirect
 	github.com/go-chi/chi/v5 v5.2.3 // indirect
-	github.com/mattn/go-sqlite3 v1.14.32 // indirect
 	github.com/robfig/cron/v3 v3.0.1 // indirect
 	golang.org/x/net v0.47.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
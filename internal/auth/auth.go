@@ -0,0 +1,201 @@
+// Package auth hashes passwords and generates the secret tokens of admin
+// sessions, invites and API tokens.
+package auth
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/romanzipp/linke-calendar/internal/database"
+)
+
+const (
+	hashScheme     = "pbkdf2-sha256"
+	hashIterations = 600000
+	hashSaltSize   = 16
+	hashKeySize    = 32
+
+	// MinPasswordLength is the shortest password accepted for accounts.
+	MinPasswordLength = 10
+
+	// apiTokenPrefix marks API tokens, so they are recognized e.g. by
+	// secret scanners.
+	apiTokenPrefix = "lcal_"
+)
+
+var (
+	// ErrPasswordTooShort is returned for passwords shorter than
+	// MinPasswordLength.
+	ErrPasswordTooShort = errors.New("password is too short")
+
+	// ErrInvalidInviteCode is returned for malformed invite codes.
+	ErrInvalidInviteCode = errors.New("invalid invite code")
+)
+
+// inviteAlphabet leaves out characters that are easily confused when an
+// invite is typed from paper, like 0/o and 1/l/i.
+const (
+	inviteAlphabet   = "abcdefghjkmnpqrstuvwxyz23456789"
+	inviteCodeLength = 16
+)
+
+// HashPassword derives a salted PBKDF2-SHA256 hash of a password, encoded
+// with its parameters as "pbkdf2-sha256$iterations$salt$hash".
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, hashSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key, err := pbkdf2.Key(sha256.New, password, salt, hashIterations, hashKeySize)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return fmt.Sprintf("%s$%d$%s$%s", hashScheme, hashIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// CheckPassword reports whether password matches a hash from HashPassword.
+func CheckPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != hashScheme {
+		return false
+	}
+
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(expected))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, expected) == 1
+}
+
+// ValidatePassword checks the requirements for new passwords.
+func ValidatePassword(password string) error {
+	if len([]rune(password)) < MinPasswordLength {
+		return ErrPasswordTooShort
+	}
+	return nil
+}
+
+// NewToken returns a random token for session cookies and CSRF fields.
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// NewAPIToken returns a random token for scripts using the API.
+func NewAPIToken() (string, error) {
+	token, err := NewToken()
+	if err != nil {
+		return "", err
+	}
+	return apiTokenPrefix + token, nil
+}
+
+// HashToken hashes a token for storage, so a leaked database does not
+// contain usable sessions, invites or API tokens. Tokens are random, a
+// plain SHA-256 is enough.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewInviteCode returns a random invite code of 79 bits in groups of four,
+// like "k7mq-2xhd-ra9t-wc4e", which can be typed from a printout.
+func NewInviteCode() (string, error) {
+	var code strings.Builder
+	b := make([]byte, 1)
+	for n := 0; n < inviteCodeLength; {
+		if _, err := rand.Read(b); err != nil {
+			return "", fmt.Errorf("failed to generate invite code: %w", err)
+		}
+		// Skip values beyond the last full multiple of the alphabet, which
+		// would make some characters more likely.
+		if int(b[0]) >= 256-256%len(inviteAlphabet) {
+			continue
+		}
+		if n > 0 && n%4 == 0 {
+			code.WriteByte('-')
+		}
+		code.WriteByte(inviteAlphabet[int(b[0])%len(inviteAlphabet)])
+		n++
+	}
+	return code.String(), nil
+}
+
+// NormalizeInviteCode removes case, spaces and dashes from a typed invite
+// code.
+func NormalizeInviteCode(code string) (string, error) {
+	var normalized strings.Builder
+	for _, r := range strings.ToLower(code) {
+		if r == '-' || r == ' ' || r == '\t' {
+			continue
+		}
+		if !strings.ContainsRune(inviteAlphabet, r) {
+			return "", ErrInvalidInviteCode
+		}
+		normalized.WriteRune(r)
+	}
+	if normalized.Len() != inviteCodeLength {
+		return "", ErrInvalidInviteCode
+	}
+	return normalized.String(), nil
+}
+
+// EnsureOperator creates the operator account of the config, or updates
+// its password if it changed in the config.
+func EnsureOperator(db *database.DB, username, password string) error {
+	user, err := db.GetUserByUsername(username)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if user == nil {
+		hash, err := HashPassword(password)
+		if err != nil {
+			return err
+		}
+		return db.CreateUser(&database.User{Username: username, PasswordHash: hash, Operator: true})
+	}
+
+	if !CheckPassword(user.PasswordHash, password) {
+		hash, err := HashPassword(password)
+		if err != nil {
+			return err
+		}
+		if err := db.UpdateUserPassword(user.ID, hash); err != nil {
+			return err
+		}
+	}
+
+	if !user.Operator {
+		return db.SetUserOperator(user.ID, true)
+	}
+	return nil
+}
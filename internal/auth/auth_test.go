@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	parts := strings.Split(hash, "$")
+
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		want     bool
+	}{
+		{"correct password", hash, "correct horse", true},
+		{"wrong password", hash, "correct horsf", false},
+		{"empty password", hash, "", false},
+		{"empty hash", "", "correct horse", false},
+		{"unknown scheme", strings.Replace(hash, hashScheme, "bcrypt", 1), "correct horse", false},
+		{"missing part", strings.Join(parts[:3], "$"), "correct horse", false},
+		{"invalid iterations", strings.Join([]string{parts[0], "x", parts[2], parts[3]}, "$"), "correct horse", false},
+		{"zero iterations", strings.Join([]string{parts[0], "0", parts[2], parts[3]}, "$"), "correct horse", false},
+		{"other iterations", strings.Join([]string{parts[0], "1000", parts[2], parts[3]}, "$"), "correct horse", false},
+		{"invalid salt", strings.Join([]string{parts[0], parts[1], "!", parts[3]}, "$"), "correct horse", false},
+		{"empty key", strings.Join([]string{parts[0], parts[1], parts[2], ""}, "$"), "correct horse", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckPassword(tt.hash, tt.password); got != tt.want {
+				t.Errorf("CheckPassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeInviteCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+		err  error
+	}{
+		{"k7mq-2xhd-ra9t-wc4e", "k7mq2xhdra9twc4e", nil},
+		{"K7MQ-2XHD-RA9T-WC4E", "k7mq2xhdra9twc4e", nil},
+		{" k7mq 2xhd\tra9t wc4e ", "k7mq2xhdra9twc4e", nil},
+		{"k7mq2xhdra9twc4e", "k7mq2xhdra9twc4e", nil},
+		{"k7mq-2xhd-ra9t", "", ErrInvalidInviteCode},
+		{"k7mq-2xhd-ra9t-wc4e-a", "", ErrInvalidInviteCode},
+		{"k7mq-2xhd-ra9t-wc4o", "", ErrInvalidInviteCode},
+		{"k7mq-2xhd-ra9t-wc41", "", ErrInvalidInviteCode},
+		{"k7mq-2xhd-ra9t-wc4ä", "", ErrInvalidInviteCode},
+		{"", "", ErrInvalidInviteCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			got, err := NormalizeInviteCode(tt.code)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("NormalizeInviteCode() error = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeInviteCode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewInviteCodeNormalizes(t *testing.T) {
+	code, err := NewInviteCode()
+	if err != nil {
+		t.Fatalf("NewInviteCode: %v", err)
+	}
+	normalized, err := NormalizeInviteCode(code)
+	if err != nil {
+		t.Fatalf("NormalizeInviteCode(%q): %v", code, err)
+	}
+	if normalized != strings.ReplaceAll(code, "-", "") {
+		t.Errorf("NormalizeInviteCode(%q) = %q", code, normalized)
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	if err := ValidatePassword(strings.Repeat("ä", MinPasswordLength)); err != nil {
+		t.Errorf("ValidatePassword() = %v, want nil", err)
+	}
+	if err := ValidatePassword(strings.Repeat("a", MinPasswordLength-1)); !errors.Is(err, ErrPasswordTooShort) {
+		t.Errorf("ValidatePassword() = %v, want %v", err, ErrPasswordTooShort)
+	}
+}
@@ -0,0 +1,162 @@
+// Package cache provides an in-memory LRU store with a memory cap.
+package cache
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+)
+
+type Cache struct {
+	mu         sync.Mutex
+	maxBytes   int64
+	bytes      int64
+	order      *list.List
+	entries    map[string]*list.Element
+	generation uint64
+	hits       uint64
+	misses     uint64
+	evictions  uint64
+}
+
+type entry struct {
+	key   string
+	value interface{}
+	size  int64
+}
+
+type Stats struct {
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"`
+	MaxBytes  int64  `json:"max_bytes"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+}
+
+// New creates a cache holding values of up to maxBytes in total. The sizes
+// are estimates given by the caller. A cache without capacity stores nothing.
+func New(maxBytes int64) *Cache {
+	return &Cache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func (c *Cache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+
+	c.hits++
+	c.order.MoveToFront(element)
+	return element.Value.(*entry).value, true
+}
+
+// Generation changes with every invalidation. Loaders read it before
+// querying the source and pass it to Set, so values loaded before an
+// invalidation are not stored afterwards.
+func (c *Cache) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// Set stores a value unless the cache was invalidated since generation or
+// the value alone exceeds the capacity. Least recently used values are
+// evicted to make room.
+func (c *Cache) Set(key string, value interface{}, size int64, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	c.store(key, value, size)
+}
+
+// Replace removes all values whose key starts with prefix and stores value
+// in one step, so readers get either the old or the new value. Like Set, it
+// does not store a value loaded before another invalidation.
+func (c *Cache) Replace(prefix, key string, value interface{}, size int64, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current := generation == c.generation
+	c.deletePrefix(prefix)
+	if current {
+		c.store(key, value, size)
+	}
+}
+
+// DeletePrefix removes all values whose key starts with prefix.
+func (c *Cache) DeletePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deletePrefix(prefix)
+}
+
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+	c.bytes = 0
+}
+
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return Stats{
+		Entries:   len(c.entries),
+		Bytes:     c.bytes,
+		MaxBytes:  c.maxBytes,
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+}
+
+func (c *Cache) deletePrefix(prefix string) {
+	c.generation++
+	for key, element := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(element)
+		}
+	}
+}
+
+func (c *Cache) store(key string, value interface{}, size int64) {
+	if size > c.maxBytes {
+		return
+	}
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+
+	for c.bytes+size > c.maxBytes && c.order.Len() > 0 {
+		c.remove(c.order.Back())
+		c.evictions++
+	}
+
+	c.entries[key] = c.order.PushFront(&entry{key: key, value: value, size: size})
+	c.bytes += size
+}
+
+func (c *Cache) remove(element *list.Element) {
+	e := element.Value.(*entry)
+	c.order.Remove(element)
+	delete(c.entries, e.key)
+	c.bytes -= e.size
+}
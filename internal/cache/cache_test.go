@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type set struct {
+	key  string
+	size int64
+	get  string
+}
+
+func keys(c *Cache) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func TestCacheEviction(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxBytes  int64
+		steps     []set
+		want      []string
+		bytes     int64
+		evictions uint64
+	}{
+		{
+			name:     "within capacity",
+			maxBytes: 10,
+			steps:    []set{{"a", 3, ""}, {"b", 3, ""}},
+			want:     []string{"a", "b"},
+			bytes:    6,
+		},
+		{
+			name:      "least recently set is evicted",
+			maxBytes:  10,
+			steps:     []set{{"a", 4, ""}, {"b", 4, ""}, {"c", 4, ""}},
+			want:      []string{"b", "c"},
+			bytes:     8,
+			evictions: 1,
+		},
+		{
+			name:      "get keeps a value",
+			maxBytes:  10,
+			steps:     []set{{"a", 4, ""}, {"b", 4, "a"}, {"c", 4, ""}},
+			want:      []string{"a", "c"},
+			bytes:     8,
+			evictions: 1,
+		},
+		{
+			name:      "large value evicts several",
+			maxBytes:  10,
+			steps:     []set{{"a", 3, ""}, {"b", 3, ""}, {"c", 3, ""}, {"d", 9, ""}},
+			want:      []string{"d"},
+			bytes:     9,
+			evictions: 3,
+		},
+		{
+			name:     "value over the cap is not stored",
+			maxBytes: 10,
+			steps:    []set{{"a", 3, ""}, {"b", 11, ""}},
+			want:     []string{"a"},
+			bytes:    3,
+		},
+		{
+			name:     "replacing a key updates the size",
+			maxBytes: 10,
+			steps:    []set{{"a", 3, ""}, {"a", 7, ""}, {"b", 3, ""}},
+			want:     []string{"a", "b"},
+			bytes:    10,
+		},
+		{
+			name:     "no capacity",
+			maxBytes: -1,
+			steps:    []set{{"a", 0, ""}, {"b", 1, ""}},
+			want:     []string{},
+			bytes:    0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(tt.maxBytes)
+			for _, step := range tt.steps {
+				c.Set(step.key, step.key, step.size, c.Generation())
+				if step.get != "" {
+					c.Get(step.get)
+				}
+			}
+
+			if got := keys(c); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("keys = %v, want %v", got, tt.want)
+			}
+			stats := c.Stats()
+			if stats.Bytes != tt.bytes {
+				t.Errorf("bytes = %d, want %d", stats.Bytes, tt.bytes)
+			}
+			if stats.Evictions != tt.evictions {
+				t.Errorf("evictions = %d, want %d", stats.Evictions, tt.evictions)
+			}
+		})
+	}
+}
+
+func TestCacheGeneration(t *testing.T) {
+	tests := []struct {
+		name       string
+		invalidate func(c *Cache)
+	}{
+		{"DeletePrefix", func(c *Cache) { c.DeletePrefix("org:2:") }},
+		{"Clear", func(c *Cache) { c.Clear() }},
+		{"Replace", func(c *Cache) { c.Replace("org:2:", "org:2:snapshot", "new", 1, c.Generation()) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(100)
+
+			// A value loaded before an invalidation must not be stored
+			// after it, even for other keys.
+			generation := c.Generation()
+			tt.invalidate(c)
+			c.Set("org:1:snapshot", "stale", 1, generation)
+			if _, ok := c.Get("org:1:snapshot"); ok {
+				t.Error("stale value stored after invalidation")
+			}
+
+			c.Set("org:1:snapshot", "fresh", 1, c.Generation())
+			if got, ok := c.Get("org:1:snapshot"); !ok || got != "fresh" {
+				t.Errorf("Get() = %v, %v, want fresh", got, ok)
+			}
+		})
+	}
+}
+
+func TestCacheDeletePrefix(t *testing.T) {
+	c := New(100)
+	for _, key := range []string{"org:1:snapshot", "org:1:body:x", "org:12:snapshot", "org:2:snapshot"} {
+		c.Set(key, key, 1, c.Generation())
+	}
+
+	c.DeletePrefix("org:1:")
+	if got, want := keys(c), []string{"org:12:snapshot", "org:2:snapshot"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keys = %v, want %v", got, want)
+	}
+	if got := c.Stats().Bytes; got != 2 {
+		t.Errorf("bytes = %d, want 2", got)
+	}
+}
+
+func TestCacheReplace(t *testing.T) {
+	c := New(100)
+	c.Set("org:1:snapshot", "old", 1, c.Generation())
+	c.Set("org:1:body:x", "old body", 1, c.Generation())
+	c.Set("org:2:snapshot", "other", 1, c.Generation())
+
+	generation := c.Generation()
+	// Readers still get the old value while the new one is loaded.
+	if got, _ := c.Get("org:1:snapshot"); got != "old" {
+		t.Fatalf("Get() = %v before the swap, want old", got)
+	}
+
+	c.Replace("org:1:", "org:1:snapshot", "new", 1, generation)
+	if got, _ := c.Get("org:1:snapshot"); got != "new" {
+		t.Errorf("Get() = %v after the swap, want new", got)
+	}
+	if got, want := keys(c), []string{"org:1:snapshot", "org:2:snapshot"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keys = %v, want %v", got, want)
+	}
+
+	// A replacement loaded before another invalidation still drops the old
+	// values, but is not stored.
+	generation = c.Generation()
+	c.DeletePrefix("org:3:")
+	c.Replace("org:1:", "org:1:snapshot", "stale", 1, generation)
+	if _, ok := c.Get("org:1:snapshot"); ok {
+		t.Error("stale replacement stored")
+	}
+}
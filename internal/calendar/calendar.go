@@ -7,10 +7,9 @@ import (
 )
 
 type Month struct {
-	Year      int
-	Month     time.Month
-	Weeks     []Week
-	MonthName string
+	Year  int
+	Month time.Month
+	Weeks []Week
 }
 
 type Week struct {
@@ -18,11 +17,11 @@ type Week struct {
 }
 
 type Day struct {
-	Date      time.Time
-	Day       int
-	IsToday   bool
-	InMonth   bool
-	Events    []*database.Event
+	Date    time.Time
+	Day     int
+	IsToday bool
+	InMonth bool
+	Events  []*database.Event
 }
 
 func Generate(year int, month time.Month, events []*database.Event) *Month {
@@ -30,10 +29,9 @@ func Generate(year int, month time.Month, events []*database.Event) *Month {
 	lastDay := firstDay.AddDate(0, 1, -1)
 
 	cal := &Month{
-		Year:      year,
-		Month:     month,
-		MonthName: getGermanMonthName(month),
-		Weeks:     make([]Week, 0),
+		Year:  year,
+		Month: month,
+		Weeks: make([]Week, 0),
 	}
 
 	eventsByDate := groupEventsByDate(events)
@@ -56,7 +54,7 @@ func Generate(year int, month time.Month, events []*database.Event) *Month {
 		})
 	}
 
-	today := time.Now().UTC()
+	today := database.WallClock(time.Now())
 	todayStr := today.Format("2006-01-02")
 
 	for day := 1; day <= lastDay.Day(); day++ {
@@ -97,24 +95,6 @@ func Generate(year int, month time.Month, events []*database.Event) *Month {
 	return cal
 }
 
-func getGermanMonthName(month time.Month) string {
-	germanMonths := map[time.Month]string{
-		time.January:   "Januar",
-		time.February:  "Februar",
-		time.March:     "März",
-		time.April:     "April",
-		time.May:       "Mai",
-		time.June:      "Juni",
-		time.July:      "Juli",
-		time.August:    "August",
-		time.September: "September",
-		time.October:   "Oktober",
-		time.November:  "November",
-		time.December:  "Dezember",
-	}
-	return germanMonths[month]
-}
-
 func groupEventsByDate(events []*database.Event) map[string][]*database.Event {
 	result := make(map[string][]*database.Event)
 	for _, event := range events {
@@ -0,0 +1,268 @@
+package calendar
+
+import (
+	"sort"
+	"time"
+
+	"github.com/romanzipp/linke-calendar/internal/database"
+)
+
+const (
+	ViewMonth  = "month"
+	ViewWeek   = "week"
+	ViewDay    = "day"
+	ViewAgenda = "agenda"
+)
+
+// AgendaDays is the number of days shown by the agenda view.
+const AgendaDays = 28
+
+const (
+	defaultFirstHour = 8
+	defaultLastHour  = 20
+)
+
+// ParseView returns the view for a query value, the month view by default.
+func ParseView(value string) string {
+	switch value {
+	case ViewWeek, ViewDay, ViewAgenda:
+		return value
+	default:
+		return ViewMonth
+	}
+}
+
+// TimeGrid is a week or day with timed events placed by their time of day.
+type TimeGrid struct {
+	Start     time.Time
+	End       time.Time
+	Days      []GridDay
+	FirstHour int
+	LastHour  int
+}
+
+type GridDay struct {
+	Day
+	AllDayEvents []*database.Event
+	TimedEvents  []PlacedEvent
+}
+
+// PlacedEvent is an event positioned in a time grid. Top and Height are
+// percentages of the visible hours, overlapping events share the width in
+// columns.
+type PlacedEvent struct {
+	Event   *database.Event
+	Top     float64
+	Height  float64
+	Column  int
+	Columns int
+}
+
+func (p PlacedEvent) Left() float64 {
+	return float64(p.Column) * 100 / float64(p.Columns)
+}
+
+func (p PlacedEvent) Width() float64 {
+	return 100 / float64(p.Columns)
+}
+
+// Hours lists the hours shown in the grid.
+func (g *TimeGrid) Hours() []int {
+	hours := make([]int, 0, g.LastHour-g.FirstHour)
+	for hour := g.FirstHour; hour < g.LastHour; hour++ {
+		hours = append(hours, hour)
+	}
+	return hours
+}
+
+// Agenda lists the days of a period that have events.
+type Agenda struct {
+	Start time.Time
+	End   time.Time
+	Days  []GridDay
+}
+
+// WeekStart returns the Monday of the week containing date.
+func WeekStart(date time.Time) time.Time {
+	weekday := int(date.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, 1-weekday)
+}
+
+func GenerateWeek(date time.Time, events []*database.Event) *TimeGrid {
+	start := WeekStart(date)
+	return generateGrid(start, 7, events)
+}
+
+func GenerateDay(date time.Time, events []*database.Event) *TimeGrid {
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	return generateGrid(start, 1, events)
+}
+
+func GenerateAgenda(start time.Time, events []*database.Event) *Agenda {
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	agenda := &Agenda{
+		Start: start,
+		End:   start.AddDate(0, 0, AgendaDays-1),
+	}
+
+	eventsByDate := groupEventsByDate(events)
+	for _, day := range days(start, AgendaDays) {
+		if len(eventsByDate[day.Date.Format("2006-01-02")]) == 0 {
+			continue
+		}
+		agenda.Days = append(agenda.Days, newGridDay(day, eventsByDate))
+	}
+
+	return agenda
+}
+
+func generateGrid(start time.Time, count int, events []*database.Event) *TimeGrid {
+	grid := &TimeGrid{
+		Start:     start,
+		End:       start.AddDate(0, 0, count-1),
+		FirstHour: defaultFirstHour,
+		LastHour:  defaultLastHour,
+	}
+
+	eventsByDate := groupEventsByDate(events)
+	for _, day := range days(start, count) {
+		grid.Days = append(grid.Days, newGridDay(day, eventsByDate))
+	}
+
+	// Extend the visible hours to early and late events.
+	for _, day := range grid.Days {
+		for _, event := range day.Events {
+			if event.AllDay {
+				continue
+			}
+			startMinute, endMinute := minutesOfDay(event)
+			if hour := startMinute / 60; hour < grid.FirstHour {
+				grid.FirstHour = hour
+			}
+			if hour := (endMinute + 59) / 60; hour > grid.LastHour {
+				grid.LastHour = hour
+			}
+		}
+	}
+
+	for i := range grid.Days {
+		grid.Days[i].TimedEvents = placeEvents(grid.Days[i].Events, grid.FirstHour, grid.LastHour)
+	}
+
+	return grid
+}
+
+func days(start time.Time, count int) []Day {
+	// Event times are Berlin wall clock, so is today.
+	todayStr := database.WallClock(time.Now()).Format("2006-01-02")
+
+	result := make([]Day, 0, count)
+	for i := 0; i < count; i++ {
+		date := start.AddDate(0, 0, i)
+		result = append(result, Day{
+			Date:    date,
+			Day:     date.Day(),
+			IsToday: date.Format("2006-01-02") == todayStr,
+			InMonth: true,
+		})
+	}
+	return result
+}
+
+func newGridDay(day Day, eventsByDate map[string][]*database.Event) GridDay {
+	day.Events = eventsByDate[day.Date.Format("2006-01-02")]
+
+	gridDay := GridDay{Day: day}
+	for _, event := range day.Events {
+		if event.AllDay {
+			gridDay.AllDayEvents = append(gridDay.AllDayEvents, event)
+		}
+	}
+	return gridDay
+}
+
+// placeEvents positions the timed events of a day and splits overlapping
+// events into columns.
+func placeEvents(events []*database.Event, firstHour, lastHour int) []PlacedEvent {
+	type span struct {
+		event      *database.Event
+		start, end int
+		column     int
+	}
+
+	var spans []*span
+	for _, event := range events {
+		if event.AllDay {
+			continue
+		}
+		start, end := minutesOfDay(event)
+		spans = append(spans, &span{event: event, start: start, end: end})
+	}
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].start < spans[j].start
+	})
+
+	visible := float64((lastHour - firstHour) * 60)
+	placed := make([]PlacedEvent, 0, len(spans))
+
+	// Events overlapping each other directly or transitively form a group
+	// sharing the same number of columns.
+	for i := 0; i < len(spans); {
+		groupEnd := spans[i].end
+		var columnEnds []int
+		j := i
+		for ; j < len(spans) && spans[j].start < groupEnd; j++ {
+			column := 0
+			for column < len(columnEnds) && columnEnds[column] > spans[j].start {
+				column++
+			}
+			if column == len(columnEnds) {
+				columnEnds = append(columnEnds, 0)
+			}
+			columnEnds[column] = spans[j].end
+			spans[j].column = column
+			if spans[j].end > groupEnd {
+				groupEnd = spans[j].end
+			}
+		}
+
+		for _, s := range spans[i:j] {
+			placed = append(placed, PlacedEvent{
+				Event:   s.event,
+				Top:     float64(s.start-firstHour*60) * 100 / visible,
+				Height:  float64(s.end-s.start) * 100 / visible,
+				Column:  s.column,
+				Columns: len(columnEnds),
+			})
+		}
+		i = j
+	}
+
+	return placed
+}
+
+// minutesOfDay returns start and end of an event as minutes since midnight
+// of its start day. Events run at least 30 minutes and end at midnight.
+func minutesOfDay(event *database.Event) (int, int) {
+	start := event.DatetimeStart.Hour()*60 + event.DatetimeStart.Minute()
+	end := start + 60
+
+	if event.DatetimeEnd.Valid && event.DatetimeEnd.Time.After(event.DatetimeStart) {
+		end = start + int(event.DatetimeEnd.Time.Sub(event.DatetimeStart).Minutes())
+	}
+	if end < start+30 {
+		end = start + 30
+	}
+	if end > 24*60 {
+		end = 24 * 60
+	}
+	if start > end-30 {
+		start = end - 30
+	}
+
+	return start, end
+}
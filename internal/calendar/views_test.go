@@ -0,0 +1,144 @@
+package calendar
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/romanzipp/linke-calendar/internal/database"
+)
+
+func timedEvent(title, start, end string) *database.Event {
+	day := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	parse := func(clock string) time.Time {
+		t, err := time.Parse("15:04", clock)
+		if err != nil {
+			panic(err)
+		}
+		return day.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute)
+	}
+
+	event := &database.Event{Title: title, DatetimeStart: parse(start)}
+	if end != "" {
+		event.DatetimeEnd = sql.NullTime{Time: parse(end), Valid: true}
+	}
+	return event
+}
+
+func TestPlaceEvents(t *testing.T) {
+	allDay := timedEvent("all day", "00:00", "")
+	allDay.AllDay = true
+
+	tests := []struct {
+		name   string
+		events []*database.Event
+		want   []string
+	}{
+		{
+			name:   "single event",
+			events: []*database.Event{timedEvent("a", "10:00", "11:00")},
+			want:   []string{"a 0/1"},
+		},
+		{
+			name:   "overlapping",
+			events: []*database.Event{timedEvent("a", "10:00", "12:00"), timedEvent("b", "11:00", "13:00")},
+			want:   []string{"a 0/2", "b 1/2"},
+		},
+		{
+			name:   "back to back",
+			events: []*database.Event{timedEvent("a", "10:00", "11:00"), timedEvent("b", "11:00", "12:00")},
+			want:   []string{"a 0/1", "b 0/1"},
+		},
+		{
+			name:   "unsorted input",
+			events: []*database.Event{timedEvent("b", "11:00", "13:00"), timedEvent("a", "10:00", "12:00")},
+			want:   []string{"a 0/2", "b 1/2"},
+		},
+		{
+			name: "transitive overlap reuses a free column",
+			events: []*database.Event{
+				timedEvent("a", "10:00", "12:00"),
+				timedEvent("b", "11:00", "13:00"),
+				timedEvent("c", "12:30", "14:00"),
+			},
+			want: []string{"a 0/2", "b 1/2", "c 0/2"},
+		},
+		{
+			name: "three at once",
+			events: []*database.Event{
+				timedEvent("a", "10:00", "12:00"),
+				timedEvent("b", "10:00", "12:00"),
+				timedEvent("c", "11:00", "11:30"),
+			},
+			want: []string{"a 0/3", "b 1/3", "c 2/3"},
+		},
+		{
+			name: "separate groups",
+			events: []*database.Event{
+				timedEvent("a", "09:00", "10:00"),
+				timedEvent("b", "09:30", "10:30"),
+				timedEvent("c", "15:00", "16:00"),
+			},
+			want: []string{"a 0/2", "b 1/2", "c 0/1"},
+		},
+		{
+			name:   "without end",
+			events: []*database.Event{timedEvent("a", "10:00", ""), timedEvent("b", "10:30", "")},
+			want:   []string{"a 0/2", "b 1/2"},
+		},
+		{
+			name:   "all-day events are skipped",
+			events: []*database.Event{allDay, timedEvent("a", "10:00", "11:00")},
+			want:   []string{"a 0/1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, placed := range placeEvents(tt.events, defaultFirstHour, defaultLastHour) {
+				got = append(got, fmt.Sprintf("%s %d/%d", placed.Event.Title, placed.Column, placed.Columns))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("placeEvents() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaceEventsPosition(t *testing.T) {
+	tests := []struct {
+		start, end  string
+		top, height float64
+	}{
+		{"08:00", "09:00", 0, 100.0 / 12},
+		{"14:00", "20:00", 50, 50},
+		{"10:00", "10:10", 100.0 / 6, 100.0 / 24},
+		{"23:50", "", 100.0 * (23*60 + 30 - 8*60) / 720, 100.0 / 24},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.start, func(t *testing.T) {
+			placed := placeEvents([]*database.Event{timedEvent("a", tt.start, tt.end)}, 8, 20)
+			if len(placed) != 1 {
+				t.Fatalf("placed %d events", len(placed))
+			}
+			if math.Abs(placed[0].Top-tt.top) > 1e-9 || math.Abs(placed[0].Height-tt.height) > 1e-9 {
+				t.Errorf("top, height = %v, %v, want %v, %v", placed[0].Top, placed[0].Height, tt.top, tt.height)
+			}
+		})
+	}
+}
+
+func TestDaysToday(t *testing.T) {
+	today := database.WallClock(time.Now()).Truncate(24 * time.Hour)
+
+	for i, day := range days(today.AddDate(0, 0, -1), 3) {
+		if want := i == 1; day.IsToday != want {
+			t.Errorf("%s: IsToday = %v, want %v", day.Date.Format("2006-01-02"), day.IsToday, want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package calendar
+
+import (
+	"time"
+
+	"github.com/romanzipp/linke-calendar/internal/database"
+)
+
+type Year struct {
+	Year   int
+	Months []*Month
+}
+
+// GenerateYear builds the twelve months of a year for the overview page.
+func GenerateYear(year int, events []*database.Event) *Year {
+	eventsByMonth := make(map[time.Month][]*database.Event)
+	for _, event := range events {
+		if event.DatetimeStart.Year() == year {
+			eventsByMonth[event.DatetimeStart.Month()] = append(eventsByMonth[event.DatetimeStart.Month()], event)
+		}
+	}
+
+	result := &Year{Year: year}
+	for month := time.January; month <= time.December; month++ {
+		result.Months = append(result.Months, Generate(year, month, eventsByMonth[month]))
+	}
+	return result
+}
+
+// HeatLevel grades the number of events of a day from 0 (none) to 4 (five
+// or more) for the shading of the year overview.
+func (d Day) HeatLevel() int {
+	switch n := len(d.Events); {
+	case n == 0:
+		return 0
+	case n <= 2:
+		return n
+	case n <= 4:
+		return 3
+	default:
+		return 4
+	}
+}
@@ -2,15 +2,40 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/romanzipp/linke-calendar/internal/ical"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
 	Scraper Scraper `yaml:"scraper"`
 	Server  Server  `yaml:"server"`
+	Sources Sources `yaml:"sources"`
+	Admin   Admin   `yaml:"admin"`
+	API     API     `yaml:"api"`
+	ICal    ICal    `yaml:"ical"`
+	Cache   Cache   `yaml:"cache"`
+	Themes  []Theme `yaml:"themes"`
+	// CacheControl overrides the Cache-Control header per public endpoint.
+	CacheControl map[string]string `yaml:"cache_control"`
+}
+
+// defaultCacheControl holds the Cache-Control headers of the public
+// endpoints. Calendar apps poll feeds often, so these are cached longer.
+var defaultCacheControl = map[string]string{
+	"calendar": "public, max-age=300",
+	"list":     "public, max-age=300",
+	"event":    "public, max-age=300",
+	"ical":     "public, max-age=900",
+	"feed":     "public, max-age=900",
+	"api":      "public, max-age=60",
+	"oembed":   "public, max-age=3600",
+	"search":   "public, max-age=300",
 }
 
 type Scraper struct {
@@ -19,8 +44,47 @@ type Scraper struct {
 }
 
 type Server struct {
-	Port string `yaml:"port"`
-	Host string `yaml:"host"`
+	Port      string `yaml:"port"`
+	Host      string `yaml:"host"`
+	PublicURL string `yaml:"public_url"`
+	// TrustedProxies lists the addresses or CIDR ranges of reverse proxies
+	// whose X-Forwarded-For and X-Real-IP headers are honored.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+type API struct {
+	CORSOrigins []string `yaml:"cors_origins"`
+}
+
+type Cache struct {
+	// MaxMemoryMB caps the in-memory read cache. Negative values disable it.
+	MaxMemoryMB int `yaml:"max_memory_mb"`
+}
+
+type ICal struct {
+	Alarms []AlarmDefault `yaml:"alarms"`
+}
+
+// AlarmDefault adds reminders to events of an activity, or to all events if
+// no activity is set.
+type AlarmDefault struct {
+	Activity string   `yaml:"activity"`
+	Before   []string `yaml:"before"`
+}
+
+type Admin struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+type Sources struct {
+	Mobilizon []MobilizonSource `yaml:"mobilizon"`
+}
+
+type MobilizonSource struct {
+	Organization int    `yaml:"organization"`
+	Instance     string `yaml:"instance"`
+	Group        string `yaml:"group"`
 }
 
 func Load(path string) (*Config, error) {
@@ -54,6 +118,55 @@ func (c *Config) validate() error {
 		}
 	}
 
+	if c.Server.PublicURL != "" {
+		u, err := url.Parse(c.Server.PublicURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("server.public_url: invalid URL %q", c.Server.PublicURL)
+		}
+	}
+
+	for i, proxy := range c.Server.TrustedProxies {
+		if _, err := parseProxy(proxy); err != nil {
+			return fmt.Errorf("server.trusted_proxies[%d]: invalid address or range %q", i, proxy)
+		}
+	}
+
+	for i, source := range c.Sources.Mobilizon {
+		if source.Organization == 0 {
+			return fmt.Errorf("sources.mobilizon[%d].organization: required", i)
+		}
+		if source.Group == "" {
+			return fmt.Errorf("sources.mobilizon[%d].group: required", i)
+		}
+		u, err := url.Parse(source.Instance)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("sources.mobilizon[%d].instance: invalid URL %q", i, source.Instance)
+		}
+	}
+
+	for i, theme := range c.Themes {
+		if theme.Organization == 0 {
+			return fmt.Errorf("themes[%d].organization: required", i)
+		}
+		if err := theme.validate(); err != nil {
+			return fmt.Errorf("themes[%d].%w", i, err)
+		}
+	}
+
+	for endpoint := range c.CacheControl {
+		if _, ok := defaultCacheControl[endpoint]; !ok {
+			return fmt.Errorf("cache_control.%s: unknown endpoint", endpoint)
+		}
+	}
+
+	for i, alarm := range c.ICal.Alarms {
+		for _, before := range alarm.Before {
+			if _, err := ical.ParseTrigger(before); err != nil {
+				return fmt.Errorf("ical.alarms[%d].before: %w", i, err)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -84,3 +197,105 @@ func (c *Config) GetServerAddress() string {
 	}
 	return host + ":" + port
 }
+
+// GetTrustedProxies returns the configured proxies as address ranges.
+func (c *Config) GetTrustedProxies() []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, proxy := range c.Server.TrustedProxies {
+		if prefix, err := parseProxy(proxy); err == nil {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// parseProxy accepts a single address or a CIDR range.
+func parseProxy(proxy string) (netip.Prefix, error) {
+	proxy = strings.TrimSpace(proxy)
+	if strings.Contains(proxy, "/") {
+		prefix, err := netip.ParsePrefix(proxy)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(proxy)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func (c *Config) GetMobilizonSources(orgID int) []MobilizonSource {
+	var sources []MobilizonSource
+	for _, source := range c.Sources.Mobilizon {
+		if source.Organization == orgID {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+func (c *Config) GetAdminUsername() string {
+	if c.Admin.Username == "" {
+		return "admin"
+	}
+	return c.Admin.Username
+}
+
+func (c *Config) AdminEnabled() bool {
+	return c.Admin.Password != ""
+}
+
+// GetAlarms returns the configured reminders for events of an activity.
+// Entries for a specific activity take precedence over the default entry.
+func (c *Config) GetAlarms(activity string) []time.Duration {
+	var selected *AlarmDefault
+	for i, alarm := range c.ICal.Alarms {
+		if alarm.Activity == "" && selected == nil {
+			selected = &c.ICal.Alarms[i]
+		}
+		if alarm.Activity != "" && strings.EqualFold(alarm.Activity, activity) {
+			selected = &c.ICal.Alarms[i]
+			break
+		}
+	}
+	if selected == nil {
+		return nil
+	}
+
+	var alarms []time.Duration
+	for _, before := range selected.Before {
+		d, _ := ical.ParseTrigger(before)
+		alarms = append(alarms, d)
+	}
+	return alarms
+}
+
+func (c *Config) GetCacheMaxBytes() int64 {
+	if c.Cache.MaxMemoryMB < 0 {
+		return 0
+	}
+	if c.Cache.MaxMemoryMB == 0 {
+		return 64 << 20
+	}
+	return int64(c.Cache.MaxMemoryMB) << 20
+}
+
+func (c *Config) GetCacheControl(endpoint string) string {
+	if value, ok := c.CacheControl[endpoint]; ok {
+		return value
+	}
+	return defaultCacheControl[endpoint]
+}
+
+// AllowsOrigin reports whether browsers on origin may read the JSON API.
+// Without configuration the API is open to all origins.
+func (c *Config) AllowsOrigin(origin string) bool {
+	if len(c.API.CORSOrigins) == 0 {
+		return true
+	}
+	for _, allowed := range c.API.CORSOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Theme customizes the look of the embedded pages of an organization.
+// Empty values keep the default.
+type Theme struct {
+	Organization int    `yaml:"organization"`
+	Primary      string `yaml:"primary"`
+	Text         string `yaml:"text"`
+	Font         string `yaml:"font"`
+	Density      string `yaml:"density"`
+	Mode         string `yaml:"mode"`
+}
+
+var (
+	ThemeFonts     = []string{"inter", "work-sans"}
+	ThemeDensities = []string{"comfortable", "compact"}
+	ThemeModes     = []string{"light", "dark"}
+)
+
+var themeColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+func ValidThemeColor(value string) bool {
+	return themeColorPattern.MatchString(value)
+}
+
+func ValidThemeOption(value string, options []string) bool {
+	for _, option := range options {
+		if value == option {
+			return true
+		}
+	}
+	return false
+}
+
+func (t Theme) validate() error {
+	if t.Primary != "" && !ValidThemeColor(t.Primary) {
+		return fmt.Errorf("primary: invalid color %q, use #rgb or #rrggbb", t.Primary)
+	}
+	if t.Text != "" && !ValidThemeColor(t.Text) {
+		return fmt.Errorf("text: invalid color %q, use #rgb or #rrggbb", t.Text)
+	}
+	if t.Font != "" && !ValidThemeOption(t.Font, ThemeFonts) {
+		return fmt.Errorf("font: must be one of %v", ThemeFonts)
+	}
+	if t.Density != "" && !ValidThemeOption(t.Density, ThemeDensities) {
+		return fmt.Errorf("density: must be one of %v", ThemeDensities)
+	}
+	if t.Mode != "" && !ValidThemeOption(t.Mode, ThemeModes) {
+		return fmt.Errorf("mode: must be one of %v", ThemeModes)
+	}
+	return nil
+}
+
+// GetTheme returns the configured theme of an organization.
+func (c *Config) GetTheme(orgID int) Theme {
+	for _, theme := range c.Themes {
+		if theme.Organization == orgID {
+			return theme
+		}
+	}
+	return Theme{Organization: orgID}
+}
@@ -9,6 +9,10 @@ import (
 
 type DB struct {
 	*sql.DB
+
+	// fts reports whether the full-text index is available. It needs
+	// SQLite built with FTS5, see the sqlite_fts5 build tag.
+	fts bool
 }
 
 func New(path string) (*DB, error) {
@@ -30,6 +34,8 @@ func (db *DB) Initialize() error {
 		id INTEGER PRIMARY KEY,
 		title TEXT,
 		last_scraped DATETIME,
+		scrape_status TEXT,
+		paused BOOLEAN NOT NULL DEFAULT 0,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
 
@@ -42,24 +48,176 @@ func (db *DB) Initialize() error {
 		datetime_end DATETIME,
 		url TEXT NOT NULL UNIQUE,
 		location TEXT,
+		activity TEXT,
+		campaign TEXT,
 		scraper TEXT DEFAULT 'website',
+		all_day BOOLEAN NOT NULL DEFAULT 0,
+		latitude REAL,
+		longitude REAL,
+		sequence INTEGER NOT NULL DEFAULT 0,
+		source_id TEXT,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY (organization_id) REFERENCES organizations(id)
 	);
 
+	CREATE TABLE IF NOT EXISTS event_overrides (
+		event_id INTEGER PRIMARY KEY,
+		title TEXT,
+		description TEXT,
+		location TEXT,
+		hidden BOOLEAN NOT NULL DEFAULT 0,
+		highlight BOOLEAN NOT NULL DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (event_id) REFERENCES events(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		organization_id INTEGER NOT NULL,
+		field TEXT NOT NULL,
+		pattern TEXT NOT NULL DEFAULT '',
+		regex BOOLEAN NOT NULL DEFAULT 0,
+		action TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (organization_id) REFERENCES organizations(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS submissions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		organization_id INTEGER NOT NULL,
+		title TEXT NOT NULL,
+		description TEXT,
+		datetime_start DATETIME NOT NULL,
+		datetime_end DATETIME,
+		all_day BOOLEAN NOT NULL DEFAULT 0,
+		location TEXT,
+		url TEXT,
+		submitter_name TEXT NOT NULL,
+		submitter_email TEXT,
+		status TEXT NOT NULL DEFAULT 'pending',
+		event_id INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (organization_id) REFERENCES organizations(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS scrape_errors (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		organization_id INTEGER NOT NULL,
+		message TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (organization_id) REFERENCES organizations(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS organization_themes (
+		organization_id INTEGER PRIMARY KEY,
+		primary_color TEXT NOT NULL DEFAULT '',
+		text_color TEXT NOT NULL DEFAULT '',
+		font TEXT NOT NULL DEFAULT '',
+		density TEXT NOT NULL DEFAULT '',
+		mode TEXT NOT NULL DEFAULT '',
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (organization_id) REFERENCES organizations(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		password_hash TEXT NOT NULL,
+		operator BOOLEAN NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS memberships (
+		user_id INTEGER NOT NULL,
+		organization_id INTEGER NOT NULL,
+		role TEXT NOT NULL,
+		PRIMARY KEY (user_id, organization_id),
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS sessions (
+		token_hash TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		csrf_token TEXT NOT NULL,
+		expires_at DATETIME NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS invites (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		token_hash TEXT NOT NULL UNIQUE,
+		organization_id INTEGER,
+		role TEXT NOT NULL,
+		created_by INTEGER NOT NULL,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS api_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		organization_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		scopes TEXT NOT NULL,
+		created_by INTEGER NOT NULL,
+		last_used_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (organization_id) REFERENCES organizations(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS scrape_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		organization_id INTEGER NOT NULL,
+		token_id INTEGER,
+		status TEXT NOT NULL,
+		error TEXT,
+		queued_at DATETIME NOT NULL,
+		started_at DATETIME,
+		finished_at DATETIME,
+		FOREIGN KEY (organization_id) REFERENCES organizations(id)
+	);
+
 	CREATE INDEX IF NOT EXISTS idx_events_org_date ON events(organization_id, datetime_start);
 	CREATE INDEX IF NOT EXISTS idx_events_date ON events(datetime_start);
+	CREATE INDEX IF NOT EXISTS idx_submissions_org_status ON submissions(organization_id, status);
+	CREATE INDEX IF NOT EXISTS idx_scrape_errors_org ON scrape_errors(organization_id, id);
+	CREATE INDEX IF NOT EXISTS idx_memberships_org ON memberships(organization_id);
+	CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id);
+	CREATE INDEX IF NOT EXISTS idx_api_tokens_org ON api_tokens(organization_id);
+	CREATE INDEX IF NOT EXISTS idx_scrape_runs_org ON scrape_runs(organization_id, id);
 	`
 
 	if _, err := db.Exec(schema); err != nil {
 		return fmt.Errorf("failed to initialize schema: %w", err)
 	}
 
-	migrationSQL := `
-	ALTER TABLE organizations ADD COLUMN title TEXT;
-	`
-	db.Exec(migrationSQL)
+	migrations := []string{
+		`ALTER TABLE organizations ADD COLUMN title TEXT`,
+		`ALTER TABLE events ADD COLUMN all_day BOOLEAN NOT NULL DEFAULT 0`,
+		`ALTER TABLE events ADD COLUMN activity TEXT`,
+		`ALTER TABLE events ADD COLUMN latitude REAL`,
+		`ALTER TABLE events ADD COLUMN longitude REAL`,
+		`ALTER TABLE events ADD COLUMN sequence INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE events ADD COLUMN source_id TEXT`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_events_source_id ON events(source_id)`,
+		`ALTER TABLE events ADD COLUMN campaign TEXT`,
+		`ALTER TABLE organizations ADD COLUMN scrape_status TEXT`,
+		`ALTER TABLE organizations ADD COLUMN paused BOOLEAN NOT NULL DEFAULT 0`,
+	}
+	for _, migrationSQL := range migrations {
+		db.Exec(migrationSQL)
+	}
+
+	if err := db.backfillSourceIDs(); err != nil {
+		return fmt.Errorf("failed to backfill source IDs: %w", err)
+	}
+
+	if err := db.initializeSearch(); err != nil {
+		return fmt.Errorf("failed to initialize search index: %w", err)
+	}
 
 	return nil
 }
@@ -2,10 +2,46 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
+
+	"github.com/mattn/go-sqlite3"
 )
 
+// ScraperManual marks events maintained in the admin UI. Scrapes never
+// overwrite them.
+const ScraperManual = "manual"
+
+// ScraperZetkin marks events scraped from Zetkin, whose organizations have a
+// public page there.
+const ScraperZetkin = "zetkin"
+
+// ErrDuplicateURL is returned when an event gets the URL of another event.
+// URLs are unique, as scraped events used to be identified by them.
+var ErrDuplicateURL = errors.New("another event has the same URL")
+
+// Berlin is the time zone of the events, whose times are stored as Berlin
+// wall clock time in UTC. It is loaded once, as event times are converted
+// often.
+var Berlin = loadBerlin()
+
+func loadBerlin() *time.Location {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// WallClock converts t to Berlin wall clock time in UTC, to compare it with
+// stored event times.
+func WallClock(t time.Time) time.Time {
+	local := t.In(Berlin)
+	return time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), local.Nanosecond(), time.UTC)
+}
+
 type Event struct {
 	ID             int
 	OrganizationID int
@@ -15,17 +51,73 @@ type Event struct {
 	DatetimeEnd    sql.NullTime
 	URL            string
 	Location       sql.NullString
+	Activity       sql.NullString
+	Campaign       sql.NullString
 	Scraper        string
+	AllDay         bool
+	Latitude       sql.NullFloat64
+	Longitude      sql.NullFloat64
+	Sequence       int
+	SourceID       sql.NullString
+	Hidden         bool
+	Highlight      bool
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
+
+	// Override holds the local edits applied to this event, Original the
+	// event as scraped. Both are nil for events without an override.
+	Override *EventOverride
+	Original *Event
+}
+
+func (e *Event) IsManual() bool {
+	return e.Scraper == ScraperManual
+}
+
+// HasLink reports whether URL points to an external page. Manual events
+// without a link carry a generated placeholder to satisfy the unique key.
+func (e *Event) HasLink() bool {
+	return strings.HasPrefix(e.URL, "http://") || strings.HasPrefix(e.URL, "https://")
+}
+
+// UID identifies the event independently of the local database, e.g. in
+// iCal feeds. Events predating source IDs fall back to the database ID.
+func (e *Event) UID() string {
+	if e.SourceID.Valid {
+		return e.SourceID.String
+	}
+	return fmt.Sprintf("%d-%d@linke-calendar", e.OrganizationID, e.ID)
+}
+
+// Source returns the event without local overrides applied.
+func (e *Event) Source() *Event {
+	if e.Original != nil {
+		return e.Original
+	}
+	return e
+}
+
+const eventColumns = `e.id, e.organization_id, e.title, e.description, e.datetime_start, e.datetime_end,
+		       e.url, e.location, e.activity, e.campaign, e.scraper, e.all_day, e.latitude, e.longitude, e.sequence,
+		       e.source_id, e.created_at, e.updated_at,
+		       o.event_id, o.title, o.description, o.location, COALESCE(o.hidden, 0), COALESCE(o.highlight, 0), o.updated_at`
+
+const eventTables = `events e LEFT JOIN event_overrides o ON o.event_id = e.id`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
 }
 
 func (db *DB) CreateEvent(event *Event) error {
+	return createEvent(db, event)
+}
+
+func createEvent(db execer, event *Event) error {
 	query := `
 		INSERT INTO events (
 			organization_id, title, description, datetime_start, datetime_end,
-			url, location, scraper
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			url, location, activity, campaign, scraper, all_day, latitude, longitude, source_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	result, err := db.Exec(
 		query,
@@ -36,9 +128,18 @@ func (db *DB) CreateEvent(event *Event) error {
 		event.DatetimeEnd,
 		event.URL,
 		event.Location,
+		event.Activity,
+		event.Campaign,
 		event.Scraper,
+		event.AllDay,
+		event.Latitude,
+		event.Longitude,
+		event.SourceID,
 	)
 	if err != nil {
+		if isUniqueViolation(err, "events.url") {
+			return ErrDuplicateURL
+		}
 		return fmt.Errorf("failed to create event: %w", err)
 	}
 
@@ -53,59 +154,68 @@ func (db *DB) CreateEvent(event *Event) error {
 
 func (db *DB) GetEvent(id int) (*Event, error) {
 	query := `
-		SELECT id, organization_id, title, description, datetime_start, datetime_end,
-		       url, location, scraper, created_at, updated_at
-		FROM events WHERE id = ?
+		SELECT ` + eventColumns + `
+		FROM ` + eventTables + `
+		WHERE e.id = ?
 	`
-	var event Event
-	err := db.QueryRow(query, id).Scan(
-		&event.ID,
-		&event.OrganizationID,
-		&event.Title,
-		&event.Description,
-		&event.DatetimeStart,
-		&event.DatetimeEnd,
-		&event.URL,
-		&event.Location,
-		&event.Scraper,
-		&event.CreatedAt,
-		&event.UpdatedAt,
-	)
+	event, err := scanEvent(db.QueryRow(query, id))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get event: %w", err)
 	}
-	return &event, nil
+	return event, nil
+}
+
+// GetEventBySourceID returns the event with the given source ID, see UID.
+func (db *DB) GetEventBySourceID(sourceID string) (*Event, error) {
+	query := `
+		SELECT ` + eventColumns + `
+		FROM ` + eventTables + `
+		WHERE e.source_id = ?
+	`
+	event, err := scanEvent(db.QueryRow(query, sourceID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event: %w", err)
+	}
+	return event, nil
+}
+
+// GetEventOrganizationID returns the organization of the event with the
+// given ID or source ID.
+func (db *DB) GetEventOrganizationID(id int, sourceID string) (int, error) {
+	var orgID int
+	err := db.QueryRow(`SELECT organization_id FROM events WHERE id = ? OR source_id = ?`, id, sourceID).Scan(&orgID)
+	if err != nil {
+		return 0, err
+	}
+	return orgID, nil
 }
 
 func (db *DB) GetEventsByOrganization(orgID int) ([]*Event, error) {
 	query := `
-		SELECT id, organization_id, title, description, datetime_start, datetime_end,
-		       url, location, scraper, created_at, updated_at
-		FROM events
-		WHERE organization_id = ?
-		ORDER BY datetime_start ASC
+		SELECT ` + eventColumns + `
+		FROM ` + eventTables + `
+		WHERE e.organization_id = ?
+		ORDER BY e.datetime_start ASC, e.id ASC
 	`
 	return db.queryEvents(query, orgID)
 }
 
 func (db *DB) GetEventsByOrganizationInRange(orgID int, start, end time.Time) ([]*Event, error) {
 	query := `
-		SELECT id, organization_id, title, description, datetime_start, datetime_end,
-		       url, location, scraper, created_at, updated_at
-		FROM events
-		WHERE organization_id = ? AND datetime_start >= ? AND datetime_start < ?
-		ORDER BY datetime_start ASC
+		SELECT ` + eventColumns + `
+		FROM ` + eventTables + `
+		WHERE e.organization_id = ? AND e.datetime_start >= ? AND e.datetime_start < ?
+		ORDER BY e.datetime_start ASC, e.id ASC
 	`
 	return db.queryEvents(query, orgID, start, end)
 }
 
 func (db *DB) GetUpcomingEventsByOrganization(orgID int, limit int) ([]*Event, error) {
 	query := `
-		SELECT id, organization_id, title, description, datetime_start, datetime_end,
-		       url, location, scraper, created_at, updated_at
-		FROM events
-		WHERE organization_id = ? AND datetime_start >= datetime('now')
-		ORDER BY datetime_start ASC
+		SELECT ` + eventColumns + `
+		FROM ` + eventTables + `
+		WHERE e.organization_id = ? AND e.datetime_start >= datetime('now')
+		ORDER BY e.datetime_start ASC, e.id ASC
 		LIMIT ?
 	`
 	return db.queryEvents(query, orgID, limit)
@@ -113,31 +223,60 @@ func (db *DB) GetUpcomingEventsByOrganization(orgID int, limit int) ([]*Event, e
 
 func (db *DB) GetAllUpcomingEventsByOrganization(orgID int) ([]*Event, error) {
 	query := `
-		SELECT id, organization_id, title, description, datetime_start, datetime_end,
-		       url, location, scraper, created_at, updated_at
-		FROM events
-		WHERE organization_id = ? AND datetime_start >= datetime('now')
-		ORDER BY datetime_start ASC
+		SELECT ` + eventColumns + `
+		FROM ` + eventTables + `
+		WHERE e.organization_id = ? AND e.datetime_start >= datetime('now')
+		ORDER BY e.datetime_start ASC, e.id ASC
 	`
 	return db.queryEvents(query, orgID)
 }
 
+// UpsertEvent stores a scraped event by its source ID. It returns
+// ErrDuplicateURL if the event is new but another event, e.g. a manual one,
+// has its URL, in which case nothing is stored.
 func (db *DB) UpsertEvent(event *Event) error {
 	query := `
 		INSERT INTO events (
 			organization_id, title, description, datetime_start, datetime_end,
-			url, location, scraper
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(url) DO UPDATE SET
+			url, location, activity, campaign, scraper, all_day, latitude, longitude, source_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(source_id) DO UPDATE SET
 			title = excluded.title,
 			description = excluded.description,
 			datetime_start = excluded.datetime_start,
 			datetime_end = excluded.datetime_end,
 			location = excluded.location,
+			activity = excluded.activity,
+			campaign = excluded.campaign,
 			scraper = excluded.scraper,
-			updated_at = CURRENT_TIMESTAMP
+			all_day = excluded.all_day,
+			latitude = excluded.latitude,
+			longitude = excluded.longitude,
+			sequence = events.sequence + (
+				events.title IS NOT excluded.title OR
+				events.datetime_start IS NOT excluded.datetime_start OR
+				events.datetime_end IS NOT excluded.datetime_end OR
+				events.location IS NOT excluded.location OR
+				events.all_day IS NOT excluded.all_day
+			),
+			url = excluded.url,
+			updated_at = CASE WHEN
+				events.title IS NOT excluded.title OR
+				events.description IS NOT excluded.description OR
+				events.datetime_start IS NOT excluded.datetime_start OR
+				events.datetime_end IS NOT excluded.datetime_end OR
+				events.location IS NOT excluded.location OR
+				events.activity IS NOT excluded.activity OR
+				events.campaign IS NOT excluded.campaign OR
+				events.all_day IS NOT excluded.all_day OR
+				events.latitude IS NOT excluded.latitude OR
+				events.longitude IS NOT excluded.longitude OR
+				events.url IS NOT excluded.url
+			THEN CURRENT_TIMESTAMP ELSE events.updated_at END
+		WHERE events.scraper != 'manual'
+		ON CONFLICT(url) DO NOTHING
 	`
-	_, err := db.Exec(
+	result, err := db.Exec(
 		query,
 		event.OrganizationID,
 		event.Title,
@@ -146,14 +285,98 @@ func (db *DB) UpsertEvent(event *Event) error {
 		event.DatetimeEnd,
 		event.URL,
 		event.Location,
+		event.Activity,
+		event.Campaign,
 		event.Scraper,
+		event.AllDay,
+		event.Latitude,
+		event.Longitude,
+		event.SourceID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to upsert event: %w", err)
 	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrDuplicateURL
+	}
+	return nil
+}
+
+func (db *DB) UpdateEvent(event *Event) error {
+	query := `
+		UPDATE events SET
+			title = ?, description = ?, datetime_start = ?, datetime_end = ?,
+			url = ?, location = ?, all_day = ?, sequence = sequence + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
+	_, err := db.Exec(
+		query,
+		event.Title,
+		event.Description,
+		event.DatetimeStart,
+		event.DatetimeEnd,
+		event.URL,
+		event.Location,
+		event.AllDay,
+		event.ID,
+	)
+	if err != nil {
+		if isUniqueViolation(err, "events.url") {
+			return ErrDuplicateURL
+		}
+		return fmt.Errorf("failed to update event: %w", err)
+	}
 	return nil
 }
 
+func (db *DB) DeleteEvent(id int) error {
+	if err := db.DeleteEventOverride(id); err != nil {
+		return err
+	}
+
+	query := `DELETE FROM events WHERE id = ?`
+	_, err := db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete event: %w", err)
+	}
+	return nil
+}
+
+// DeleteStaleEvents removes upcoming events of a scraper that are no longer
+// returned by the source, along with their overrides.
+func (db *DB) DeleteStaleEvents(orgID int, scraper string, sourceIDs []string, from time.Time) (int, error) {
+	condition := `organization_id = ? AND scraper = ? AND datetime_start >= ?`
+	args := []interface{}{orgID, scraper, from}
+	if len(sourceIDs) > 0 {
+		condition += ` AND (source_id IS NULL OR source_id NOT IN (?` + strings.Repeat(", ?", len(sourceIDs)-1) + `))`
+		for _, id := range sourceIDs {
+			args = append(args, id)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM event_overrides WHERE event_id IN (SELECT id FROM events WHERE `+condition+`)`, args...); err != nil {
+		return 0, fmt.Errorf("failed to delete stale overrides: %w", err)
+	}
+
+	result, err := tx.Exec(`DELETE FROM events WHERE `+condition, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete stale events: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	deleted, _ := result.RowsAffected()
+	return int(deleted), nil
+}
+
 func (db *DB) DeleteOldEvents(before time.Time) error {
 	query := `DELETE FROM events WHERE datetime_start < ?`
 	_, err := db.Exec(query, before)
@@ -172,24 +395,66 @@ func (db *DB) queryEvents(query string, args ...interface{}) ([]*Event, error) {
 
 	var events []*Event
 	for rows.Next() {
-		var event Event
-		if err := rows.Scan(
-			&event.ID,
-			&event.OrganizationID,
-			&event.Title,
-			&event.Description,
-			&event.DatetimeStart,
-			&event.DatetimeEnd,
-			&event.URL,
-			&event.Location,
-			&event.Scraper,
-			&event.CreatedAt,
-			&event.UpdatedAt,
-		); err != nil {
+		event, err := scanEvent(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan event: %w", err)
 		}
-		events = append(events, &event)
+		events = append(events, event)
 	}
 
 	return events, nil
 }
+
+func scanEvent(row rowScanner) (*Event, error) {
+	var event Event
+	var override EventOverride
+	var overrideID sql.NullInt64
+	var overrideUpdatedAt sql.NullTime
+
+	if err := row.Scan(
+		&event.ID,
+		&event.OrganizationID,
+		&event.Title,
+		&event.Description,
+		&event.DatetimeStart,
+		&event.DatetimeEnd,
+		&event.URL,
+		&event.Location,
+		&event.Activity,
+		&event.Campaign,
+		&event.Scraper,
+		&event.AllDay,
+		&event.Latitude,
+		&event.Longitude,
+		&event.Sequence,
+		&event.SourceID,
+		&event.CreatedAt,
+		&event.UpdatedAt,
+		&overrideID,
+		&override.Title,
+		&override.Description,
+		&override.Location,
+		&override.Hidden,
+		&override.Highlight,
+		&overrideUpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if overrideID.Valid {
+		override.EventID = int(overrideID.Int64)
+		override.UpdatedAt = overrideUpdatedAt.Time
+		event.applyOverride(&override)
+	}
+
+	return &event, nil
+}
+
+// isUniqueViolation reports whether err violates the unique constraint of a
+// column like "events.url".
+func isUniqueViolation(err error, column string) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) &&
+		sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique &&
+		strings.HasSuffix(sqliteErr.Error(), column)
+}
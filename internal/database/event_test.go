@@ -0,0 +1,153 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	db, err := New(filepath.Join(t.TempDir(), "calendar.db"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	return db
+}
+
+func TestCreateEventDuplicateURL(t *testing.T) {
+	db := newTestDB(t)
+
+	newEvent := func(sourceID string) *Event {
+		return &Event{
+			OrganizationID: 1,
+			Title:          "Mitgliederversammlung",
+			DatetimeStart:  time.Date(2025, 3, 1, 19, 0, 0, 0, time.UTC),
+			URL:            "https://example.org/mv",
+			Scraper:        ScraperManual,
+			SourceID:       sql.NullString{String: sourceID, Valid: true},
+		}
+	}
+
+	if err := db.CreateEvent(newEvent(ManualSourceID("a"))); err != nil {
+		t.Fatalf("CreateEvent: %v", err)
+	}
+	if err := db.CreateEvent(newEvent(ManualSourceID("b"))); !errors.Is(err, ErrDuplicateURL) {
+		t.Fatalf("CreateEvent with the same URL returned %v, want ErrDuplicateURL", err)
+	}
+
+	other := newEvent(ManualSourceID("c"))
+	other.URL = "manual:c"
+	if err := db.CreateEvent(other); err != nil {
+		t.Fatalf("CreateEvent: %v", err)
+	}
+	other.URL = "https://example.org/mv"
+	if err := db.UpdateEvent(other); !errors.Is(err, ErrDuplicateURL) {
+		t.Fatalf("UpdateEvent with the same URL returned %v, want ErrDuplicateURL", err)
+	}
+}
+
+func TestWallClock(t *testing.T) {
+	tests := []struct {
+		name string
+		in   time.Time
+		want time.Time
+	}{
+		{"winter", time.Date(2025, 1, 15, 17, 30, 0, 0, time.UTC), time.Date(2025, 1, 15, 18, 30, 0, 0, time.UTC)},
+		{"summer", time.Date(2025, 7, 1, 22, 30, 0, 0, time.UTC), time.Date(2025, 7, 2, 0, 30, 0, 0, time.UTC)},
+		{"other zone", time.Date(2025, 7, 1, 12, 0, 0, 0, time.FixedZone("EDT", -4*3600)), time.Date(2025, 7, 1, 18, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WallClock(tt.in); !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Errorf("WallClock(%s) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteStaleEventsWallClock(t *testing.T) {
+	db := newTestDB(t)
+
+	// Stored as Berlin wall clock: starts at 19:00 Berlin, 17:00 UTC.
+	event := &Event{
+		OrganizationID: 1,
+		Title:          "Infostand",
+		DatetimeStart:  time.Date(2025, 7, 1, 19, 0, 0, 0, time.UTC),
+		URL:            "https://example.org/infostand",
+		Scraper:        ScraperZetkin,
+		SourceID:       sql.NullString{String: ZetkinSourceID(1), Valid: true},
+	}
+	if err := db.CreateEvent(event); err != nil {
+		t.Fatalf("CreateEvent: %v", err)
+	}
+
+	// At 18:00 Berlin the event has not started and must not be touched.
+	if deleted, err := db.DeleteStaleEvents(1, ScraperZetkin, []string{ZetkinSourceID(2)}, WallClock(time.Date(2025, 7, 1, 16, 0, 0, 0, time.UTC))); err != nil || deleted != 1 {
+		t.Fatalf("DeleteStaleEvents before the start = %d, %v, want 1", deleted, err)
+	}
+
+	if err := db.CreateEvent(event); err != nil {
+		t.Fatalf("CreateEvent: %v", err)
+	}
+	// At 20:00 Berlin (18:00 UTC) it has started and is kept as history.
+	if deleted, err := db.DeleteStaleEvents(1, ScraperZetkin, []string{ZetkinSourceID(2)}, WallClock(time.Date(2025, 7, 1, 18, 0, 0, 0, time.UTC))); err != nil || deleted != 0 {
+		t.Fatalf("DeleteStaleEvents after the start = %d, %v, want 0", deleted, err)
+	}
+
+	found, err := db.GetEventBySourceID(ZetkinSourceID(1))
+	if err != nil || found.Title != "Infostand" {
+		t.Fatalf("GetEventBySourceID = %v, %v", found, err)
+	}
+}
+
+func TestUpsertEventDuplicateURL(t *testing.T) {
+	db := newTestDB(t)
+
+	manual := &Event{
+		OrganizationID: 1,
+		Title:          "Infostand",
+		DatetimeStart:  time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC),
+		URL:            "https://app.zetkin.die-linke.de/o/1/events/7",
+		Scraper:        ScraperManual,
+		SourceID:       sql.NullString{String: ManualSourceID("a"), Valid: true},
+	}
+	if err := db.CreateEvent(manual); err != nil {
+		t.Fatalf("CreateEvent: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		want error
+	}{
+		{"link of a manual event", manual.URL, ErrDuplicateURL},
+		{"own link", "https://app.zetkin.die-linke.de/o/1/events/8", nil},
+		{"unchanged", "https://app.zetkin.die-linke.de/o/1/events/8", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scraped := &Event{
+				OrganizationID: 1,
+				Title:          "Infostand",
+				DatetimeStart:  manual.DatetimeStart,
+				URL:            tt.url,
+				Scraper:        ScraperZetkin,
+				SourceID:       sql.NullString{String: ZetkinSourceID(8), Valid: true},
+			}
+			if err := db.UpsertEvent(scraped); !errors.Is(err, tt.want) {
+				t.Errorf("UpsertEvent() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -3,16 +3,47 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Outcomes of a scrape, see RecordScrapeResult.
+const (
+	ScrapeStatusOK    = "ok"
+	ScrapeStatusError = "error"
 )
 
+// scrapeErrorsKept is the number of errors kept per organization.
+const scrapeErrorsKept = 20
+
 type Organization struct {
-	ID          int
-	Title       sql.NullString
-	LastScraped sql.NullTime
-	CreatedAt   time.Time
+	ID           int
+	Title        sql.NullString
+	LastScraped  sql.NullTime
+	ScrapeStatus sql.NullString
+	// Paused organizations are skipped by the scheduled scrapes.
+	Paused    bool
+	CreatedAt time.Time
 }
 
+type ScrapeError struct {
+	ID             int
+	OrganizationID int
+	Message        string
+	CreatedAt      time.Time
+}
+
+// EventCounts are the numbers of stored events of an organization.
+type EventCounts struct {
+	Total    int
+	Upcoming int
+	Manual   int
+}
+
+const organizationColumns = `id, title, last_scraped, scrape_status, paused, created_at`
+
 func (db *DB) CreateOrganization(org *Organization) error {
 	query := `INSERT INTO organizations (id, title) VALUES (?, ?)`
 	_, err := db.Exec(query, org.ID, org.Title)
@@ -23,22 +54,16 @@ func (db *DB) CreateOrganization(org *Organization) error {
 }
 
 func (db *DB) GetOrganization(id int) (*Organization, error) {
-	query := `SELECT id, title, last_scraped, created_at FROM organizations WHERE id = ?`
-	var org Organization
-	err := db.QueryRow(query, id).Scan(
-		&org.ID,
-		&org.Title,
-		&org.LastScraped,
-		&org.CreatedAt,
-	)
+	query := `SELECT ` + organizationColumns + ` FROM organizations WHERE id = ?`
+	org, err := scanOrganization(db.QueryRow(query, id))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get organization: %w", err)
 	}
-	return &org, nil
+	return org, nil
 }
 
 func (db *DB) GetAllOrganizations() ([]*Organization, error) {
-	query := `SELECT id, title, last_scraped, created_at FROM organizations ORDER BY id`
+	query := `SELECT ` + organizationColumns + ` FROM organizations ORDER BY id`
 	rows, err := db.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get organizations: %w", err)
@@ -47,21 +72,31 @@ func (db *DB) GetAllOrganizations() ([]*Organization, error) {
 
 	var orgs []*Organization
 	for rows.Next() {
-		var org Organization
-		if err := rows.Scan(
-			&org.ID,
-			&org.Title,
-			&org.LastScraped,
-			&org.CreatedAt,
-		); err != nil {
+		org, err := scanOrganization(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan organization: %w", err)
 		}
-		orgs = append(orgs, &org)
+		orgs = append(orgs, org)
 	}
 
 	return orgs, nil
 }
 
+func scanOrganization(row rowScanner) (*Organization, error) {
+	var org Organization
+	if err := row.Scan(
+		&org.ID,
+		&org.Title,
+		&org.LastScraped,
+		&org.ScrapeStatus,
+		&org.Paused,
+		&org.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
 func (db *DB) GetDistinctOrganizationsFromEvents() ([]int, error) {
 	query := `SELECT DISTINCT organization_id FROM events ORDER BY organization_id`
 	rows, err := db.Query(query)
@@ -96,7 +131,7 @@ func (db *DB) UpsertOrganization(org *Organization) error {
 		INSERT INTO organizations (id, title)
 		VALUES (?, ?)
 		ON CONFLICT(id) DO UPDATE SET
-			title = excluded.title,
+			title = COALESCE(excluded.title, organizations.title),
 			last_scraped = COALESCE(organizations.last_scraped, excluded.last_scraped)
 	`
 	_, err := db.Exec(query, org.ID, org.Title)
@@ -106,6 +141,147 @@ func (db *DB) UpsertOrganization(org *Organization) error {
 	return nil
 }
 
+// RecordScrapeResult stores the outcome of a scrape. Errors are appended to
+// the log of the organization, which keeps the most recent ones.
+func (db *DB) RecordScrapeResult(orgID int, errs []string) error {
+	status := ScrapeStatusOK
+	if len(errs) > 0 {
+		status = ScrapeStatusError
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE organizations SET scrape_status = ? WHERE id = ?`, status, orgID); err != nil {
+		return fmt.Errorf("failed to update scrape status: %w", err)
+	}
+
+	for _, message := range errs {
+		if _, err := tx.Exec(`INSERT INTO scrape_errors (organization_id, message) VALUES (?, ?)`, orgID, message); err != nil {
+			return fmt.Errorf("failed to insert scrape error: %w", err)
+		}
+	}
+
+	if len(errs) > 0 {
+		query := `
+			DELETE FROM scrape_errors
+			WHERE organization_id = ? AND id NOT IN (
+				SELECT id FROM scrape_errors WHERE organization_id = ? ORDER BY id DESC LIMIT ?
+			)
+		`
+		if _, err := tx.Exec(query, orgID, orgID, scrapeErrorsKept); err != nil {
+			return fmt.Errorf("failed to prune scrape errors: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetRecentScrapeErrors returns the latest scrape errors of all
+// organizations, newest first.
+func (db *DB) GetRecentScrapeErrors(limit int) ([]*ScrapeError, error) {
+	query := `SELECT id, organization_id, message, created_at FROM scrape_errors ORDER BY id DESC LIMIT ?`
+	rows, err := db.Query(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scrape errors: %w", err)
+	}
+	defer rows.Close()
+
+	var errs []*ScrapeError
+	for rows.Next() {
+		var e ScrapeError
+		if err := rows.Scan(&e.ID, &e.OrganizationID, &e.Message, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan scrape error: %w", err)
+		}
+		errs = append(errs, &e)
+	}
+
+	return errs, nil
+}
+
+func (db *DB) SetOrganizationPaused(id int, paused bool) error {
+	result, err := db.Exec(`UPDATE organizations SET paused = ? WHERE id = ?`, paused, id)
+	if err != nil {
+		return fmt.Errorf("failed to update organization: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteOrganization removes an organization with its events, overrides,
+// rules, submissions, scrape errors and runs, members and API tokens. Only a
+// paused row without title is kept, so visiting its pages does not scrape
+// it again until it is resumed.
+func (db *DB) DeleteOrganization(id int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`DELETE FROM event_overrides WHERE event_id IN (SELECT id FROM events WHERE organization_id = ?)`,
+		`DELETE FROM events WHERE organization_id = ?`,
+		`DELETE FROM rules WHERE organization_id = ?`,
+		`DELETE FROM submissions WHERE organization_id = ?`,
+		`DELETE FROM scrape_errors WHERE organization_id = ?`,
+		`DELETE FROM organization_themes WHERE organization_id = ?`,
+		`DELETE FROM memberships WHERE organization_id = ?`,
+		`DELETE FROM invites WHERE organization_id = ?`,
+		`DELETE FROM api_tokens WHERE organization_id = ?`,
+		`DELETE FROM scrape_runs WHERE organization_id = ?`,
+		`INSERT INTO organizations (id, paused) VALUES (?, 1)
+			ON CONFLICT(id) DO UPDATE SET title = NULL, last_scraped = NULL, scrape_status = NULL, paused = 1`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement, id); err != nil {
+			return fmt.Errorf("failed to delete organization: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetEventCounts counts the events of every organization. Upcoming events
+// start at or after now.
+func (db *DB) GetEventCounts(now time.Time) (map[int]EventCounts, error) {
+	query := `
+		SELECT organization_id, COUNT(*),
+		       COALESCE(SUM(datetime_start >= ?), 0),
+		       COALESCE(SUM(scraper = 'manual'), 0)
+		FROM events
+		GROUP BY organization_id
+	`
+	rows, err := db.Query(query, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count events: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int]EventCounts)
+	for rows.Next() {
+		var orgID int
+		var c EventCounts
+		if err := rows.Scan(&orgID, &c.Total, &c.Upcoming, &c.Manual); err != nil {
+			return nil, fmt.Errorf("failed to scan event counts: %w", err)
+		}
+		counts[orgID] = c
+	}
+
+	return counts, nil
+}
+
 func (db *DB) HasEventsForOrganization(orgID int) (bool, error) {
 	query := `SELECT COUNT(*) FROM events WHERE organization_id = ?`
 	var count int
@@ -115,3 +291,64 @@ func (db *DB) HasEventsForOrganization(orgID int) (bool, error) {
 	}
 	return count > 0, nil
 }
+
+// OrganizationVersion summarizes everything that affects the public output
+// of an organization. Key changes whenever events, overrides, rules, the
+// theme or the organization itself change.
+type OrganizationVersion struct {
+	Key          string
+	LastModified time.Time
+}
+
+func (db *DB) GetOrganizationVersion(orgID int) (*OrganizationVersion, error) {
+	query := `
+		SELECT
+			(SELECT MAX(updated_at) FROM events WHERE organization_id = ?),
+			(SELECT COUNT(*) FROM events WHERE organization_id = ?),
+			(SELECT MAX(o.updated_at) FROM event_overrides o JOIN events e ON e.id = o.event_id WHERE e.organization_id = ?),
+			(SELECT COUNT(*) FROM event_overrides o JOIN events e ON e.id = o.event_id WHERE e.organization_id = ?),
+			(SELECT COALESCE(MAX(id), 0) FROM rules WHERE organization_id = ?),
+			(SELECT COUNT(*) FROM rules WHERE organization_id = ?),
+			(SELECT last_scraped FROM organizations WHERE id = ?),
+			(SELECT updated_at FROM organization_themes WHERE organization_id = ?)
+	`
+	var eventsUpdated, overridesUpdated, lastScraped, themeUpdated sql.NullString
+	var eventCount, overrideCount, maxRuleID, ruleCount int
+	err := db.QueryRow(query, orgID, orgID, orgID, orgID, orgID, orgID, orgID, orgID).Scan(
+		&eventsUpdated,
+		&eventCount,
+		&overridesUpdated,
+		&overrideCount,
+		&maxRuleID,
+		&ruleCount,
+		&lastScraped,
+		&themeUpdated,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization version: %w", err)
+	}
+
+	version := &OrganizationVersion{
+		Key: fmt.Sprintf("%s|%d|%s|%d|%d|%d|%s|%s",
+			eventsUpdated.String, eventCount, overridesUpdated.String, overrideCount, maxRuleID, ruleCount, lastScraped.String,
+			themeUpdated.String),
+	}
+	for _, value := range []sql.NullString{eventsUpdated, overridesUpdated, lastScraped, themeUpdated} {
+		if t, ok := parseTimestamp(value.String); ok && t.After(version.LastModified) {
+			version.LastModified = t
+		}
+	}
+	return version, nil
+}
+
+// parseTimestamp parses timestamps read without column type information,
+// e.g. from aggregates, in the formats the SQLite driver writes.
+func parseTimestamp(value string) (time.Time, bool) {
+	value = strings.TrimSuffix(value, "Z")
+	for _, format := range sqlite3.SQLiteTimestampFormats {
+		if t, err := time.ParseInLocation(format, value, time.UTC); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestUpsertOrganizationKeepsTitle(t *testing.T) {
+	db := newTestDB(t)
+
+	for _, title := range []sql.NullString{{String: "Die Linke Berlin", Valid: true}, {}} {
+		if err := db.UpsertOrganization(&Organization{ID: 1, Title: title}); err != nil {
+			t.Fatalf("UpsertOrganization: %v", err)
+		}
+	}
+
+	org, err := db.GetOrganization(1)
+	if err != nil {
+		t.Fatalf("GetOrganization: %v", err)
+	}
+	if org.Title.String != "Die Linke Berlin" {
+		t.Errorf("title = %v, want the stored one", org.Title)
+	}
+}
+
+func TestDeleteOrganizationStaysPaused(t *testing.T) {
+	db := newTestDB(t)
+
+	if err := db.UpsertOrganization(&Organization{ID: 1, Title: sql.NullString{String: "Die Linke Berlin", Valid: true}}); err != nil {
+		t.Fatalf("UpsertOrganization: %v", err)
+	}
+	if err := db.UpdateOrganizationLastScraped(1, time.Now()); err != nil {
+		t.Fatalf("UpdateOrganizationLastScraped: %v", err)
+	}
+	event := &Event{
+		OrganizationID: 1,
+		Title:          "Infostand",
+		DatetimeStart:  time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC),
+		URL:            "https://example.org/infostand",
+		Scraper:        ScraperManual,
+		SourceID:       sql.NullString{String: ManualSourceID("infostand"), Valid: true},
+	}
+	if err := db.CreateEvent(event); err != nil {
+		t.Fatalf("CreateEvent: %v", err)
+	}
+
+	// An organization without a row gets one as well.
+	for _, id := range []int{1, 2} {
+		if err := db.DeleteOrganization(id); err != nil {
+			t.Fatalf("DeleteOrganization(%d): %v", id, err)
+		}
+
+		org, err := db.GetOrganization(id)
+		if err != nil {
+			t.Fatalf("GetOrganization(%d): %v", id, err)
+		}
+		if !org.Paused || org.Title.Valid || org.LastScraped.Valid {
+			t.Errorf("organization %d = paused %v, title %v, last scraped %v, want paused without data", id, org.Paused, org.Title, org.LastScraped)
+		}
+	}
+
+	if hasEvents, err := db.HasEventsForOrganization(1); err != nil || hasEvents {
+		t.Errorf("HasEventsForOrganization() = %v, %v, want no events", hasEvents, err)
+	}
+}
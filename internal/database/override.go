@@ -0,0 +1,69 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// EventOverride stores public-facing edits to a scraped event. It lives in
+// its own table so re-scrapes never touch it.
+type EventOverride struct {
+	EventID     int
+	Title       sql.NullString
+	Description sql.NullString
+	Location    sql.NullString
+	Hidden      bool
+	Highlight   bool
+	UpdatedAt   time.Time
+}
+
+func (o *EventOverride) IsEmpty() bool {
+	return !o.Title.Valid && !o.Description.Valid && !o.Location.Valid && !o.Hidden && !o.Highlight
+}
+
+func (e *Event) applyOverride(o *EventOverride) {
+	original := *e
+	e.Original = &original
+	e.Override = o
+
+	if o.Title.Valid {
+		e.Title = o.Title.String
+	}
+	if o.Description.Valid {
+		e.Description = o.Description
+	}
+	if o.Location.Valid {
+		e.Location = o.Location
+	}
+	e.Hidden = o.Hidden
+	e.Highlight = o.Highlight
+}
+
+func (db *DB) UpsertEventOverride(o *EventOverride) error {
+	query := `
+		INSERT INTO event_overrides (event_id, title, description, location, hidden, highlight)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(event_id) DO UPDATE SET
+			title = excluded.title,
+			description = excluded.description,
+			location = excluded.location,
+			hidden = excluded.hidden,
+			highlight = excluded.highlight,
+			updated_at = CURRENT_TIMESTAMP
+	`
+	_, err := db.Exec(query, o.EventID, o.Title, o.Description, o.Location, o.Hidden, o.Highlight)
+	if err != nil {
+		return fmt.Errorf("failed to upsert event override: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) DeleteEventOverride(eventID int) error {
+	query := `DELETE FROM event_overrides WHERE event_id = ?`
+	_, err := db.Exec(query, eventID)
+	if err != nil {
+		return fmt.Errorf("failed to delete event override: %w", err)
+	}
+	return nil
+}
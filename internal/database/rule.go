@@ -0,0 +1,91 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	RuleFieldTitle    = "title"
+	RuleFieldActivity = "activity"
+	RuleFieldLocation = "location"
+	RuleFieldAny      = "any"
+
+	RuleActionHide         = "hide"
+	RuleActionStripContact = "strip_contact"
+)
+
+// Rule hides or redacts the events of an organization whose field matches
+// Pattern. Keywords is a comma or newline separated list matched
+// case-insensitively, Regex a regular expression. A rule without pattern
+// applies to all events.
+type Rule struct {
+	ID             int
+	OrganizationID int
+	Field          string
+	Pattern        string
+	Regex          bool
+	Action         string
+	CreatedAt      time.Time
+}
+
+func (db *DB) CreateRule(rule *Rule) error {
+	query := `
+		INSERT INTO rules (organization_id, field, pattern, regex, action)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	result, err := db.Exec(query, rule.OrganizationID, rule.Field, rule.Pattern, rule.Regex, rule.Action)
+	if err != nil {
+		return fmt.Errorf("failed to create rule: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	rule.ID = int(id)
+	return nil
+}
+
+func (db *DB) GetRulesByOrganization(orgID int) ([]*Rule, error) {
+	query := `
+		SELECT id, organization_id, field, pattern, regex, action, created_at
+		FROM rules
+		WHERE organization_id = ?
+		ORDER BY id
+	`
+	rows, err := db.Query(query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*Rule
+	for rows.Next() {
+		var rule Rule
+		if err := rows.Scan(
+			&rule.ID,
+			&rule.OrganizationID,
+			&rule.Field,
+			&rule.Pattern,
+			&rule.Regex,
+			&rule.Action,
+			&rule.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan rule: %w", err)
+		}
+		rules = append(rules, &rule)
+	}
+
+	return rules, nil
+}
+
+func (db *DB) DeleteRule(orgID, id int) error {
+	query := `DELETE FROM rules WHERE organization_id = ? AND id = ?`
+	_, err := db.Exec(query, orgID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete rule: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// States of a queued scrape. Finished runs use ScrapeStatusOK or
+// ScrapeStatusError.
+const (
+	RunStatusQueued  = "queued"
+	RunStatusRunning = "running"
+)
+
+// scrapeRunsKept is the number of finished runs kept per organization.
+const scrapeRunsKept = 20
+
+// ScrapeRun is a scrape requested outside the schedule, e.g. by an API
+// token. TokenID is not set for runs of the admin area.
+type ScrapeRun struct {
+	ID             int
+	OrganizationID int
+	TokenID        sql.NullInt64
+	Status         string
+	Error          sql.NullString
+	QueuedAt       time.Time
+	StartedAt      sql.NullTime
+	FinishedAt     sql.NullTime
+}
+
+const scrapeRunColumns = `id, organization_id, token_id, status, error, queued_at, started_at, finished_at`
+
+func (db *DB) CreateScrapeRun(run *ScrapeRun) error {
+	query := `INSERT INTO scrape_runs (organization_id, token_id, status, queued_at) VALUES (?, ?, ?, ?)`
+	result, err := db.Exec(query, run.OrganizationID, run.TokenID, run.Status, run.QueuedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create scrape run: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	run.ID = int(id)
+	return nil
+}
+
+// GetScrapeRun returns a run of an organization.
+func (db *DB) GetScrapeRun(id, orgID int) (*ScrapeRun, error) {
+	query := `SELECT ` + scrapeRunColumns + ` FROM scrape_runs WHERE id = ? AND organization_id = ?`
+	run, err := scanScrapeRun(db.QueryRow(query, id, orgID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scrape run: %w", err)
+	}
+	return run, nil
+}
+
+// GetQueuedScrapeRun returns the run of an organization that waits to be
+// started, or sql.ErrNoRows.
+func (db *DB) GetQueuedScrapeRun(orgID int) (*ScrapeRun, error) {
+	query := `SELECT ` + scrapeRunColumns + ` FROM scrape_runs WHERE organization_id = ? AND status = ? ORDER BY id LIMIT 1`
+	run, err := scanScrapeRun(db.QueryRow(query, orgID, RunStatusQueued))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scrape run: %w", err)
+	}
+	return run, nil
+}
+
+// GetScrapeRuns returns the latest runs of an organization, newest first.
+func (db *DB) GetScrapeRuns(orgID, limit int) ([]*ScrapeRun, error) {
+	query := `SELECT ` + scrapeRunColumns + ` FROM scrape_runs WHERE organization_id = ? ORDER BY id DESC LIMIT ?`
+	rows, err := db.Query(query, orgID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scrape runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*ScrapeRun
+	for rows.Next() {
+		run, err := scanScrapeRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan scrape run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+func scanScrapeRun(row rowScanner) (*ScrapeRun, error) {
+	var run ScrapeRun
+	if err := row.Scan(
+		&run.ID,
+		&run.OrganizationID,
+		&run.TokenID,
+		&run.Status,
+		&run.Error,
+		&run.QueuedAt,
+		&run.StartedAt,
+		&run.FinishedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (db *DB) StartScrapeRun(id int, now time.Time) error {
+	query := `UPDATE scrape_runs SET status = ?, started_at = ? WHERE id = ?`
+	if _, err := db.Exec(query, RunStatusRunning, now, id); err != nil {
+		return fmt.Errorf("failed to start scrape run: %w", err)
+	}
+	return nil
+}
+
+// FinishScrapeRun stores the outcome of a run and drops the oldest finished
+// runs of its organization.
+func (db *DB) FinishScrapeRun(run *ScrapeRun, errs []string, now time.Time) error {
+	status := ScrapeStatusOK
+	var message sql.NullString
+	if len(errs) > 0 {
+		status = ScrapeStatusError
+		message = sql.NullString{String: strings.Join(errs, "\n"), Valid: true}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE scrape_runs SET status = ?, error = ?, finished_at = ? WHERE id = ?`
+	if _, err := tx.Exec(query, status, message, now, run.ID); err != nil {
+		return fmt.Errorf("failed to finish scrape run: %w", err)
+	}
+
+	query = `
+		DELETE FROM scrape_runs
+		WHERE organization_id = ? AND finished_at IS NOT NULL AND id NOT IN (
+			SELECT id FROM scrape_runs WHERE organization_id = ? AND finished_at IS NOT NULL ORDER BY id DESC LIMIT ?
+		)
+	`
+	if _, err := tx.Exec(query, run.OrganizationID, run.OrganizationID, scrapeRunsKept); err != nil {
+		return fmt.Errorf("failed to prune scrape runs: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	run.Status = status
+	run.Error = message
+	run.FinishedAt = sql.NullTime{Time: now, Valid: true}
+	return nil
+}
+
+// AbortScrapeRuns fails all runs that are still queued or running, e.g. left
+// over from before a restart.
+func (db *DB) AbortScrapeRuns(message string, now time.Time) error {
+	query := `UPDATE scrape_runs SET status = ?, error = ?, finished_at = ? WHERE finished_at IS NULL`
+	if _, err := db.Exec(query, ScrapeStatusError, message, now); err != nil {
+		return fmt.Errorf("failed to abort scrape runs: %w", err)
+	}
+	return nil
+}
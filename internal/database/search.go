@@ -0,0 +1,355 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+)
+
+// Matches of a search are enclosed in these markers, which never occur in
+// event texts, so they can be turned into HTML after escaping.
+const (
+	HighlightStart = "\x02"
+	HighlightEnd   = "\x03"
+)
+
+const (
+	searchMaxTerms    = 8
+	searchSnippetSize = 160
+)
+
+// SearchHit is an event matching a search, with the matches in title,
+// description and location enclosed in highlight markers. Snippet is an
+// excerpt of the description around the first match.
+type SearchHit struct {
+	EventID  int
+	Title    string
+	Snippet  string
+	Location string
+}
+
+// searchDocument is the indexed text of an event, with overrides applied.
+// The tokenizer keeps ß, so it is replaced by ss like in foldText.
+var searchDocument = `
+	SELECT e.id, ` + foldSharpS(`COALESCE(o.title, e.title)`) + `,
+	       ` + foldSharpS(`COALESCE(o.description, e.description, '')`) + `,
+	       ` + foldSharpS(`COALESCE(o.location, e.location, '')`) + `, e.organization_id
+	FROM ` + eventTables
+
+func foldSharpS(column string) string {
+	return `replace(replace(` + column + `, 'ß', 'ss'), 'ẞ', 'ss')`
+}
+
+// initializeSearch creates the full-text index with triggers keeping it in
+// sync with events and overrides, so every write like UpsertEvent updates
+// it. Without FTS5 events are matched in memory.
+func (db *DB) initializeSearch() error {
+	_, err := db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS events_fts USING fts5(
+		title, description, location, organization_id UNINDEXED,
+		tokenize = 'unicode61 remove_diacritics 2'
+	)`)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such module") {
+			log.Printf("SQLite was built without FTS5, search matches events in memory")
+			return nil
+		}
+		return err
+	}
+
+	reindex := func(id string) string {
+		return `DELETE FROM events_fts WHERE rowid = ` + id + `;
+			INSERT INTO events_fts (rowid, title, description, location, organization_id)
+			` + searchDocument + ` WHERE e.id = ` + id + `;`
+	}
+
+	triggers := map[string]string{
+		"events_fts_insert": `AFTER INSERT ON events BEGIN ` + reindex("new.id") + ` END`,
+		"events_fts_update": `AFTER UPDATE ON events BEGIN ` + reindex("new.id") + ` END`,
+		"events_fts_delete": `AFTER DELETE ON events BEGIN
+			DELETE FROM events_fts WHERE rowid = old.id;
+		END`,
+		"event_overrides_fts_insert": `AFTER INSERT ON event_overrides BEGIN ` + reindex("new.event_id") + ` END`,
+		"event_overrides_fts_update": `AFTER UPDATE ON event_overrides BEGIN ` + reindex("new.event_id") + ` END`,
+		"event_overrides_fts_delete": `AFTER DELETE ON event_overrides BEGIN ` + reindex("old.event_id") + ` END`,
+	}
+	// The triggers are recreated, so they index the current searchDocument.
+	for name, trigger := range triggers {
+		if _, err := db.Exec(`DROP TRIGGER IF EXISTS ` + name); err != nil {
+			return err
+		}
+		if _, err := db.Exec(`CREATE TRIGGER ` + name + ` ` + trigger); err != nil {
+			return err
+		}
+	}
+
+	// Rebuild the index on startup, which covers databases predating it.
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM events_fts`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO events_fts (rowid, title, description, location, organization_id) ` + searchDocument); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	db.fts = true
+	return nil
+}
+
+// SearchEvents finds the given events of an organization containing all
+// words of query. The words are reduced to their German stem and match at
+// word starts, so "Mieten" finds "Mietendemo" and "Demonstrationen" finds
+// "Demonstration". Diacritics are ignored and ß matches ss. As a stem is a
+// prefix of its word, matching at word starts also covers the stems of the
+// indexed words.
+//
+// The index holds the texts before rules are applied, so it only preselects
+// and ranks candidates. Matches and highlights come from the passed events,
+// so text removed by rules, like contact details, is never found or shown.
+func (db *DB) SearchEvents(orgID int, query string, events []*Event) ([]*SearchHit, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	if !db.fts {
+		return matchEvents(events, terms), nil
+	}
+
+	match := make([]string, len(terms))
+	for i, term := range terms {
+		match[i] = `"` + term + `"*`
+	}
+
+	rows, err := db.Query(`
+		SELECT rowid FROM events_fts
+		WHERE events_fts MATCH ? AND organization_id = ?
+		ORDER BY rank
+	`, strings.Join(match, " "), orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search events: %w", err)
+	}
+	defer rows.Close()
+
+	byID := make(map[int]*Event, len(events))
+	for _, event := range events {
+		byID[event.ID] = event
+	}
+
+	var candidates []*Event
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan search hit: %w", err)
+		}
+		if event, ok := byID[id]; ok {
+			candidates = append(candidates, event)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return matchEvents(candidates, terms), nil
+}
+
+// matchEvents keeps the events whose title, description or location contain
+// every term and highlights the matches.
+func matchEvents(events []*Event, terms []string) []*SearchHit {
+	var hits []*SearchHit
+	for _, event := range events {
+		texts := []string{event.Title, event.Description.String, event.Location.String}
+
+		found := true
+		for _, term := range terms {
+			if !containsTerm(texts, term) {
+				found = false
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+
+		hits = append(hits, &SearchHit{
+			EventID:  event.ID,
+			Title:    highlightTerms(event.Title, terms),
+			Snippet:  highlightTerms(excerpt(event.Description.String, terms), terms),
+			Location: highlightTerms(event.Location.String, terms),
+		})
+	}
+	return hits
+}
+
+func containsTerm(texts []string, term string) bool {
+	for _, text := range texts {
+		folded, _ := foldText(text)
+		if len(termOffsets(folded, []rune(term))) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// searchTerms splits a query into the stems of its folded words, dropping
+// the operators of the FTS5 query syntax.
+func searchTerms(query string) []string {
+	terms := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for i, term := range terms {
+		folded, _ := foldText(term)
+		terms[i] = string(stemGerman(folded))
+	}
+	if len(terms) > searchMaxTerms {
+		terms = terms[:searchMaxTerms]
+	}
+	return terms
+}
+
+// foldDiacritics maps letters with diacritics to their base letter, like the
+// remove_diacritics option of the FTS5 tokenizer. ß has no base letter and
+// is replaced by ss in foldText.
+var foldDiacritics = map[rune]rune{
+	'à': 'a', 'á': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a', 'å': 'a', 'ā': 'a', 'ă': 'a', 'ą': 'a',
+	'ç': 'c', 'ć': 'c', 'č': 'c', 'ď': 'd',
+	'è': 'e', 'é': 'e', 'ê': 'e', 'ë': 'e', 'ē': 'e', 'ė': 'e', 'ę': 'e', 'ě': 'e',
+	'ğ': 'g', 'ì': 'i', 'í': 'i', 'î': 'i', 'ï': 'i', 'ī': 'i', 'ı': 'i',
+	'ñ': 'n', 'ń': 'n', 'ň': 'n',
+	'ò': 'o', 'ó': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o', 'ō': 'o', 'ő': 'o',
+	'ř': 'r', 'ś': 's', 'š': 's', 'ş': 's', 'ť': 't',
+	'ù': 'u', 'ú': 'u', 'û': 'u', 'ü': 'u', 'ū': 'u', 'ů': 'u', 'ű': 'u',
+	'ý': 'y', 'ÿ': 'y', 'ź': 'z', 'ż': 'z', 'ž': 'z',
+}
+
+// foldText lowercases text, removes diacritics and replaces ß by ss. It
+// also returns the index of the rune of text each folded rune comes from.
+func foldText(text string) ([]rune, []int) {
+	folded := make([]rune, 0, len(text))
+	origins := make([]int, 0, len(text))
+	for i, r := range []rune(text) {
+		r = unicode.ToLower(r)
+		if r == 'ß' {
+			folded = append(folded, 's', 's')
+			origins = append(origins, i, i)
+			continue
+		}
+		if base, ok := foldDiacritics[r]; ok {
+			r = base
+		}
+		folded = append(folded, r)
+		origins = append(origins, i)
+	}
+	return folded, origins
+}
+
+// termOffsets returns the rune offsets where term starts a word of the
+// folded text.
+func termOffsets(folded, term []rune) []int {
+	var offsets []int
+	for offset := 0; offset < len(folded); {
+		i := indexRunes(folded[offset:], term)
+		if i < 0 {
+			break
+		}
+		start := offset + i
+		if start == 0 || !isWordRune(folded[start-1]) {
+			offsets = append(offsets, start)
+		}
+		offset = start + 1
+	}
+	return offsets
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// excerpt cuts text to the part around the first match of a term.
+func excerpt(text string, terms []string) string {
+	runes := []rune(text)
+	if len(runes) <= searchSnippetSize {
+		return text
+	}
+
+	folded, origins := foldText(text)
+	start := 0
+	for _, term := range terms {
+		if offsets := termOffsets(folded, []rune(term)); len(offsets) > 0 {
+			start = origins[offsets[0]] - searchSnippetSize/4
+			break
+		}
+	}
+	if start < 0 {
+		start = 0
+	}
+	end := start + searchSnippetSize
+	if end > len(runes) {
+		end = len(runes)
+		start = end - searchSnippetSize
+	}
+
+	result := string(runes[start:end])
+	if start > 0 {
+		result = "…" + result
+	}
+	if end < len(runes) {
+		result += "…"
+	}
+	return result
+}
+
+// highlightTerms encloses the matches of the terms in highlight markers.
+func highlightTerms(text string, terms []string) string {
+	runes := []rune(text)
+	folded, origins := foldText(text)
+
+	marked := make([]bool, len(runes))
+	for _, term := range terms {
+		needle := []rune(term)
+		for _, offset := range termOffsets(folded, needle) {
+			for j := offset; j < offset+len(needle); j++ {
+				marked[origins[j]] = true
+			}
+		}
+	}
+
+	var b strings.Builder
+	for i, r := range runes {
+		if marked[i] && (i == 0 || !marked[i-1]) {
+			b.WriteString(HighlightStart)
+		}
+		b.WriteRune(r)
+		if marked[i] && (i == len(runes)-1 || !marked[i+1]) {
+			b.WriteString(HighlightEnd)
+		}
+	}
+	return b.String()
+}
+
+func indexRunes(haystack, needle []rune) int {
+	if len(needle) == 0 {
+		return -1
+	}
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		match := true
+		for j := range needle {
+			if haystack[i+j] != needle[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{}},
+		{"Mietendemo", []string{"mietendemo"}},
+		{"  Görlitzer   Park ", []string{"gorlitz", "park"}},
+		{`"miete" OR demo*`, []string{"miet", "or", "demo"}},
+		{"NEAR(a b)", []string{"near", "a", "b"}},
+		{"Straße", []string{"strass"}},
+		{"STRASSE", []string{"strass"}},
+		{"Demonstrationen", []string{"demonstration"}},
+		{"çay şiş", []string{"cay", "sis"}},
+		{"a b c d e f g h i j", []string{"a", "b", "c", "d", "e", "f", "g", "h"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := searchTerms(tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("searchTerms(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlightTerms(t *testing.T) {
+	mark := func(s string) string {
+		s = strings.ReplaceAll(s, "[", HighlightStart)
+		return strings.ReplaceAll(s, "]", HighlightEnd)
+	}
+
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		want  string
+	}{
+		{"no match", "Infostand", []string{"demo"}, "Infostand"},
+		{"prefix", "Mietendemo am Markt", []string{"miete"}, "[Miete]ndemo am Markt"},
+		{"not inside words", "Mietendemo", []string{"demo"}, "Mietendemo"},
+		{"every occurrence", "Demo, Demo", []string{"demo"}, "[Demo], [Demo]"},
+		{"several terms", "Görlitzer Park", []string{"gorlitz", "park"}, "[Görlitz]er [Park]"},
+		{"adjacent matches merge", "Rotfront", []string{"rot", "rotf"}, "[Rotf]ront"},
+		{"case and diacritics", "ÜBER uns", []string{"uber"}, "[ÜBER] uns"},
+		{"after punctuation", "(Demo)", []string{"demo"}, "([Demo])"},
+		{"sharp s", "Torstraße 1", []string{"torstrass"}, "[Torstraß]e 1"},
+		{"after sharp s", "Maß und Ziel", []string{"und"}, "Maß [und] Ziel"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlightTerms(tt.text, tt.terms); got != mark(tt.want) {
+				t.Errorf("highlightTerms(%q) = %q, want %q", tt.text, got, mark(tt.want))
+			}
+		})
+	}
+}
+
+func TestSearchEventsUsesGivenTexts(t *testing.T) {
+	db := newTestDB(t)
+
+	stored := &Event{
+		OrganizationID: 1,
+		Title:          "Mitgliederversammlung",
+		Location:       sql.NullString{String: "Alte Straße 1", Valid: true},
+		Description:    sql.NullString{String: "Wahl des Vorstands\n\nKontakt: Anna Muster", Valid: true},
+		DatetimeStart:  time.Date(2025, 3, 1, 19, 0, 0, 0, time.UTC),
+		URL:            "https://example.org/mv",
+		Scraper:        ScraperManual,
+		SourceID:       sql.NullString{String: ManualSourceID("mv"), Valid: true},
+	}
+	if err := db.CreateEvent(stored); err != nil {
+		t.Fatalf("CreateEvent: %v", err)
+	}
+
+	events, err := db.GetEventsByOrganization(1)
+	if err != nil || len(events) != 1 {
+		t.Fatalf("GetEventsByOrganization = %v, %v", events, err)
+	}
+	// As after a strip_contact rule.
+	events[0].Description.String = "Wahl des Vorstands"
+
+	tests := []struct {
+		query       string
+		wantHits    int
+		wantSnippet string
+	}{
+		{"vorstand", 1, "Wahl des [Vorstand]s"},
+		{"mitglieder wahl", 1, "[Wahl] des Vorstands"},
+		{"anna", 0, ""},
+		{"kontakt", 0, ""},
+		{"wahl anna", 0, ""},
+		{"vorstände", 1, "Wahl des [Vorstand]s"},
+		{"wahlen", 1, "[Wahl] des Vorstands"},
+		{"mitgliedern", 1, "Wahl des Vorstands"},
+		{"strasse", 1, "Wahl des Vorstands"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			hits, err := db.SearchEvents(1, tt.query, events)
+			if err != nil {
+				t.Fatalf("SearchEvents: %v", err)
+			}
+			if len(hits) != tt.wantHits {
+				t.Fatalf("got %d hits, want %d", len(hits), tt.wantHits)
+			}
+			if tt.wantHits > 0 {
+				want := strings.NewReplacer("[", HighlightStart, "]", HighlightEnd).Replace(tt.wantSnippet)
+				if hits[0].Snippet != want {
+					t.Errorf("Snippet = %q, want %q", hits[0].Snippet, want)
+				}
+			}
+		})
+	}
+}
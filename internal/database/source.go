@@ -0,0 +1,85 @@
+package database
+
+import (
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// zetkinHost is the Zetkin instance the scraper reads from.
+const zetkinHost = "app.zetkin.die-linke.de"
+
+// Source IDs identify an event by its identity at the source, so they survive
+// database rebuilds. They are used as iCal UIDs and to reconcile scrapes.
+
+func ZetkinSourceID(actionID int) string {
+	return fmt.Sprintf("zetkin-%d@%s", actionID, zetkinHost)
+}
+
+func MobilizonSourceID(uuid, host string) string {
+	return fmt.Sprintf("mobilizon-%s@%s", uuid, host)
+}
+
+func ManualSourceID(key string) string {
+	return fmt.Sprintf("manual-%s@linke-calendar", key)
+}
+
+// sourceIDFromURL derives the source ID of events stored before source IDs
+// were introduced.
+func sourceIDFromURL(scraper, rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err == nil {
+		id := path.Base(u.Path)
+		switch {
+		case scraper == "zetkin" && u.Host == zetkinHost && id != "" && id != "/":
+			return fmt.Sprintf("zetkin-%s@%s", id, zetkinHost)
+		case scraper == "mobilizon" && u.Host != "" && id != "" && id != "/":
+			return MobilizonSourceID(id, u.Host)
+		case scraper == ScraperManual && strings.HasPrefix(rawURL, "manual:"):
+			return ManualSourceID(strings.TrimPrefix(rawURL, "manual:"))
+		}
+	}
+
+	sum := sha1.Sum([]byte(rawURL))
+	return fmt.Sprintf("%s-%s@linke-calendar", scraper, hex.EncodeToString(sum[:12]))
+}
+
+func (db *DB) backfillSourceIDs() error {
+	rows, err := db.Query(`SELECT id, scraper, url FROM events WHERE source_id IS NULL`)
+	if err != nil {
+		return err
+	}
+
+	type pending struct {
+		id       int
+		sourceID string
+	}
+	var updates []pending
+	for rows.Next() {
+		var id int
+		var scraper sql.NullString
+		var rawURL string
+		if err := rows.Scan(&id, &scraper, &rawURL); err != nil {
+			rows.Close()
+			return err
+		}
+		updates = append(updates, pending{id: id, sourceID: sourceIDFromURL(scraper.String, rawURL)})
+	}
+	rows.Close()
+
+	for _, update := range updates {
+		if _, err := db.Exec(`UPDATE events SET source_id = ? WHERE id = ?`, update.sourceID, update.id); err != nil {
+			log.Printf("Failed to set source ID of event %d: %v", update.id, err)
+		}
+	}
+
+	if len(updates) > 0 {
+		log.Printf("Backfilled source IDs of %d events", len(updates))
+	}
+	return nil
+}
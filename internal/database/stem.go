@@ -0,0 +1,152 @@
+package database
+
+import "unicode"
+
+// stemGerman reduces a folded word to its stem with the German Snowball
+// stemmer, see https://snowballstem.org/algorithms/german/stemmer.html.
+// The stemmer only removes suffixes, and umlauts and ß are folded already,
+// so the stem is always a prefix of the word.
+func stemGerman(word []rune) []rune {
+	w := make([]rune, len(word))
+	copy(w, word)
+
+	// u and y between vowels are consonants.
+	for i := 1; i+1 < len(w); i++ {
+		if (w[i] == 'u' || w[i] == 'y') && isGermanVowel(w[i-1]) && isGermanVowel(w[i+1]) {
+			w[i] = unicode.ToUpper(w[i])
+		}
+	}
+
+	// R1 starts after the first non-vowel following a vowel, but no
+	// earlier than the fourth letter, R2 does the same within R1.
+	p1, p2 := len(w), len(w)
+	if len(w) >= 3 {
+		start := germanRegion(w, 0)
+		p2 = germanRegion(w, start)
+		p1 = max(start, 3)
+	}
+
+	w = germanStep1(w, p1)
+	w = germanStep2(w, p1)
+	w = germanStep3(w, p1, p2)
+
+	for i, r := range w {
+		w[i] = unicode.ToLower(r)
+	}
+	return w
+}
+
+func germanStep1(w []rune, p1 int) []rune {
+	suffix := longestSuffix(w, "em", "ern", "er", "e", "en", "es", "s")
+	start := len(w) - len(suffix)
+	if suffix == "" || start < p1 {
+		return w
+	}
+
+	switch suffix {
+	case "s":
+		if isGermanSEnding(w[start-1]) {
+			return w[:start]
+		}
+	case "e", "en", "es":
+		w = w[:start]
+		if hasRuneSuffix(w, "niss") {
+			w = w[:len(w)-1]
+		}
+	default:
+		w = w[:start]
+	}
+	return w
+}
+
+func germanStep2(w []rune, p1 int) []rune {
+	suffix := longestSuffix(w, "en", "er", "est", "st")
+	start := len(w) - len(suffix)
+	if suffix == "" || start < p1 {
+		return w
+	}
+
+	// st needs a valid ending before it, which follows at least three
+	// letters.
+	if suffix == "st" && (start < 4 || !isGermanSTEnding(w[start-1])) {
+		return w
+	}
+	return w[:start]
+}
+
+func germanStep3(w []rune, p1, p2 int) []rune {
+	suffix := longestSuffix(w, "end", "ung", "ig", "ik", "isch", "lich", "heit", "keit")
+	start := len(w) - len(suffix)
+	if suffix == "" || start < p2 {
+		return w
+	}
+
+	switch suffix {
+	case "end", "ung":
+		w = w[:start]
+		if hasRuneSuffix(w, "ig") && len(w)-2 >= p2 && w[len(w)-3] != 'e' {
+			w = w[:len(w)-2]
+		}
+	case "ig", "ik", "isch":
+		if w[start-1] != 'e' {
+			w = w[:start]
+		}
+	case "lich", "heit":
+		w = w[:start]
+		if (hasRuneSuffix(w, "er") || hasRuneSuffix(w, "en")) && len(w)-2 >= p1 {
+			w = w[:len(w)-2]
+		}
+	case "keit":
+		w = w[:start]
+		if rest := longestSuffix(w, "lich", "ig"); rest != "" && len(w)-len(rest) >= p2 {
+			w = w[:len(w)-len(rest)]
+		}
+	}
+	return w
+}
+
+// germanRegion returns the index after the first non-vowel following a
+// vowel, starting at from.
+func germanRegion(w []rune, from int) int {
+	for i := from + 1; i < len(w); i++ {
+		if isGermanVowel(w[i-1]) && !isGermanVowel(w[i]) {
+			return i + 1
+		}
+	}
+	return len(w)
+}
+
+// longestSuffix returns the longest of the ASCII suffixes w ends with.
+func longestSuffix(w []rune, suffixes ...string) string {
+	longest := ""
+	for _, suffix := range suffixes {
+		if len(suffix) > len(longest) && hasRuneSuffix(w, suffix) {
+			longest = suffix
+		}
+	}
+	return longest
+}
+
+func hasRuneSuffix(w []rune, suffix string) bool {
+	return len(w) >= len(suffix) && string(w[len(w)-len(suffix):]) == suffix
+}
+
+func isGermanVowel(r rune) bool {
+	switch r {
+	case 'a', 'e', 'i', 'o', 'u', 'y', 'ä', 'ö', 'ü':
+		return true
+	}
+	return false
+}
+
+func isGermanSEnding(r rune) bool {
+	switch r {
+	case 'b', 'd', 'f', 'g', 'h', 'k', 'l', 'm', 'n', 'r', 't':
+		return true
+	}
+	return false
+}
+
+func isGermanSTEnding(r rune) bool {
+	return r != 'r' && isGermanSEnding(r)
+}
@@ -0,0 +1,43 @@
+package database
+
+import "testing"
+
+func TestStemGerman(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		// From the vocabulary of the Snowball stemmer.
+		{"aufeinander", "aufeinand"},
+		{"aufeinanderfolgenden", "aufeinanderfolg"},
+		{"aufeinanderfolgten", "aufeinanderfolgt"},
+		{"aufenthaltes", "aufenthalt"},
+		{"kategorien", "kategori"},
+		{"kategorischen", "kategor"},
+
+		{"demonstration", "demonstration"},
+		{"demonstrationen", "demonstration"},
+		{"miete", "miet"},
+		{"mieten", "miet"},
+		{"mietendemo", "mietendemo"},
+		{"vorstands", "vorstand"},
+		{"ergebnisse", "ergebnis"},
+		{"versammlungen", "versamml"},
+		{"freiheit", "freiheit"},
+		{"gemeinschaftlich", "gemeinschaft"},
+		{"abgeordnete", "abgeordnet"},
+		{"strasse", "strass"},
+		{"bauer", "bau"},
+		{"feuer", "feu"},
+		{"ab", "ab"},
+		{"2025", "2025"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			if got := string(stemGerman([]rune(tt.word))); got != tt.want {
+				t.Errorf("stemGerman(%q) = %q, want %q", tt.word, got, tt.want)
+			}
+		})
+	}
+}
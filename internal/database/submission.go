@@ -0,0 +1,219 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	SubmissionPending  = "pending"
+	SubmissionApproved = "approved"
+	SubmissionRejected = "rejected"
+)
+
+// ErrSubmissionModerated is returned when a submission has been approved or
+// rejected in the meantime.
+var ErrSubmissionModerated = errors.New("submission has already been moderated")
+
+// Submission is an event proposed through the public form. Approved
+// submissions are copied into a manual event.
+type Submission struct {
+	ID             int
+	OrganizationID int
+	Title          string
+	Description    sql.NullString
+	DatetimeStart  time.Time
+	DatetimeEnd    sql.NullTime
+	AllDay         bool
+	Location       sql.NullString
+	URL            sql.NullString
+	SubmitterName  string
+	SubmitterEmail sql.NullString
+	Status         string
+	EventID        sql.NullInt64
+	CreatedAt      time.Time
+}
+
+// Event returns the proposed event as a manual event of the organization.
+func (s *Submission) Event() *Event {
+	return &Event{
+		OrganizationID: s.OrganizationID,
+		Title:          s.Title,
+		Description:    s.Description,
+		DatetimeStart:  s.DatetimeStart,
+		DatetimeEnd:    s.DatetimeEnd,
+		URL:            s.URL.String,
+		Location:       s.Location,
+		Scraper:        ScraperManual,
+		AllDay:         s.AllDay,
+	}
+}
+
+const submissionColumns = `id, organization_id, title, description, datetime_start, datetime_end, all_day,
+		       location, url, submitter_name, submitter_email, status, event_id, created_at`
+
+func (db *DB) CreateSubmission(s *Submission) error {
+	query := `
+		INSERT INTO submissions (
+			organization_id, title, description, datetime_start, datetime_end, all_day,
+			location, url, submitter_name, submitter_email, status
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := db.Exec(
+		query,
+		s.OrganizationID,
+		s.Title,
+		s.Description,
+		s.DatetimeStart,
+		s.DatetimeEnd,
+		s.AllDay,
+		s.Location,
+		s.URL,
+		s.SubmitterName,
+		s.SubmitterEmail,
+		SubmissionPending,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create submission: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	s.ID = int(id)
+	s.Status = SubmissionPending
+	return nil
+}
+
+func (db *DB) GetSubmission(id int) (*Submission, error) {
+	query := `SELECT ` + submissionColumns + ` FROM submissions WHERE id = ?`
+	s, err := scanSubmission(db.QueryRow(query, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get submission: %w", err)
+	}
+	return s, nil
+}
+
+func (db *DB) GetPendingSubmissionsByOrganization(orgID int) ([]*Submission, error) {
+	query := `
+		SELECT ` + submissionColumns + `
+		FROM submissions
+		WHERE organization_id = ? AND status = ?
+		ORDER BY created_at ASC
+	`
+	rows, err := db.Query(query, orgID, SubmissionPending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get submissions: %w", err)
+	}
+	defer rows.Close()
+
+	var submissions []*Submission
+	for rows.Next() {
+		s, err := scanSubmission(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan submission: %w", err)
+		}
+		submissions = append(submissions, s)
+	}
+
+	return submissions, nil
+}
+
+func (db *DB) CountPendingSubmissions(orgID int) (int, error) {
+	query := `SELECT COUNT(*) FROM submissions WHERE organization_id = ? AND status = ?`
+	var count int
+	if err := db.QueryRow(query, orgID, SubmissionPending).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count submissions: %w", err)
+	}
+	return count, nil
+}
+
+// UpdateSubmission stores a moderated submission. It returns
+// ErrSubmissionModerated if the submission is no longer pending.
+func (db *DB) UpdateSubmission(s *Submission) error {
+	return updateSubmission(db, s)
+}
+
+// ApproveSubmission creates the manual event of a submission and stores the
+// submission as approved in one transaction, so a submission is published
+// only once. It returns ErrSubmissionModerated if the submission is no
+// longer pending.
+func (db *DB) ApproveSubmission(s *Submission, event *Event) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := createEvent(tx, event); err != nil {
+		return err
+	}
+
+	s.Status = SubmissionApproved
+	s.EventID = sql.NullInt64{Int64: int64(event.ID), Valid: true}
+	if err := updateSubmission(tx, s); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func updateSubmission(db execer, s *Submission) error {
+	query := `
+		UPDATE submissions SET
+			title = ?, description = ?, datetime_start = ?, datetime_end = ?, all_day = ?,
+			location = ?, url = ?, status = ?, event_id = ?
+		WHERE id = ? AND status = ?
+	`
+	result, err := db.Exec(
+		query,
+		s.Title,
+		s.Description,
+		s.DatetimeStart,
+		s.DatetimeEnd,
+		s.AllDay,
+		s.Location,
+		s.URL,
+		s.Status,
+		s.EventID,
+		s.ID,
+		SubmissionPending,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update submission: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return ErrSubmissionModerated
+	}
+	return nil
+}
+
+func scanSubmission(row rowScanner) (*Submission, error) {
+	var s Submission
+	if err := row.Scan(
+		&s.ID,
+		&s.OrganizationID,
+		&s.Title,
+		&s.Description,
+		&s.DatetimeStart,
+		&s.DatetimeEnd,
+		&s.AllDay,
+		&s.Location,
+		&s.URL,
+		&s.SubmitterName,
+		&s.SubmitterEmail,
+		&s.Status,
+		&s.EventID,
+		&s.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestApproveSubmissionOnce(t *testing.T) {
+	db := newTestDB(t)
+
+	submission := &Submission{
+		OrganizationID: 1,
+		Title:          "Infostand",
+		DatetimeStart:  time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC),
+		SubmitterName:  "Anna",
+		Status:         SubmissionPending,
+	}
+	if err := db.CreateSubmission(submission); err != nil {
+		t.Fatalf("CreateSubmission: %v", err)
+	}
+
+	taken := submission.Event()
+	taken.URL = "https://example.org/taken"
+	if err := db.CreateEvent(taken); err != nil {
+		t.Fatalf("CreateEvent: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		want error
+	}{
+		{"duplicate link", "https://example.org/taken", ErrDuplicateURL},
+		{"first approval", "manual:a", nil},
+		{"second approval", "manual:b", ErrSubmissionModerated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pending := *submission
+			event := submission.Event()
+			event.URL = tt.url
+			if err := db.ApproveSubmission(&pending, event); !errors.Is(err, tt.want) {
+				t.Fatalf("ApproveSubmission() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	events, err := db.GetEventsByOrganization(1)
+	if err != nil {
+		t.Fatalf("GetEventsByOrganization: %v", err)
+	}
+	if len(events) != 2 {
+		t.Errorf("got %d events, want the taken one and one approved", len(events))
+	}
+
+	stored, err := db.GetSubmission(submission.ID)
+	if err != nil {
+		t.Fatalf("GetSubmission: %v", err)
+	}
+	if stored.Status != SubmissionApproved || !stored.EventID.Valid {
+		t.Errorf("stored submission = %s with event %v, want approved with event", stored.Status, stored.EventID)
+	}
+
+	rejected := *stored
+	rejected.Status = SubmissionRejected
+	if err := db.UpdateSubmission(&rejected); !errors.Is(err, ErrSubmissionModerated) {
+		t.Errorf("UpdateSubmission() = %v, want %v", err, ErrSubmissionModerated)
+	}
+}
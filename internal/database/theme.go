@@ -0,0 +1,66 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// OrganizationTheme is the look of the embedded pages as set in the admin
+// area. Empty values keep the configured theme.
+type OrganizationTheme struct {
+	OrganizationID int
+	Primary        string
+	Text           string
+	Font           string
+	Density        string
+	Mode           string
+	UpdatedAt      time.Time
+}
+
+// GetOrganizationTheme returns the theme of an organization, which is empty
+// if none has been saved.
+func (db *DB) GetOrganizationTheme(orgID int) (*OrganizationTheme, error) {
+	query := `
+		SELECT organization_id, primary_color, text_color, font, density, mode, updated_at
+		FROM organization_themes
+		WHERE organization_id = ?
+	`
+	var theme OrganizationTheme
+	err := db.QueryRow(query, orgID).Scan(
+		&theme.OrganizationID,
+		&theme.Primary,
+		&theme.Text,
+		&theme.Font,
+		&theme.Density,
+		&theme.Mode,
+		&theme.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &OrganizationTheme{OrganizationID: orgID}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization theme: %w", err)
+	}
+	return &theme, nil
+}
+
+func (db *DB) UpsertOrganizationTheme(theme *OrganizationTheme) error {
+	query := `
+		INSERT INTO organization_themes (organization_id, primary_color, text_color, font, density, mode, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(organization_id) DO UPDATE SET
+			primary_color = excluded.primary_color,
+			text_color = excluded.text_color,
+			font = excluded.font,
+			density = excluded.density,
+			mode = excluded.mode,
+			updated_at = CURRENT_TIMESTAMP
+	`
+	_, err := db.Exec(query, theme.OrganizationID, theme.Primary, theme.Text, theme.Font, theme.Density, theme.Mode)
+	if err != nil {
+		return fmt.Errorf("failed to save organization theme: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Scopes of API tokens. Refresh tokens may queue scrapes of their
+// organization, events tokens manage its manual events with the admin API.
+const (
+	ScopeRefresh = "refresh"
+	ScopeEvents  = "events"
+)
+
+var Scopes = []string{ScopeRefresh, ScopeEvents}
+
+// APIToken authenticates scripts of an organization. Like sessions, only the
+// hash of the token is stored. CreatedByName is only set when listing the
+// tokens of an organization.
+type APIToken struct {
+	ID             int
+	OrganizationID int
+	Name           string
+	TokenHash      string
+	Scopes         []string
+	CreatedBy      int
+	CreatedByName  string
+	LastUsedAt     sql.NullTime
+	CreatedAt      time.Time
+}
+
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+const apiTokenColumns = `t.id, t.organization_id, t.name, t.token_hash, t.scopes, t.created_by, COALESCE(u.username, ''), t.last_used_at, t.created_at`
+
+func (db *DB) CreateAPIToken(token *APIToken) error {
+	query := `
+		INSERT INTO api_tokens (organization_id, name, token_hash, scopes, created_by)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	result, err := db.Exec(query, token.OrganizationID, token.Name, token.TokenHash, strings.Join(token.Scopes, ","), token.CreatedBy)
+	if err != nil {
+		return fmt.Errorf("failed to create API token: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	token.ID = int(id)
+	return nil
+}
+
+func (db *DB) GetAPITokenByHash(tokenHash string) (*APIToken, error) {
+	query := `SELECT ` + apiTokenColumns + ` FROM api_tokens t LEFT JOIN users u ON u.id = t.created_by WHERE t.token_hash = ?`
+	token, err := scanAPIToken(db.QueryRow(query, tokenHash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API token: %w", err)
+	}
+	return token, nil
+}
+
+func (db *DB) GetAPITokens(orgID int) ([]*APIToken, error) {
+	query := `SELECT ` + apiTokenColumns + ` FROM api_tokens t LEFT JOIN users u ON u.id = t.created_by
+		WHERE t.organization_id = ? ORDER BY t.created_at, t.id`
+	rows, err := db.Query(query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []*APIToken
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func scanAPIToken(row rowScanner) (*APIToken, error) {
+	var token APIToken
+	var scopes string
+	if err := row.Scan(
+		&token.ID,
+		&token.OrganizationID,
+		&token.Name,
+		&token.TokenHash,
+		&scopes,
+		&token.CreatedBy,
+		&token.CreatedByName,
+		&token.LastUsedAt,
+		&token.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if scopes != "" {
+		token.Scopes = strings.Split(scopes, ",")
+	}
+	return &token, nil
+}
+
+func (db *DB) UpdateAPITokenLastUsed(id int, now time.Time) error {
+	if _, err := db.Exec(`UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, now, id); err != nil {
+		return fmt.Errorf("failed to update API token: %w", err)
+	}
+	return nil
+}
+
+// DeleteAPIToken revokes a token of an organization.
+func (db *DB) DeleteAPIToken(id, orgID int) error {
+	result, err := db.Exec(`DELETE FROM api_tokens WHERE id = ? AND organization_id = ?`, id, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to delete API token: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,467 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Roles of admin accounts. Operators manage the whole instance, org admins
+// and editors are members of single organizations. Org admins manage rules,
+// theme and members of their organization, editors its events.
+const (
+	RoleOperator = "operator"
+	RoleOrgAdmin = "org-admin"
+	RoleEditor   = "editor"
+)
+
+var (
+	ErrUsernameTaken = errors.New("username is already taken")
+	ErrInviteInvalid = errors.New("invite is used or expired")
+)
+
+type User struct {
+	ID           int
+	Username     string
+	PasswordHash string
+	Operator     bool
+	CreatedAt    time.Time
+}
+
+// Membership grants a user a role in an organization. Username is only set
+// when listing the members of an organization.
+type Membership struct {
+	UserID         int
+	OrganizationID int
+	Role           string
+	Username       string
+}
+
+// Session is a login of a user. The token itself is only known to the
+// browser, the database stores its hash.
+type Session struct {
+	TokenHash string
+	UserID    int
+	CSRFToken string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// Invite lets someone join an organization, or become an operator if
+// OrganizationID is not set.
+type Invite struct {
+	ID             int
+	TokenHash      string
+	OrganizationID sql.NullInt64
+	Role           string
+	CreatedBy      int
+	ExpiresAt      time.Time
+	UsedAt         sql.NullTime
+	CreatedAt      time.Time
+}
+
+const (
+	userColumns   = `id, username, password_hash, operator, created_at`
+	inviteColumns = `id, token_hash, organization_id, role, created_by, expires_at, used_at, created_at`
+)
+
+func (db *DB) CreateUser(user *User) error {
+	return createUser(db, user)
+}
+
+func createUser(db execer, user *User) error {
+	query := `INSERT INTO users (username, password_hash, operator) VALUES (?, ?, ?)`
+	result, err := db.Exec(query, user.Username, user.PasswordHash, user.Operator)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return ErrUsernameTaken
+		}
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	user.ID = int(id)
+	return nil
+}
+
+func (db *DB) GetUser(id int) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = ?`
+	user, err := scanUser(db.QueryRow(query, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+// GetUserByUsername finds a user, ignoring the case of the username.
+func (db *DB) GetUserByUsername(username string) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = ?`
+	user, err := scanUser(db.QueryRow(query, username))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+func (db *DB) GetAllUsers() ([]*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users ORDER BY username`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+func scanUser(row rowScanner) (*User, error) {
+	var user User
+	if err := row.Scan(
+		&user.ID,
+		&user.Username,
+		&user.PasswordHash,
+		&user.Operator,
+		&user.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (db *DB) UpdateUserPassword(id int, passwordHash string) error {
+	query := `UPDATE users SET password_hash = ? WHERE id = ?`
+	if _, err := db.Exec(query, passwordHash, id); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) SetUserOperator(id int, operator bool) error {
+	query := `UPDATE users SET operator = ? WHERE id = ?`
+	if _, err := db.Exec(query, operator, id); err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	return nil
+}
+
+// DeleteUser removes a user with its memberships, sessions and API tokens.
+func (db *DB) DeleteUser(id int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`DELETE FROM sessions WHERE user_id = ?`,
+		`DELETE FROM memberships WHERE user_id = ?`,
+		`DELETE FROM api_tokens WHERE created_by = ?`,
+		`DELETE FROM users WHERE id = ?`,
+	} {
+		if _, err := tx.Exec(query, id); err != nil {
+			return fmt.Errorf("failed to delete user: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) GetMemberships(userID int) ([]*Membership, error) {
+	query := `
+		SELECT m.user_id, m.organization_id, m.role, u.username
+		FROM memberships m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.user_id = ?
+		ORDER BY m.organization_id
+	`
+	return db.queryMemberships(query, userID)
+}
+
+func (db *DB) GetOrganizationMembers(orgID int) ([]*Membership, error) {
+	query := `
+		SELECT m.user_id, m.organization_id, m.role, u.username
+		FROM memberships m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.organization_id = ?
+		ORDER BY u.username
+	`
+	return db.queryMemberships(query, orgID)
+}
+
+func (db *DB) GetAllMemberships() ([]*Membership, error) {
+	query := `
+		SELECT m.user_id, m.organization_id, m.role, u.username
+		FROM memberships m
+		JOIN users u ON u.id = m.user_id
+		ORDER BY m.organization_id
+	`
+	return db.queryMemberships(query)
+}
+
+func (db *DB) queryMemberships(query string, args ...interface{}) ([]*Membership, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get memberships: %w", err)
+	}
+	defer rows.Close()
+
+	var memberships []*Membership
+	for rows.Next() {
+		var m Membership
+		if err := rows.Scan(&m.UserID, &m.OrganizationID, &m.Role, &m.Username); err != nil {
+			return nil, fmt.Errorf("failed to scan membership: %w", err)
+		}
+		memberships = append(memberships, &m)
+	}
+
+	return memberships, rows.Err()
+}
+
+// UpdateMembershipRole changes the role of a member. It returns
+// sql.ErrNoRows if the user is no member.
+func (db *DB) UpdateMembershipRole(userID, orgID int, role string) error {
+	query := `UPDATE memberships SET role = ? WHERE user_id = ? AND organization_id = ?`
+	result, err := db.Exec(query, role, userID, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to update membership: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteMembership removes a user from an organization and revokes the API
+// tokens the user created for it. It returns sql.ErrNoRows if the user is no
+// member.
+func (db *DB) DeleteMembership(userID, orgID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM memberships WHERE user_id = ? AND organization_id = ?`
+	result, err := tx.Exec(query, userID, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to delete membership: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+
+	query = `DELETE FROM api_tokens WHERE created_by = ? AND organization_id = ?`
+	if _, err := tx.Exec(query, userID, orgID); err != nil {
+		return fmt.Errorf("failed to delete API tokens: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) CreateSession(session *Session) error {
+	query := `INSERT INTO sessions (token_hash, user_id, csrf_token, expires_at) VALUES (?, ?, ?, ?)`
+	if _, err := db.Exec(query, session.TokenHash, session.UserID, session.CSRFToken, session.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+// GetSession returns a session that has not expired by now.
+func (db *DB) GetSession(tokenHash string, now time.Time) (*Session, error) {
+	query := `
+		SELECT token_hash, user_id, csrf_token, expires_at, created_at
+		FROM sessions
+		WHERE token_hash = ? AND expires_at > ?
+	`
+	var session Session
+	err := db.QueryRow(query, tokenHash, now).Scan(
+		&session.TokenHash,
+		&session.UserID,
+		&session.CSRFToken,
+		&session.ExpiresAt,
+		&session.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return &session, nil
+}
+
+func (db *DB) DeleteSession(tokenHash string) error {
+	if _, err := db.Exec(`DELETE FROM sessions WHERE token_hash = ?`, tokenHash); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// DeleteUserSessions logs a user out everywhere, except for the session
+// keepTokenHash.
+func (db *DB) DeleteUserSessions(userID int, keepTokenHash string) error {
+	query := `DELETE FROM sessions WHERE user_id = ? AND token_hash != ?`
+	if _, err := db.Exec(query, userID, keepTokenHash); err != nil {
+		return fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) DeleteExpiredSessions(now time.Time) error {
+	if _, err := db.Exec(`DELETE FROM sessions WHERE expires_at <= ?`, now); err != nil {
+		return fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) CreateInvite(invite *Invite) error {
+	query := `
+		INSERT INTO invites (token_hash, organization_id, role, created_by, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	result, err := db.Exec(query, invite.TokenHash, invite.OrganizationID, invite.Role, invite.CreatedBy, invite.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create invite: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	invite.ID = int(id)
+	return nil
+}
+
+// GetOpenInvite returns an invite that is neither used nor expired.
+func (db *DB) GetOpenInvite(tokenHash string, now time.Time) (*Invite, error) {
+	query := `SELECT ` + inviteColumns + ` FROM invites WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?`
+	invite, err := scanInvite(db.QueryRow(query, tokenHash, now))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invite: %w", err)
+	}
+	return invite, nil
+}
+
+// GetOpenInvites lists the unused invites of an organization, or the
+// operator invites if orgID is 0.
+func (db *DB) GetOpenInvites(orgID int, now time.Time) ([]*Invite, error) {
+	query := `SELECT ` + inviteColumns + ` FROM invites
+		WHERE COALESCE(organization_id, 0) = ? AND used_at IS NULL AND expires_at > ?
+		ORDER BY created_at`
+	rows, err := db.Query(query, orgID, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invites: %w", err)
+	}
+	defer rows.Close()
+
+	var invites []*Invite
+	for rows.Next() {
+		invite, err := scanInvite(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invite: %w", err)
+		}
+		invites = append(invites, invite)
+	}
+
+	return invites, rows.Err()
+}
+
+func scanInvite(row rowScanner) (*Invite, error) {
+	var invite Invite
+	if err := row.Scan(
+		&invite.ID,
+		&invite.TokenHash,
+		&invite.OrganizationID,
+		&invite.Role,
+		&invite.CreatedBy,
+		&invite.ExpiresAt,
+		&invite.UsedAt,
+		&invite.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// DeleteInvite revokes an invite of an organization, or an operator invite
+// if orgID is 0.
+func (db *DB) DeleteInvite(id, orgID int) error {
+	query := `DELETE FROM invites WHERE id = ? AND COALESCE(organization_id, 0) = ?`
+	if _, err := db.Exec(query, id, orgID); err != nil {
+		return fmt.Errorf("failed to delete invite: %w", err)
+	}
+	return nil
+}
+
+// AcceptInvite marks an invite as used and grants its role to user. A user
+// without ID is created first. It returns ErrInviteInvalid if the invite
+// has been used or expired in the meantime.
+func (db *DB) AcceptInvite(invite *Invite, user *User, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE invites SET used_at = ? WHERE id = ? AND used_at IS NULL AND expires_at > ?`,
+		now, invite.ID, now)
+	if err != nil {
+		return fmt.Errorf("failed to use invite: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return ErrInviteInvalid
+	}
+
+	if user.ID == 0 {
+		if err := createUser(tx, user); err != nil {
+			return err
+		}
+	}
+
+	if invite.OrganizationID.Valid {
+		// Accepting an editor invite keeps an existing org admin role.
+		_, err = tx.Exec(`
+			INSERT INTO memberships (user_id, organization_id, role)
+			VALUES (?, ?, ?)
+			ON CONFLICT(user_id, organization_id) DO UPDATE SET
+				role = CASE WHEN memberships.role = ? THEN memberships.role ELSE excluded.role END
+		`, user.ID, invite.OrganizationID.Int64, invite.Role, RoleOrgAdmin)
+	} else {
+		_, err = tx.Exec(`UPDATE users SET operator = 1 WHERE id = ?`, user.ID)
+		user.Operator = true
+	}
+	if err != nil {
+		return fmt.Errorf("failed to grant invite role: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// execer is implemented by *DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
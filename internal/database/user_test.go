@@ -0,0 +1,70 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRemovingUsersRevokesTokens(t *testing.T) {
+	db := newTestDB(t)
+
+	newUser := func(username string) *User {
+		user := &User{Username: username, PasswordHash: "x"}
+		if err := db.CreateUser(user); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		for _, orgID := range []int{1, 2} {
+			if _, err := db.Exec(`INSERT INTO memberships (user_id, organization_id, role) VALUES (?, ?, ?)`, user.ID, orgID, "org-admin"); err != nil {
+				t.Fatalf("insert membership: %v", err)
+			}
+			token := &APIToken{OrganizationID: orgID, Name: username, TokenHash: fmt.Sprintf("%s-%d", username, orgID), Scopes: Scopes, CreatedBy: user.ID}
+			if err := db.CreateAPIToken(token); err != nil {
+				t.Fatalf("CreateAPIToken: %v", err)
+			}
+		}
+		return user
+	}
+
+	tokenCount := func(orgID int) map[string]int {
+		tokens, err := db.GetAPITokens(orgID)
+		if err != nil {
+			t.Fatalf("GetAPITokens: %v", err)
+		}
+		count := make(map[string]int)
+		for _, token := range tokens {
+			count[token.Name]++
+		}
+		return count
+	}
+
+	alice := newUser("alice")
+	bob := newUser("bob")
+	newUser("carol")
+
+	if err := db.DeleteMembership(alice.ID, 1); err != nil {
+		t.Fatalf("DeleteMembership: %v", err)
+	}
+	if err := db.DeleteUser(bob.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	tests := []struct {
+		orgID int
+		want  map[string]int
+	}{
+		{1, map[string]int{"carol": 1}},
+		{2, map[string]int{"alice": 1, "carol": 1}},
+	}
+	for _, tt := range tests {
+		got := tokenCount(tt.orgID)
+		if len(got) != len(tt.want) {
+			t.Errorf("tokens of organization %d = %v, want %v", tt.orgID, got, tt.want)
+			continue
+		}
+		for name, n := range tt.want {
+			if got[name] != n {
+				t.Errorf("tokens of organization %d = %v, want %v", tt.orgID, got, tt.want)
+			}
+		}
+	}
+}
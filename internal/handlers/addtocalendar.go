@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/romanzipp/linke-calendar/internal/database"
+	"github.com/romanzipp/linke-calendar/internal/ical"
+)
+
+// maxLinkDescription limits the description passed to calendar services, as
+// their links break with very long URLs.
+const maxLinkDescription = 1000
+
+// calendarLinks are the "In Kalender eintragen" targets of an event.
+type calendarLinks struct {
+	ICS     string
+	Google  string
+	Outlook string
+	Yahoo   string
+}
+
+func newCalendarLinks(event *database.Event) calendarLinks {
+	start, end := eventTimes(event)
+	details := linkDescription(event)
+
+	google := url.Values{}
+	google.Set("action", "TEMPLATE")
+	google.Set("text", event.Title)
+	if event.AllDay {
+		google.Set("dates", start.Format("20060102")+"/"+end.Format("20060102"))
+	} else {
+		google.Set("dates", start.Format("20060102T150405")+"/"+end.Format("20060102T150405"))
+		google.Set("ctz", start.Location().String())
+	}
+	setNonEmpty(google, "details", details)
+	setNonEmpty(google, "location", event.Location.String)
+
+	outlook := url.Values{}
+	outlook.Set("path", "/calendar/action/compose")
+	outlook.Set("rru", "addevent")
+	outlook.Set("subject", event.Title)
+	if event.AllDay {
+		outlook.Set("startdt", start.Format("2006-01-02"))
+		outlook.Set("enddt", end.Format("2006-01-02"))
+		outlook.Set("allday", "true")
+	} else {
+		outlook.Set("startdt", start.Format(time.RFC3339))
+		outlook.Set("enddt", end.Format(time.RFC3339))
+		outlook.Set("allday", "false")
+	}
+	setNonEmpty(outlook, "body", details)
+	setNonEmpty(outlook, "location", event.Location.String)
+
+	yahoo := url.Values{}
+	yahoo.Set("v", "60")
+	yahoo.Set("title", event.Title)
+	if event.AllDay {
+		yahoo.Set("st", start.Format("20060102"))
+		yahoo.Set("dur", "allday")
+	} else {
+		yahoo.Set("st", start.UTC().Format("20060102T150405Z"))
+		yahoo.Set("et", end.UTC().Format("20060102T150405Z"))
+	}
+	setNonEmpty(yahoo, "desc", details)
+	setNonEmpty(yahoo, "in_loc", event.Location.String)
+
+	return calendarLinks{
+		ICS:     eventPath(event) + "/ics",
+		Google:  "https://calendar.google.com/calendar/render?" + encodeQuery(google),
+		Outlook: "https://outlook.live.com/calendar/0/deeplink/compose?" + encodeQuery(outlook),
+		Yahoo:   "https://calendar.yahoo.com/?" + encodeQuery(yahoo),
+	}
+}
+
+func setNonEmpty(values url.Values, key, value string) {
+	if value != "" {
+		values.Set(key, value)
+	}
+}
+
+// encodeQuery encodes spaces as %20, since not every calendar service
+// decodes "+" in query strings.
+func encodeQuery(values url.Values) string {
+	return strings.ReplaceAll(values.Encode(), "+", "%20")
+}
+
+func linkDescription(event *database.Event) string {
+	description := truncate(event.Description.String, maxLinkDescription)
+	if event.HasLink() {
+		if description != "" {
+			description += "\n\n"
+		}
+		description += event.URL
+	}
+	return description
+}
+
+// EventICS offers a single event as calendar file for visitors who don't
+// want to subscribe to the whole feed.
+func (h *Handler) EventICS(w http.ResponseWriter, r *http.Request) {
+	event, snap, err := h.findEvent(r)
+	if errors.Is(err, errEventNotFound) {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to get event %s: %v", chi.URLParam(r, "eventID"), err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if _, done := h.notModified(w, r, "event", snap.Version); done {
+		return
+	}
+
+	icalEvent := newICalEvent(event, newICalOrganizer(event.OrganizationID, getOrganizationTitle(snap.Organization)))
+	for _, before := range h.config.GetAlarms(event.Activity.String) {
+		icalEvent.Alarms = append(icalEvent.Alarms, ical.Alarm{Before: before})
+	}
+
+	cal := &ical.Calendar{
+		Location: database.Berlin,
+		Events:   []*ical.Event{icalEvent},
+	}
+
+	var buf bytes.Buffer
+	if err := cal.Encode(&buf); err != nil {
+		log.Printf("Failed to serialize iCal: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"event-%d.ics\"", event.ID))
+	w.Write(buf.Bytes())
+}
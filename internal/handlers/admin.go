@@ -0,0 +1,657 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/romanzipp/linke-calendar/internal/database"
+	"github.com/romanzipp/linke-calendar/internal/i18n"
+	"github.com/romanzipp/linke-calendar/internal/rules"
+)
+
+const (
+	formDateLayout     = "2006-01-02"
+	formDatetimeLayout = "2006-01-02T15:04"
+)
+
+// duplicateLinkError is shown if the link of a manual event is already used
+// by another event, as links are unique. The JSON API answers in English.
+const (
+	duplicateLinkError    = "Ein anderer Termin hat bereits diesen Link"
+	apiDuplicateLinkError = "Another event already uses this link"
+)
+
+type eventPayload struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Location    string `json:"location"`
+	URL         string `json:"url"`
+	Start       string `json:"start"`
+	End         string `json:"end"`
+	AllDay      bool   `json:"all_day"`
+}
+
+type adminEventJSON struct {
+	ID             int     `json:"id"`
+	OrganizationID int     `json:"organization_id"`
+	Title          string  `json:"title"`
+	Description    *string `json:"description"`
+	Location       *string `json:"location"`
+	URL            *string `json:"url"`
+	Start          string  `json:"start"`
+	End            *string `json:"end"`
+	AllDay         bool    `json:"all_day"`
+	Hidden         bool    `json:"hidden"`
+	Highlight      bool    `json:"highlight"`
+	Scraper        string  `json:"scraper"`
+}
+
+func (h *Handler) AdminEvents(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	h.renderAdminEvents(w, r, orgID, eventPayload{}, "")
+}
+
+func (h *Handler) AdminCreateEvent(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	payload := eventPayloadFromForm(r)
+	event := &database.Event{OrganizationID: orgID, Scraper: database.ScraperManual}
+	if err := payload.apply(event); err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		h.renderAdminEvents(w, r, orgID, payload, err.Error())
+		return
+	}
+
+	if err := h.db.CreateEvent(event); err != nil {
+		if errors.Is(err, database.ErrDuplicateURL) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			h.renderAdminEvents(w, r, orgID, payload, duplicateLinkError)
+			return
+		}
+		log.Printf("Failed to create manual event for organization %d: %v", orgID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/org/%d/events", orgID), http.StatusSeeOther)
+}
+
+func (h *Handler) AdminEditEvent(w http.ResponseWriter, r *http.Request) {
+	event, ok := h.adminManualEvent(w, r)
+	if !ok {
+		return
+	}
+
+	h.renderAdminEventForm(w, r, event, eventPayloadFromEvent(event), "")
+}
+
+func (h *Handler) AdminUpdateEvent(w http.ResponseWriter, r *http.Request) {
+	event, ok := h.adminManualEvent(w, r)
+	if !ok {
+		return
+	}
+
+	payload := eventPayloadFromForm(r)
+	if err := payload.apply(event); err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		h.renderAdminEventForm(w, r, event, payload, err.Error())
+		return
+	}
+
+	if err := h.db.UpdateEvent(event); err != nil {
+		if errors.Is(err, database.ErrDuplicateURL) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			h.renderAdminEventForm(w, r, event, payload, duplicateLinkError)
+			return
+		}
+		log.Printf("Failed to update event %d: %v", event.ID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/org/%d/events", event.OrganizationID), http.StatusSeeOther)
+}
+
+func (h *Handler) AdminDeleteEvent(w http.ResponseWriter, r *http.Request) {
+	event, ok := h.adminManualEvent(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteEvent(event.ID); err != nil {
+		log.Printf("Failed to delete event %d: %v", event.ID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/org/%d/events", event.OrganizationID), http.StatusSeeOther)
+}
+
+func (h *Handler) AdminEditOverride(w http.ResponseWriter, r *http.Request) {
+	event, ok := h.adminEvent(w, r)
+	if !ok {
+		return
+	}
+
+	h.renderAdminOverride(w, r, event)
+}
+
+func (h *Handler) AdminUpdateOverride(w http.ResponseWriter, r *http.Request) {
+	event, ok := h.adminEvent(w, r)
+	if !ok {
+		return
+	}
+
+	source := event.Source()
+	override := &database.EventOverride{
+		EventID:     event.ID,
+		Title:       overrideValue(r.FormValue("title"), source.Title),
+		Description: overrideValue(r.FormValue("description"), source.Description.String),
+		Location:    overrideValue(r.FormValue("location"), source.Location.String),
+		Hidden:      r.FormValue("hidden") != "",
+		Highlight:   r.FormValue("highlight") != "",
+	}
+
+	var err error
+	if override.IsEmpty() {
+		err = h.db.DeleteEventOverride(event.ID)
+	} else {
+		err = h.db.UpsertEventOverride(override)
+	}
+	if err != nil {
+		log.Printf("Failed to save override for event %d: %v", event.ID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/event/%d/override", event.ID), http.StatusSeeOther)
+}
+
+func (h *Handler) AdminDeleteOverride(w http.ResponseWriter, r *http.Request) {
+	event, ok := h.adminEvent(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteEventOverride(event.ID); err != nil {
+		log.Printf("Failed to delete override for event %d: %v", event.ID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/event/%d/override", event.ID), http.StatusSeeOther)
+}
+
+func (h *Handler) AdminAPIListEvents(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid organization ID")
+		return
+	}
+
+	events, err := h.db.GetEventsByOrganization(orgID)
+	if err != nil {
+		log.Printf("Failed to get events for organization %d: %v", orgID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	result := make([]adminEventJSON, 0, len(events))
+	for _, event := range events {
+		result = append(result, newAdminEventJSON(event))
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h *Handler) AdminAPICreateEvent(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid organization ID")
+		return
+	}
+
+	var payload eventPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	event := &database.Event{OrganizationID: orgID, Scraper: database.ScraperManual}
+	if err := payload.apply(event); err != nil {
+		writePayloadError(w, err)
+		return
+	}
+
+	if err := h.db.CreateEvent(event); err != nil {
+		if errors.Is(err, database.ErrDuplicateURL) {
+			writeJSONError(w, http.StatusUnprocessableEntity, apiDuplicateLinkError)
+			return
+		}
+		log.Printf("Failed to create manual event for organization %d: %v", orgID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newAdminEventJSON(event))
+}
+
+func (h *Handler) AdminAPIUpdateEvent(w http.ResponseWriter, r *http.Request) {
+	event, status, msg := h.loadManualEvent(r)
+	if event == nil {
+		writeJSONError(w, status, msg)
+		return
+	}
+
+	var payload eventPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	if err := payload.apply(event); err != nil {
+		writePayloadError(w, err)
+		return
+	}
+
+	if err := h.db.UpdateEvent(event); err != nil {
+		if errors.Is(err, database.ErrDuplicateURL) {
+			writeJSONError(w, http.StatusUnprocessableEntity, apiDuplicateLinkError)
+			return
+		}
+		log.Printf("Failed to update event %d: %v", event.ID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newAdminEventJSON(event))
+}
+
+func (h *Handler) AdminAPIDeleteEvent(w http.ResponseWriter, r *http.Request) {
+	event, status, msg := h.loadManualEvent(r)
+	if event == nil {
+		writeJSONError(w, status, msg)
+		return
+	}
+
+	if err := h.db.DeleteEvent(event.ID); err != nil {
+		log.Printf("Failed to delete event %d: %v", event.ID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) renderAdminEvents(w http.ResponseWriter, r *http.Request, orgID int, form eventPayload, formError string) {
+	events, err := h.db.GetEventsByOrganization(orgID)
+	if err != nil {
+		log.Printf("Failed to get events for organization %d: %v", orgID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	orgRules, err := h.db.GetRulesByOrganization(orgID)
+	if err != nil {
+		log.Printf("Failed to get rules for organization %d: %v", orgID, err)
+	}
+	rules.Apply(orgRules, events)
+
+	pending, err := h.db.CountPendingSubmissions(orgID)
+	if err != nil {
+		log.Printf("Failed to count submissions for organization %d: %v", orgID, err)
+	}
+
+	org, err := h.db.GetOrganization(orgID)
+	if err != nil {
+		log.Printf("Failed to get organization %d: %v", orgID, err)
+	}
+
+	data := struct {
+		OrganizationID     int
+		OrganizationTitle  string
+		Events             []*database.Event
+		PendingSubmissions int
+		Form               eventPayload
+		Error              string
+		Version            string
+	}{
+		OrganizationID:     orgID,
+		OrganizationTitle:  getOrganizationTitle(org),
+		Events:             events,
+		PendingSubmissions: pending,
+		Form:               form,
+		Error:              formError,
+		Version:            h.version,
+	}
+
+	h.renderAdmin(w, r, "admin-events.html", data)
+}
+
+func (h *Handler) renderAdminEventForm(w http.ResponseWriter, r *http.Request, event *database.Event, form eventPayload, formError string) {
+	data := struct {
+		Event   *database.Event
+		Form    eventPayload
+		Error   string
+		Version string
+	}{
+		Event:   event,
+		Form:    form,
+		Error:   formError,
+		Version: h.version,
+	}
+
+	h.renderAdmin(w, r, "admin-event.html", data)
+}
+
+func (h *Handler) renderAdminOverride(w http.ResponseWriter, r *http.Request, event *database.Event) {
+	data := struct {
+		Event    *database.Event
+		Original *database.Event
+		Override *database.EventOverride
+		Version  string
+	}{
+		Event:    event,
+		Original: event.Source(),
+		Override: event.Override,
+		Version:  h.version,
+	}
+	if data.Override == nil {
+		data.Override = &database.EventOverride{EventID: event.ID}
+	}
+
+	h.renderAdmin(w, r, "admin-override.html", data)
+}
+
+func (h *Handler) adminEvent(w http.ResponseWriter, r *http.Request) (*database.Event, bool) {
+	eventID, err := strconv.Atoi(chi.URLParam(r, "eventID"))
+	if err != nil {
+		http.Error(w, "Invalid event ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	event, err := h.db.GetEvent(eventID)
+	if err != nil {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	if !currentAdmin(r).CanEdit(event.OrganizationID) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, false
+	}
+
+	return event, true
+}
+
+func (h *Handler) adminManualEvent(w http.ResponseWriter, r *http.Request) (*database.Event, bool) {
+	event, status, msg := h.loadManualEvent(r)
+	if event == nil {
+		http.Error(w, msg, status)
+		return nil, false
+	}
+	return event, true
+}
+
+func (h *Handler) loadManualEvent(r *http.Request) (*database.Event, int, string) {
+	eventID, err := strconv.Atoi(chi.URLParam(r, "eventID"))
+	if err != nil {
+		return nil, http.StatusBadRequest, "Invalid event ID"
+	}
+
+	event, err := h.db.GetEvent(eventID)
+	if err != nil {
+		return nil, http.StatusNotFound, "Event not found"
+	}
+
+	if !currentAdmin(r).CanEdit(event.OrganizationID) {
+		return nil, http.StatusForbidden, "Forbidden"
+	}
+
+	if !event.IsManual() {
+		return nil, http.StatusConflict, "Only manual events can be edited"
+	}
+
+	return event, 0, ""
+}
+
+func eventPayloadFromForm(r *http.Request) eventPayload {
+	payload := eventPayload{
+		Title:       strings.TrimSpace(r.FormValue("title")),
+		Description: strings.TrimSpace(r.FormValue("description")),
+		Location:    strings.TrimSpace(r.FormValue("location")),
+		URL:         strings.TrimSpace(r.FormValue("url")),
+		AllDay:      r.FormValue("all_day") != "",
+	}
+
+	if payload.AllDay {
+		payload.Start = r.FormValue("start_date")
+		payload.End = r.FormValue("end_date")
+	} else {
+		payload.Start = r.FormValue("start")
+		payload.End = r.FormValue("end")
+	}
+
+	return payload
+}
+
+func eventPayloadFromEvent(event *database.Event) eventPayload {
+	layout := formDatetimeLayout
+	if event.AllDay {
+		layout = formDateLayout
+	}
+
+	payload := eventPayload{
+		Title:       event.Title,
+		Description: event.Description.String,
+		Location:    event.Location.String,
+		Start:       event.DatetimeStart.Format(layout),
+		AllDay:      event.AllDay,
+	}
+	if event.HasLink() {
+		payload.URL = event.URL
+	}
+	if event.DatetimeEnd.Valid {
+		payload.End = event.DatetimeEnd.Time.Format(layout)
+	}
+	return payload
+}
+
+// payloadError is a validation error of an event payload. It holds the key
+// of its message, Error returns the German text of the admin area.
+type payloadError string
+
+func (e payloadError) Key() string {
+	return string(e)
+}
+
+// writePayloadError answers the JSON API with the English message of a
+// payload error.
+func writePayloadError(w http.ResponseWriter, err error) {
+	var invalid payloadError
+	if !errors.As(err, &invalid) {
+		log.Printf("Failed to prepare manual event: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	writeJSONError(w, http.StatusUnprocessableEntity, i18n.Get("en").T(invalid.Key()))
+}
+
+func (e payloadError) Error() string {
+	return i18n.Get(i18n.DefaultLanguage).T(string(e))
+}
+
+// apply validates the payload and copies it onto the event. Times are taken
+// as Berlin wall clock, matching how scraped events are stored.
+func (p eventPayload) apply(event *database.Event) error {
+	title := strings.TrimSpace(p.Title)
+	if title == "" {
+		return payloadError("form.error.title")
+	}
+
+	start, err := parseFormTime(p.Start, p.AllDay)
+	if err != nil {
+		return payloadError("form.error.start")
+	}
+
+	end := sql.NullTime{}
+	if p.End != "" {
+		t, err := parseFormTime(p.End, p.AllDay)
+		if err != nil {
+			return payloadError("form.error.end")
+		}
+		if p.AllDay {
+			t = t.Add(23*time.Hour + 59*time.Minute)
+		}
+		if t.Before(start) {
+			return payloadError("form.error.end_before_start")
+		}
+		end = sql.NullTime{Time: t, Valid: true}
+	} else if p.AllDay {
+		end = sql.NullTime{Time: start.Add(23*time.Hour + 59*time.Minute), Valid: true}
+	}
+
+	link := strings.TrimSpace(p.URL)
+	if link != "" {
+		u, err := url.Parse(link)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return payloadError("form.error.link")
+		}
+	}
+
+	event.Title = title
+	event.Description = sql.NullString{String: strings.TrimSpace(p.Description), Valid: strings.TrimSpace(p.Description) != ""}
+	event.Location = sql.NullString{String: strings.TrimSpace(p.Location), Valid: strings.TrimSpace(p.Location) != ""}
+	event.DatetimeStart = start
+	event.DatetimeEnd = end
+	event.AllDay = p.AllDay
+
+	var key string
+	if !event.SourceID.Valid || (link == "" && (event.URL == "" || event.HasLink())) {
+		if key, err = randomKey(); err != nil {
+			return err
+		}
+	}
+
+	if !event.SourceID.Valid {
+		event.SourceID = sql.NullString{String: database.ManualSourceID(key), Valid: true}
+	}
+
+	if link != "" {
+		event.URL = link
+	} else if event.URL == "" || event.HasLink() {
+		event.URL = "manual:" + key
+	}
+
+	return nil
+}
+
+func parseFormTime(value string, allDay bool) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if allDay {
+		if len(value) > len(formDateLayout) {
+			value = value[:len(formDateLayout)]
+		}
+		return time.Parse(formDateLayout, value)
+	}
+
+	for _, layout := range []string{formDatetimeLayout, "2006-01-02T15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+func newAdminEventJSON(event *database.Event) adminEventJSON {
+	result := adminEventJSON{
+		ID:             event.ID,
+		OrganizationID: event.OrganizationID,
+		Title:          event.Title,
+		Start:          event.DatetimeStart.Format("2006-01-02T15:04:05"),
+		AllDay:         event.AllDay,
+		Hidden:         event.Hidden,
+		Highlight:      event.Highlight,
+		Scraper:        event.Scraper,
+	}
+	if event.Description.Valid {
+		result.Description = &event.Description.String
+	}
+	if event.Location.Valid {
+		result.Location = &event.Location.String
+	}
+	if event.HasLink() {
+		result.URL = &event.URL
+	}
+	if event.DatetimeEnd.Valid {
+		end := event.DatetimeEnd.Time.Format("2006-01-02T15:04:05")
+		result.End = &end
+	}
+	return result
+}
+
+// overrideValue stores a field only if it differs from the scraped value, so
+// unchanged fields keep following the source.
+func overrideValue(value, original string) sql.NullString {
+	value = strings.TrimSpace(value)
+	if value == "" || value == strings.TrimSpace(original) {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: value, Valid: true}
+}
+
+func isSameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return u.Host == r.Host
+}
+
+func randomKey() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to encode JSON response: %v", err)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,513 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/romanzipp/linke-calendar/internal/auth"
+	"github.com/romanzipp/linke-calendar/internal/database"
+)
+
+const (
+	sessionCookie   = "admin_session"
+	sessionLifetime = 14 * 24 * time.Hour
+	csrfField       = "csrf_token"
+	csrfHeader      = "X-CSRF-Token"
+)
+
+type adminContextKey struct{}
+
+// adminUser is the logged in user of an admin request with its roles per
+// organization.
+type adminUser struct {
+	*database.User
+	Roles map[int]string
+	// Session is nil for requests with HTTP Basic Auth or an API token.
+	Session *database.Session
+}
+
+// Role returns the role of the user in an organization, which is empty if
+// the user is no member.
+func (u *adminUser) Role(orgID int) string {
+	if u.Operator {
+		return database.RoleOperator
+	}
+	return u.Roles[orgID]
+}
+
+// CanEdit reports whether the user may edit events, overrides and
+// submissions of an organization.
+func (u *adminUser) CanEdit(orgID int) bool {
+	return u.Role(orgID) != ""
+}
+
+// CanManage reports whether the user may change rules, theme, members and
+// API tokens of an organization and scrape it.
+func (u *adminUser) CanManage(orgID int) bool {
+	role := u.Role(orgID)
+	return role == database.RoleOperator || role == database.RoleOrgAdmin
+}
+
+func currentAdmin(r *http.Request) *adminUser {
+	user, _ := r.Context().Value(adminContextKey{}).(*adminUser)
+	return user
+}
+
+// RequireAdmin authenticates admin requests with the session cookie, or
+// with HTTP Basic Auth or an API token for scripts using the JSON API.
+// Writes with a session need its CSRF token in a form field or the
+// X-CSRF-Token header.
+func (h *Handler) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.config.AdminEnabled() {
+			http.NotFound(w, r)
+			return
+		}
+
+		isAPI := strings.HasPrefix(r.URL.Path, "/admin/api/")
+
+		user := h.sessionUser(r)
+		if user == nil && isAPI {
+			user = h.basicAuthUser(r)
+		}
+		if user == nil && isAPI {
+			user = h.tokenUser(r)
+		}
+		if user == nil {
+			if isAPI {
+				w.Header().Set("WWW-Authenticate", `Basic realm="linke-calendar admin", charset="UTF-8"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			http.Redirect(w, r, "/admin/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+			return
+		}
+
+		write := r.Method != http.MethodGet && r.Method != http.MethodHead
+		if write && !isSameOrigin(r) {
+			http.Error(w, "Cross-origin request rejected", http.StatusForbidden)
+			return
+		}
+		if write && user.Session != nil && !validCSRFToken(r, user.Session) {
+			http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adminContextKey{}, user)))
+
+		// Admin changes are rare, so any write simply drops the read cache.
+		if write {
+			h.cache.Clear()
+		}
+	})
+}
+
+// RequireRole restricts admin routes to users with a role in the
+// organization of the route. Operators pass any check, routes without
+// organization are only open to them.
+func (h *Handler) RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := currentAdmin(r)
+			if user == nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			allowed := user.Operator
+			if role != database.RoleOperator {
+				orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+				if err != nil {
+					http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+					return
+				}
+				if role == database.RoleOrgAdmin {
+					allowed = user.CanManage(orgID)
+				} else {
+					allowed = user.CanEdit(orgID)
+				}
+			}
+
+			if !allowed {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// sessionUser returns the user of the session cookie, or nil.
+func (h *Handler) sessionUser(r *http.Request) *adminUser {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+
+	session, err := h.db.GetSession(auth.HashToken(cookie.Value), time.Now().UTC())
+	if err != nil {
+		return nil
+	}
+
+	return h.loadAdminUser(session.UserID, session)
+}
+
+func (h *Handler) basicAuthUser(r *http.Request) *adminUser {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return nil
+	}
+
+	user, ok := h.checkLogin(r, username, password)
+	if !ok {
+		return nil
+	}
+	return h.loadAdminUser(user.ID, nil)
+}
+
+// tokenUser returns an editor of the organization of an API token with the
+// events scope. It has no account, so it only passes the JSON API routes.
+func (h *Handler) tokenUser(r *http.Request) *adminUser {
+	token := h.apiToken(r)
+	if token == nil || !token.HasScope(database.ScopeEvents) {
+		return nil
+	}
+
+	return &adminUser{
+		User:  &database.User{Username: token.Name},
+		Roles: map[int]string{token.OrganizationID: database.RoleEditor},
+	}
+}
+
+func (h *Handler) loadAdminUser(userID int, session *database.Session) *adminUser {
+	user, err := h.db.GetUser(userID)
+	if err != nil {
+		return nil
+	}
+
+	memberships, err := h.db.GetMemberships(user.ID)
+	if err != nil {
+		log.Printf("Failed to get memberships of user %d: %v", user.ID, err)
+		return nil
+	}
+
+	roles := make(map[int]string, len(memberships))
+	for _, m := range memberships {
+		roles[m.OrganizationID] = m.Role
+	}
+	return &adminUser{User: user, Roles: roles, Session: session}
+}
+
+// dummyPasswordHash is checked for unknown usernames, so failed logins take
+// the same time whether the user exists or not.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := auth.HashPassword("linke-calendar")
+	return hash
+})
+
+// checkLogin verifies a username and password. Failed attempts are limited
+// per client IP.
+func (h *Handler) checkLogin(r *http.Request, username, password string) (*database.User, bool) {
+	ip := clientIP(r, h.proxies)
+	if h.logins.Limited(ip) {
+		return nil, false
+	}
+
+	user, err := h.db.GetUserByUsername(strings.TrimSpace(username))
+	if err != nil {
+		auth.CheckPassword(dummyPasswordHash(), password)
+		h.logins.Allow(ip)
+		return nil, false
+	}
+
+	if !auth.CheckPassword(user.PasswordHash, password) {
+		h.logins.Allow(ip)
+		return nil, false
+	}
+	return user, true
+}
+
+func validCSRFToken(r *http.Request, session *database.Session) bool {
+	token := r.Header.Get(csrfHeader)
+	if token == "" {
+		token = r.FormValue(csrfField)
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(session.CSRFToken)) == 1
+}
+
+// startSession logs a user in by setting a new session cookie.
+func (h *Handler) startSession(w http.ResponseWriter, r *http.Request, user *database.User) error {
+	token, err := auth.NewToken()
+	if err != nil {
+		return err
+	}
+	csrfToken, err := auth.NewToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	if err := h.db.DeleteExpiredSessions(now); err != nil {
+		log.Printf("Failed to delete expired sessions: %v", err)
+	}
+
+	session := &database.Session{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		CSRFToken: csrfToken,
+		ExpiresAt: now.Add(sessionLifetime),
+	}
+	if err := h.db.CreateSession(session); err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/admin",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.publicURL(r), "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+func (h *Handler) AdminLogin(w http.ResponseWriter, r *http.Request) {
+	if !h.config.AdminEnabled() {
+		http.NotFound(w, r)
+		return
+	}
+
+	if h.sessionUser(r) != nil {
+		http.Redirect(w, r, loginRedirect(r.URL.Query().Get("next")), http.StatusSeeOther)
+		return
+	}
+
+	h.renderAdminLogin(w, r, "", "")
+}
+
+func (h *Handler) AdminLoginPost(w http.ResponseWriter, r *http.Request) {
+	if !h.config.AdminEnabled() {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !isSameOrigin(r) {
+		http.Error(w, "Cross-origin request rejected", http.StatusForbidden)
+		return
+	}
+
+	username := strings.TrimSpace(r.FormValue("username"))
+	if h.logins.Limited(clientIP(r, h.proxies)) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		h.renderAdminLogin(w, r, username, "Zu viele fehlgeschlagene Anmeldungen. Bitte versuche es später erneut.")
+		return
+	}
+
+	user, ok := h.checkLogin(r, username, r.FormValue("password"))
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		h.renderAdminLogin(w, r, username, "Benutzername oder Passwort falsch")
+		return
+	}
+
+	if err := h.startSession(w, r, user); err != nil {
+		log.Printf("Failed to start session for user %d: %v", user.ID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, loginRedirect(r.FormValue("next")), http.StatusSeeOther)
+}
+
+func (h *Handler) AdminLogout(w http.ResponseWriter, r *http.Request) {
+	if user := currentAdmin(r); user != nil && user.Session != nil {
+		if err := h.db.DeleteSession(user.Session.TokenHash); err != nil {
+			log.Printf("Failed to delete session: %v", err)
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/admin",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+}
+
+// AdminAccount lets users change their password.
+func (h *Handler) AdminAccount(w http.ResponseWriter, r *http.Request) {
+	h.renderAdminAccount(w, r, "", false)
+}
+
+func (h *Handler) AdminUpdateAccount(w http.ResponseWriter, r *http.Request) {
+	user := currentAdmin(r)
+	if h.isConfigOperator(user.User) {
+		http.Error(w, "The password of this account is set in the config", http.StatusConflict)
+		return
+	}
+
+	if !auth.CheckPassword(user.PasswordHash, r.FormValue("current_password")) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		h.renderAdminAccount(w, r, "Aktuelles Passwort falsch", false)
+		return
+	}
+
+	password := r.FormValue("password")
+	if message := validateNewPassword(password, r.FormValue("password_confirm")); message != "" {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		h.renderAdminAccount(w, r, message, false)
+		return
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		log.Printf("Failed to hash password: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.db.UpdateUserPassword(user.ID, hash); err != nil {
+		log.Printf("Failed to update password of user %d: %v", user.ID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Other sessions, e.g. on a lost device, end with the old password.
+	keep := ""
+	if user.Session != nil {
+		keep = user.Session.TokenHash
+	}
+	if err := h.db.DeleteUserSessions(user.ID, keep); err != nil {
+		log.Printf("Failed to delete sessions of user %d: %v", user.ID, err)
+	}
+
+	h.renderAdminAccount(w, r, "", true)
+}
+
+// isConfigOperator reports whether user is the account of admin.username,
+// whose password follows the config.
+func (h *Handler) isConfigOperator(user *database.User) bool {
+	return strings.EqualFold(user.Username, h.config.GetAdminUsername())
+}
+
+func (h *Handler) renderAdminLogin(w http.ResponseWriter, r *http.Request, username, formError string) {
+	data := struct {
+		Username string
+		Next     string
+		Error    string
+		Version  string
+	}{
+		Username: username,
+		Next:     loginRedirect(r.FormValue("next")),
+		Error:    formError,
+		Version:  h.version,
+	}
+
+	h.renderAdmin(w, r, "admin-login.html", data)
+}
+
+func (h *Handler) renderAdminAccount(w http.ResponseWriter, r *http.Request, formError string, saved bool) {
+	user := currentAdmin(r)
+
+	memberships, err := h.db.GetMemberships(user.ID)
+	if err != nil {
+		log.Printf("Failed to get memberships of user %d: %v", user.ID, err)
+	}
+
+	titles := make(map[int]string, len(memberships))
+	for _, m := range memberships {
+		org, err := h.db.GetOrganization(m.OrganizationID)
+		if err != nil {
+			titles[m.OrganizationID] = strconv.Itoa(m.OrganizationID)
+			continue
+		}
+		titles[m.OrganizationID] = getOrganizationTitle(org)
+	}
+
+	data := struct {
+		Memberships   []*database.Membership
+		Titles        map[int]string
+		ConfigManaged bool
+		Error         string
+		Saved         bool
+		Version       string
+	}{
+		Memberships:   memberships,
+		Titles:        titles,
+		ConfigManaged: h.isConfigOperator(user.User),
+		Error:         formError,
+		Saved:         saved,
+		Version:       h.version,
+	}
+
+	h.renderAdmin(w, r, "admin-account.html", data)
+}
+
+// renderAdmin executes an admin template with the functions of the current
+// user: adminUser returns it and csrfField the hidden CSRF input of its
+// session, which every form posting to the admin area needs.
+func (h *Handler) renderAdmin(w http.ResponseWriter, r *http.Request, name string, data interface{}) {
+	tmpl, err := h.admin.Clone()
+	if err != nil {
+		log.Printf("Failed to clone admin templates: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	user := currentAdmin(r)
+	if user == nil {
+		user = h.sessionUser(r)
+	}
+
+	tmpl.Funcs(template.FuncMap{
+		"adminUser": func() *adminUser { return user },
+		"csrfField": func() template.HTML {
+			if user == nil || user.Session == nil {
+				return ""
+			}
+			return template.HTML(fmt.Sprintf(`<input type="hidden" name="%s" value="%s">`,
+				csrfField, template.HTMLEscapeString(user.Session.CSRFToken)))
+		},
+	})
+
+	if err := tmpl.ExecuteTemplate(w, name, data); err != nil {
+		log.Printf("Failed to render %s: %v", name, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// loginRedirect only allows redirects within the admin area.
+func loginRedirect(next string) string {
+	if !strings.HasPrefix(next, "/admin/") || strings.HasPrefix(next, "/admin/login") {
+		return "/admin/"
+	}
+	return next
+}
+
+func validateNewPassword(password, confirm string) string {
+	if err := auth.ValidatePassword(password); errors.Is(err, auth.ErrPasswordTooShort) {
+		return fmt.Sprintf("Das Passwort muss mindestens %d Zeichen lang sein", auth.MinPasswordLength)
+	} else if err != nil {
+		return "Ungültiges Passwort"
+	}
+	if password != confirm {
+		return "Die Passwörter stimmen nicht überein"
+	}
+	return ""
+}
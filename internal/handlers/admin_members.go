@@ -0,0 +1,524 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/romanzipp/linke-calendar/internal/auth"
+	"github.com/romanzipp/linke-calendar/internal/database"
+)
+
+const inviteLifetime = 14 * 24 * time.Hour
+
+var usernamePattern = regexp.MustCompile(`^[\p{L}\p{N}._@-]{2,64}$`)
+
+// roleLabels names the roles in the admin area.
+var roleLabels = map[string]string{
+	database.RoleOperator: "Betrieb",
+	database.RoleOrgAdmin: "Admin",
+	database.RoleEditor:   "Redaktion",
+}
+
+type adminUserRow struct {
+	User          *database.User
+	Memberships   []*database.Membership
+	ConfigManaged bool
+}
+
+// AdminMembers lists the members and open invites of an organization.
+func (h *Handler) AdminMembers(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	members, err := h.db.GetOrganizationMembers(orgID)
+	if err != nil {
+		log.Printf("Failed to get members of organization %d: %v", orgID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	invites, err := h.db.GetOpenInvites(orgID, time.Now().UTC())
+	if err != nil {
+		log.Printf("Failed to get invites of organization %d: %v", orgID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	org, err := h.db.GetOrganization(orgID)
+	if err != nil {
+		log.Printf("Failed to get organization %d: %v", orgID, err)
+	}
+
+	data := struct {
+		OrganizationID    int
+		OrganizationTitle string
+		Members           []*database.Membership
+		Invites           []*database.Invite
+		Version           string
+	}{
+		OrganizationID:    orgID,
+		OrganizationTitle: getOrganizationTitle(org),
+		Members:           members,
+		Invites:           invites,
+		Version:           h.version,
+	}
+
+	h.renderAdmin(w, r, "admin-members.html", data)
+}
+
+func (h *Handler) AdminUpdateMember(w http.ResponseWriter, r *http.Request) {
+	orgID, userID, ok := memberParams(w, r)
+	if !ok {
+		return
+	}
+
+	role := r.FormValue("role")
+	if role != database.RoleOrgAdmin && role != database.RoleEditor {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.UpdateMembershipRole(userID, orgID, role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Member not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to update membership of user %d in organization %d: %v", userID, orgID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/org/%d/members", orgID), http.StatusSeeOther)
+}
+
+func (h *Handler) AdminRemoveMember(w http.ResponseWriter, r *http.Request) {
+	orgID, userID, ok := memberParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteMembership(userID, orgID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Member not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to remove user %d from organization %d: %v", userID, orgID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if userID == currentAdmin(r).ID && !currentAdmin(r).Operator {
+		http.Redirect(w, r, "/admin/", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/admin/org/%d/members", orgID), http.StatusSeeOther)
+}
+
+// AdminCreateInvite creates an invite to an organization and shows it once
+// as a printable page, only its hash is stored.
+func (h *Handler) AdminCreateInvite(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	role := r.FormValue("role")
+	if role != database.RoleOrgAdmin && role != database.RoleEditor {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	h.createInvite(w, r, sql.NullInt64{Int64: int64(orgID), Valid: true}, role)
+}
+
+func (h *Handler) AdminRevokeInvite(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	inviteID, err := strconv.Atoi(chi.URLParam(r, "inviteID"))
+	if err != nil {
+		http.Error(w, "Invalid invite ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.DeleteInvite(inviteID, orgID); err != nil {
+		log.Printf("Failed to delete invite %d: %v", inviteID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/org/%d/members", orgID), http.StatusSeeOther)
+}
+
+// AdminUsers lists all accounts with their memberships and the open
+// operator invites.
+func (h *Handler) AdminUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.db.GetAllUsers()
+	if err != nil {
+		log.Printf("Failed to get users: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	memberships, err := h.db.GetAllMemberships()
+	if err != nil {
+		log.Printf("Failed to get memberships: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	invites, err := h.db.GetOpenInvites(0, time.Now().UTC())
+	if err != nil {
+		log.Printf("Failed to get operator invites: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	orgs, err := h.db.GetAllOrganizations()
+	if err != nil {
+		log.Printf("Failed to get organizations: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	titles := make(map[int]string, len(orgs))
+	for _, org := range orgs {
+		titles[org.ID] = getOrganizationTitle(org)
+	}
+
+	byUser := make(map[int][]*database.Membership)
+	for _, m := range memberships {
+		byUser[m.UserID] = append(byUser[m.UserID], m)
+		if _, ok := titles[m.OrganizationID]; !ok {
+			titles[m.OrganizationID] = strconv.Itoa(m.OrganizationID)
+		}
+	}
+
+	rows := make([]adminUserRow, 0, len(users))
+	for _, user := range users {
+		rows = append(rows, adminUserRow{
+			User:          user,
+			Memberships:   byUser[user.ID],
+			ConfigManaged: h.isConfigOperator(user),
+		})
+	}
+
+	data := struct {
+		Users   []adminUserRow
+		Invites []*database.Invite
+		Titles  map[int]string
+		Version string
+	}{
+		Users:   rows,
+		Invites: invites,
+		Titles:  titles,
+		Version: h.version,
+	}
+
+	h.renderAdmin(w, r, "admin-users.html", data)
+}
+
+func (h *Handler) AdminCreateOperatorInvite(w http.ResponseWriter, r *http.Request) {
+	h.createInvite(w, r, sql.NullInt64{}, database.RoleOperator)
+}
+
+func (h *Handler) AdminRevokeOperatorInvite(w http.ResponseWriter, r *http.Request) {
+	inviteID, err := strconv.Atoi(chi.URLParam(r, "inviteID"))
+	if err != nil {
+		http.Error(w, "Invalid invite ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.DeleteInvite(inviteID, 0); err != nil {
+		log.Printf("Failed to delete invite %d: %v", inviteID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+func (h *Handler) AdminDeleteUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.db.GetUser(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	// The config account would be created again on the next start.
+	if user.ID == currentAdmin(r).ID || h.isConfigOperator(user) {
+		http.Error(w, "This account cannot be deleted", http.StatusConflict)
+		return
+	}
+
+	if err := h.db.DeleteUser(user.ID); err != nil {
+		log.Printf("Failed to delete user %d: %v", user.ID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Deleted user %s", user.Username)
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+func (h *Handler) createInvite(w http.ResponseWriter, r *http.Request, orgID sql.NullInt64, role string) {
+	code, err := auth.NewInviteCode()
+	if err != nil {
+		log.Printf("Failed to create invite: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	normalized, err := auth.NormalizeInviteCode(code)
+	if err != nil {
+		log.Printf("Failed to create invite: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	invite := &database.Invite{
+		TokenHash:      auth.HashToken(normalized),
+		OrganizationID: orgID,
+		Role:           role,
+		CreatedBy:      currentAdmin(r).ID,
+		ExpiresAt:      time.Now().UTC().Add(inviteLifetime),
+	}
+	if err := h.db.CreateInvite(invite); err != nil {
+		log.Printf("Failed to create invite: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	title := ""
+	back := "/admin/users"
+	if orgID.Valid {
+		org, err := h.db.GetOrganization(int(orgID.Int64))
+		if err != nil {
+			log.Printf("Failed to get organization %d: %v", orgID.Int64, err)
+		}
+		title = getOrganizationTitle(org)
+		back = fmt.Sprintf("/admin/org/%d/members", orgID.Int64)
+	}
+
+	base := h.publicURL(r)
+	data := struct {
+		Invite            *database.Invite
+		OrganizationTitle string
+		Code              string
+		Link              string
+		EntryURL          string
+		Back              string
+		Version           string
+	}{
+		Invite:            invite,
+		OrganizationTitle: title,
+		Code:              code,
+		Link:              base + "/admin/invite/" + code,
+		EntryURL:          base + "/admin/invite",
+		Back:              back,
+		Version:           h.version,
+	}
+
+	h.renderAdmin(w, r, "admin-invite-created.html", data)
+}
+
+// AdminInvite shows an invite, with a form to create an account or to
+// accept it with the logged in account. Without code it asks for one, as
+// typed from a printout.
+func (h *Handler) AdminInvite(w http.ResponseWriter, r *http.Request) {
+	if !h.config.AdminEnabled() {
+		http.NotFound(w, r)
+		return
+	}
+
+	code := chi.URLParam(r, "code")
+	if code == "" {
+		if typed := strings.TrimSpace(r.URL.Query().Get("code")); typed != "" {
+			http.Redirect(w, r, "/admin/invite/"+url.PathEscape(typed), http.StatusSeeOther)
+			return
+		}
+		h.renderAdmin(w, r, "admin-invite.html", h.invitePage(r, "", nil, ""))
+		return
+	}
+
+	invite, ok := h.openInvite(code)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		h.renderAdmin(w, r, "admin-invite.html", h.invitePage(r, code, nil, "Die Einladung ist ungültig, abgelaufen oder wurde schon verwendet."))
+		return
+	}
+
+	h.renderAdmin(w, r, "admin-invite.html", h.invitePage(r, code, invite, ""))
+}
+
+// AdminAcceptInvite accepts an invite with the logged in account, or with a
+// new account that is logged in right away.
+func (h *Handler) AdminAcceptInvite(w http.ResponseWriter, r *http.Request) {
+	if !h.config.AdminEnabled() {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !isSameOrigin(r) {
+		http.Error(w, "Cross-origin request rejected", http.StatusForbidden)
+		return
+	}
+
+	code := chi.URLParam(r, "code")
+	invite, ok := h.openInvite(code)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		h.renderAdmin(w, r, "admin-invite.html", h.invitePage(r, code, nil, "Die Einladung ist ungültig, abgelaufen oder wurde schon verwendet."))
+		return
+	}
+
+	redirect := "/admin/"
+	if invite.OrganizationID.Valid {
+		redirect = fmt.Sprintf("/admin/org/%d/events", invite.OrganizationID.Int64)
+	}
+
+	if current := h.sessionUser(r); current != nil {
+		if !validCSRFToken(r, current.Session) {
+			http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+			return
+		}
+		if err := h.db.AcceptInvite(invite, current.User, time.Now().UTC()); err != nil {
+			h.inviteFailed(w, r, code, invite, err)
+			return
+		}
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+		return
+	}
+
+	page := h.invitePage(r, code, invite, "")
+	page.Username = strings.TrimSpace(r.FormValue("username"))
+
+	if !usernamePattern.MatchString(page.Username) {
+		page.Error = "Der Benutzername muss 2 bis 64 Zeichen lang sein und darf nur Buchstaben, Ziffern und . _ @ - enthalten"
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		h.renderAdmin(w, r, "admin-invite.html", page)
+		return
+	}
+
+	password := r.FormValue("password")
+	if message := validateNewPassword(password, r.FormValue("password_confirm")); message != "" {
+		page.Error = message
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		h.renderAdmin(w, r, "admin-invite.html", page)
+		return
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		log.Printf("Failed to hash password: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	user := &database.User{Username: page.Username, PasswordHash: hash}
+	if err := h.db.AcceptInvite(invite, user, time.Now().UTC()); err != nil {
+		if errors.Is(err, database.ErrUsernameTaken) {
+			page.Error = "Der Benutzername ist schon vergeben. Wenn es dein Konto ist, melde dich an und nimm die Einladung danach an."
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			h.renderAdmin(w, r, "admin-invite.html", page)
+			return
+		}
+		h.inviteFailed(w, r, code, invite, err)
+		return
+	}
+
+	log.Printf("Created user %s from invite %d", user.Username, invite.ID)
+
+	if err := h.startSession(w, r, user); err != nil {
+		log.Printf("Failed to start session for user %d: %v", user.ID, err)
+		http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+type invitePage struct {
+	Code              string
+	Invite            *database.Invite
+	OrganizationTitle string
+	Username          string
+	Error             string
+	Version           string
+}
+
+func (h *Handler) invitePage(r *http.Request, code string, invite *database.Invite, pageError string) invitePage {
+	page := invitePage{
+		Code:    code,
+		Invite:  invite,
+		Error:   pageError,
+		Version: h.version,
+	}
+	if invite != nil && invite.OrganizationID.Valid {
+		org, err := h.db.GetOrganization(int(invite.OrganizationID.Int64))
+		if err != nil {
+			log.Printf("Failed to get organization %d: %v", invite.OrganizationID.Int64, err)
+		}
+		page.OrganizationTitle = getOrganizationTitle(org)
+	}
+	return page
+}
+
+func (h *Handler) openInvite(code string) (*database.Invite, bool) {
+	normalized, err := auth.NormalizeInviteCode(code)
+	if err != nil {
+		return nil, false
+	}
+
+	invite, err := h.db.GetOpenInvite(auth.HashToken(normalized), time.Now().UTC())
+	if err != nil {
+		return nil, false
+	}
+	return invite, true
+}
+
+func (h *Handler) inviteFailed(w http.ResponseWriter, r *http.Request, code string, invite *database.Invite, err error) {
+	if errors.Is(err, database.ErrInviteInvalid) {
+		w.WriteHeader(http.StatusConflict)
+		h.renderAdmin(w, r, "admin-invite.html", h.invitePage(r, code, nil, "Die Einladung ist ungültig, abgelaufen oder wurde schon verwendet."))
+		return
+	}
+	log.Printf("Failed to accept invite %d: %v", invite.ID, err)
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+}
+
+func memberParams(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	userID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	return orgID, userID, true
+}
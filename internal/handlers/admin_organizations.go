@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/romanzipp/linke-calendar/internal/database"
+	"github.com/romanzipp/linke-calendar/internal/scraper"
+)
+
+const adminRecentErrors = 20
+
+type adminOrganization struct {
+	Organization       *database.Organization
+	Title              string
+	Counts             database.EventCounts
+	PendingSubmissions int
+	LastError          *database.ScrapeError
+}
+
+// AdminDashboard lists the organizations of the user with their scrape
+// status, all of them for operators.
+func (h *Handler) AdminDashboard(w http.ResponseWriter, r *http.Request) {
+	user := currentAdmin(r)
+
+	orgs, err := h.db.GetAllOrganizations()
+	if err != nil {
+		log.Printf("Failed to get organizations: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	counts, err := h.db.GetEventCounts(startOfDay(time.Now()))
+	if err != nil {
+		log.Printf("Failed to count events: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	recentErrors, err := h.db.GetRecentScrapeErrors(adminRecentErrors)
+	if err != nil {
+		log.Printf("Failed to get scrape errors: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	lastErrors := make(map[int]*database.ScrapeError)
+	visibleErrors := recentErrors[:0]
+	for _, scrapeError := range recentErrors {
+		if !user.CanEdit(scrapeError.OrganizationID) {
+			continue
+		}
+		visibleErrors = append(visibleErrors, scrapeError)
+		if _, ok := lastErrors[scrapeError.OrganizationID]; !ok {
+			lastErrors[scrapeError.OrganizationID] = scrapeError
+		}
+	}
+
+	rows := make([]adminOrganization, 0, len(orgs))
+	titles := make(map[int]string, len(orgs))
+	for _, org := range orgs {
+		if !user.CanEdit(org.ID) {
+			continue
+		}
+
+		pending, err := h.db.CountPendingSubmissions(org.ID)
+		if err != nil {
+			log.Printf("Failed to count submissions for organization %d: %v", org.ID, err)
+		}
+
+		titles[org.ID] = getOrganizationTitle(org)
+		rows = append(rows, adminOrganization{
+			Organization:       org,
+			Title:              titles[org.ID],
+			Counts:             counts[org.ID],
+			PendingSubmissions: pending,
+			LastError:          lastErrors[org.ID],
+		})
+	}
+
+	data := struct {
+		Organizations []adminOrganization
+		RecentErrors  []*database.ScrapeError
+		Titles        map[int]string
+		Version       string
+	}{
+		Organizations: rows,
+		RecentErrors:  visibleErrors,
+		Titles:        titles,
+		Version:       h.version,
+	}
+
+	h.renderAdmin(w, r, "admin-dashboard.html", data)
+}
+
+// AdminScrapeOrganization scrapes an organization right away, also if it
+// is paused.
+func (h *Handler) AdminScrapeOrganization(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	// The scrape runs in the background, failures are recorded for the
+	// organization and shown on the dashboard.
+	if _, err := h.scraper.Enqueue(orgID, sql.NullInt64{}); err != nil {
+		if errors.Is(err, scraper.ErrQueueFull) {
+			http.Error(w, "Too many queued scrapes, try again later", http.StatusServiceUnavailable)
+			return
+		}
+		log.Printf("Failed to queue scrape of organization %d: %v", orgID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+func (h *Handler) AdminPauseOrganization(w http.ResponseWriter, r *http.Request) {
+	h.setOrganizationPaused(w, r, true)
+}
+
+func (h *Handler) AdminResumeOrganization(w http.ResponseWriter, r *http.Request) {
+	h.setOrganizationPaused(w, r, false)
+}
+
+func (h *Handler) setOrganizationPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.SetOrganizationPaused(orgID, paused); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Organization not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to update organization %d: %v", orgID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+func (h *Handler) AdminDeleteOrganization(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.DeleteOrganization(orgID); err != nil {
+		log.Printf("Failed to delete organization %d: %v", orgID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Deleted organization %d", orgID)
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
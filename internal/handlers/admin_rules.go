@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/romanzipp/linke-calendar/internal/database"
+	"github.com/romanzipp/linke-calendar/internal/rules"
+)
+
+type rulePreview struct {
+	Rule    *database.Rule
+	Matches []*database.Event
+}
+
+func (h *Handler) AdminRules(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	h.renderAdminRules(w, r, orgID, &database.Rule{Field: database.RuleFieldTitle, Action: database.RuleActionHide}, "")
+}
+
+func (h *Handler) AdminCreateRule(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	rule := &database.Rule{
+		OrganizationID: orgID,
+		Field:          r.FormValue("field"),
+		Pattern:        strings.TrimSpace(r.FormValue("pattern")),
+		Regex:          r.FormValue("regex") != "",
+		Action:         r.FormValue("action"),
+	}
+
+	if err := rules.Validate(rule); err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		h.renderAdminRules(w, r, orgID, rule, ruleErrorMessage(err))
+		return
+	}
+
+	if err := h.db.CreateRule(rule); err != nil {
+		log.Printf("Failed to create rule for organization %d: %v", orgID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/org/%d/rules", orgID), http.StatusSeeOther)
+}
+
+func (h *Handler) AdminDeleteRule(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	ruleID, err := strconv.Atoi(chi.URLParam(r, "ruleID"))
+	if err != nil {
+		http.Error(w, "Invalid rule ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.DeleteRule(orgID, ruleID); err != nil {
+		log.Printf("Failed to delete rule %d: %v", ruleID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/org/%d/rules", orgID), http.StatusSeeOther)
+}
+
+func (h *Handler) renderAdminRules(w http.ResponseWriter, r *http.Request, orgID int, form *database.Rule, formError string) {
+	orgRules, err := h.db.GetRulesByOrganization(orgID)
+	if err != nil {
+		log.Printf("Failed to get rules for organization %d: %v", orgID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	events, err := h.db.GetAllUpcomingEventsByOrganization(orgID)
+	if err != nil {
+		log.Printf("Failed to get upcoming events: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	previews := make([]rulePreview, 0, len(orgRules))
+	for _, rule := range orgRules {
+		preview := rulePreview{Rule: rule}
+		for _, event := range events {
+			if rules.Matches(rule, event) {
+				preview.Matches = append(preview.Matches, event)
+			}
+		}
+		previews = append(previews, preview)
+	}
+
+	org, err := h.db.GetOrganization(orgID)
+	if err != nil {
+		log.Printf("Failed to get organization %d: %v", orgID, err)
+	}
+
+	data := struct {
+		OrganizationID    int
+		OrganizationTitle string
+		Rules             []rulePreview
+		Form              *database.Rule
+		Error             string
+		Version           string
+	}{
+		OrganizationID:    orgID,
+		OrganizationTitle: getOrganizationTitle(org),
+		Rules:             previews,
+		Form:              form,
+		Error:             formError,
+		Version:           h.version,
+	}
+
+	h.renderAdmin(w, r, "admin-rules.html", data)
+}
+
+// ruleErrorMessage returns the German message of a rules.Validate error.
+func ruleErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, rules.ErrUnknownField):
+		return "Unbekanntes Feld"
+	case errors.Is(err, rules.ErrUnknownAction):
+		return "Unbekannte Aktion"
+	case errors.Is(err, rules.ErrMissingPattern):
+		return "Zum Ausblenden von Terminen wird ein Muster benötigt"
+	case errors.Is(err, rules.ErrInvalidRegex):
+		return "Ungültiger regulärer Ausdruck"
+	default:
+		return "Ungültige Regel"
+	}
+}
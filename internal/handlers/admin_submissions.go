@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/romanzipp/linke-calendar/internal/database"
+)
+
+func (h *Handler) AdminSubmissions(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	submissions, err := h.db.GetPendingSubmissionsByOrganization(orgID)
+	if err != nil {
+		log.Printf("Failed to get submissions for organization %d: %v", orgID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	org, err := h.db.GetOrganization(orgID)
+	if err != nil {
+		log.Printf("Failed to get organization %d: %v", orgID, err)
+	}
+
+	data := struct {
+		OrganizationID    int
+		OrganizationTitle string
+		Submissions       []*database.Submission
+		Version           string
+	}{
+		OrganizationID:    orgID,
+		OrganizationTitle: getOrganizationTitle(org),
+		Submissions:       submissions,
+		Version:           h.version,
+	}
+
+	h.renderAdmin(w, r, "admin-submissions.html", data)
+}
+
+func (h *Handler) AdminSubmission(w http.ResponseWriter, r *http.Request) {
+	submission, ok := h.adminPendingSubmission(w, r)
+	if !ok {
+		return
+	}
+
+	h.renderAdminSubmission(w, r, submission, eventPayloadFromEvent(submission.Event()), "")
+}
+
+// AdminModerateSubmission approves or rejects a submission. Approval applies
+// the edited form and publishes it as a manual event.
+func (h *Handler) AdminModerateSubmission(w http.ResponseWriter, r *http.Request) {
+	submission, ok := h.adminPendingSubmission(w, r)
+	if !ok {
+		return
+	}
+
+	redirect := fmt.Sprintf("/admin/org/%d/submissions", submission.OrganizationID)
+
+	if r.FormValue("decision") == "reject" {
+		submission.Status = database.SubmissionRejected
+		if err := h.db.UpdateSubmission(submission); err != nil {
+			if errors.Is(err, database.ErrSubmissionModerated) {
+				http.Error(w, "Submission has already been moderated", http.StatusConflict)
+				return
+			}
+			log.Printf("Failed to reject submission %d: %v", submission.ID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+		return
+	}
+
+	payload := eventPayloadFromForm(r)
+	event := &database.Event{OrganizationID: submission.OrganizationID, Scraper: database.ScraperManual}
+	if err := payload.apply(event); err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		h.renderAdminSubmission(w, r, submission, payload, err.Error())
+		return
+	}
+
+	submission.Title = event.Title
+	submission.Description = event.Description
+	submission.DatetimeStart = event.DatetimeStart
+	submission.DatetimeEnd = event.DatetimeEnd
+	submission.AllDay = event.AllDay
+	submission.Location = event.Location
+	submission.URL = sql.NullString{String: event.URL, Valid: event.HasLink()}
+
+	if err := h.db.ApproveSubmission(submission, event); err != nil {
+		switch {
+		case errors.Is(err, database.ErrDuplicateURL):
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			h.renderAdminSubmission(w, r, submission, payload, duplicateLinkError)
+		case errors.Is(err, database.ErrSubmissionModerated):
+			http.Error(w, "Submission has already been moderated", http.StatusConflict)
+		default:
+			log.Printf("Failed to approve submission %d: %v", submission.ID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+func (h *Handler) adminPendingSubmission(w http.ResponseWriter, r *http.Request) (*database.Submission, bool) {
+	submissionID, err := strconv.Atoi(chi.URLParam(r, "submissionID"))
+	if err != nil {
+		http.Error(w, "Invalid submission ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	submission, err := h.db.GetSubmission(submissionID)
+	if err != nil {
+		http.Error(w, "Submission not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	if !currentAdmin(r).CanEdit(submission.OrganizationID) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, false
+	}
+
+	if submission.Status != database.SubmissionPending {
+		http.Error(w, "Submission has already been moderated", http.StatusConflict)
+		return nil, false
+	}
+
+	return submission, true
+}
+
+func (h *Handler) renderAdminSubmission(w http.ResponseWriter, r *http.Request, submission *database.Submission, form eventPayload, formError string) {
+	data := struct {
+		Submission *database.Submission
+		Form       eventPayload
+		Error      string
+		Version    string
+	}{
+		Submission: submission,
+		Form:       form,
+		Error:      formError,
+		Version:    h.version,
+	}
+
+	h.renderAdmin(w, r, "admin-submission.html", data)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/romanzipp/linke-calendar/internal/database"
+	"github.com/romanzipp/linke-calendar/internal/rules"
+)
+
+func TestWritePayloadError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		want   string
+	}{
+		{"title", payloadError("form.error.title"), http.StatusUnprocessableEntity, "Title is missing"},
+		{"end before start", payloadError("form.error.end_before_start"), http.StatusUnprocessableEntity, "End is before the start"},
+		{"wrapped", errors.Join(payloadError("form.error.link")), http.StatusUnprocessableEntity, "Invalid link"},
+		{"other error", errors.New("no randomness"), http.StatusInternalServerError, "Internal server error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writePayloadError(w, tt.err)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			var body map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if body["error"] != tt.want {
+				t.Errorf("error = %q, want %q", body["error"], tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleErrorMessage(t *testing.T) {
+	tests := []struct {
+		rule database.Rule
+		want string
+	}{
+		{database.Rule{Field: "url", Pattern: "a", Action: database.RuleActionHide}, "Unbekanntes Feld"},
+		{database.Rule{Field: database.RuleFieldTitle, Pattern: "a", Action: "delete"}, "Unbekannte Aktion"},
+		{database.Rule{Field: database.RuleFieldAny, Action: database.RuleActionHide}, "Zum Ausblenden von Terminen wird ein Muster benötigt"},
+		{database.Rule{Field: database.RuleFieldTitle, Pattern: "(", Regex: true, Action: database.RuleActionHide}, "Ungültiger regulärer Ausdruck"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			err := rules.Validate(&tt.rule)
+			if err == nil {
+				t.Fatal("Validate() accepted the rule")
+			}
+			if got := ruleErrorMessage(err); got != tt.want {
+				t.Errorf("ruleErrorMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
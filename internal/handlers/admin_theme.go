@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/romanzipp/linke-calendar/internal/config"
+	"github.com/romanzipp/linke-calendar/internal/database"
+)
+
+// AdminTheme edits the look of the embedded pages of an organization. Saved
+// values take precedence over the config, query params over both.
+func (h *Handler) AdminTheme(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	theme, err := h.db.GetOrganizationTheme(orgID)
+	if err != nil {
+		log.Printf("Failed to get theme of organization %d: %v", orgID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.renderAdminTheme(w, r, orgID, theme, "", r.URL.Query().Has("saved"))
+}
+
+func (h *Handler) AdminUpdateTheme(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	theme := &database.OrganizationTheme{
+		OrganizationID: orgID,
+		Primary:        strings.TrimSpace(r.FormValue("primary")),
+		Text:           strings.TrimSpace(r.FormValue("text")),
+		Font:           r.FormValue("font"),
+		Density:        r.FormValue("density"),
+		Mode:           r.FormValue("mode"),
+	}
+
+	if err := validateOrganizationTheme(theme); err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		h.renderAdminTheme(w, r, orgID, theme, err.Error(), false)
+		return
+	}
+
+	if err := h.db.UpsertOrganizationTheme(theme); err != nil {
+		log.Printf("Failed to save theme of organization %d: %v", orgID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/org/%d/theme?saved", orgID), http.StatusSeeOther)
+}
+
+// validateOrganizationTheme checks a theme from the form. Colors may be
+// entered without "#".
+func validateOrganizationTheme(theme *database.OrganizationTheme) error {
+	for _, color := range []*string{&theme.Primary, &theme.Text} {
+		if *color == "" {
+			continue
+		}
+		if v := themeColorParam(*color); v != "" {
+			*color = v
+			continue
+		}
+		return errors.New("Ungültige Farbe, bitte als #rgb oder #rrggbb angeben")
+	}
+
+	if theme.Font != "" && !config.ValidThemeOption(theme.Font, config.ThemeFonts) {
+		return errors.New("Ungültige Schriftart")
+	}
+	if theme.Density != "" && !config.ValidThemeOption(theme.Density, config.ThemeDensities) {
+		return errors.New("Ungültige Dichte")
+	}
+	if theme.Mode != "" && !config.ValidThemeOption(theme.Mode, config.ThemeModes) {
+		return errors.New("Ungültiger Modus")
+	}
+	return nil
+}
+
+func (h *Handler) renderAdminTheme(w http.ResponseWriter, r *http.Request, orgID int, form *database.OrganizationTheme, formError string, saved bool) {
+	org, err := h.db.GetOrganization(orgID)
+	if err != nil {
+		log.Printf("Failed to get organization %d: %v", orgID, err)
+	}
+
+	data := struct {
+		OrganizationID    int
+		OrganizationTitle string
+		Form              *database.OrganizationTheme
+		Configured        config.Theme
+		Fonts             []string
+		Densities         []string
+		Modes             []string
+		Error             string
+		Saved             bool
+		Version           string
+	}{
+		OrganizationID:    orgID,
+		OrganizationTitle: getOrganizationTitle(org),
+		Form:              form,
+		Configured:        h.config.GetTheme(orgID),
+		Fonts:             config.ThemeFonts,
+		Densities:         config.ThemeDensities,
+		Modes:             config.ThemeModes,
+		Error:             formError,
+		Saved:             saved,
+		Version:           h.version,
+	}
+
+	h.renderAdmin(w, r, "admin-theme.html", data)
+}
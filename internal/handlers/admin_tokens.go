@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/romanzipp/linke-calendar/internal/auth"
+	"github.com/romanzipp/linke-calendar/internal/database"
+)
+
+// adminRecentRuns is the number of scrape runs shown with the tokens.
+const adminRecentRuns = 10
+
+// scopeLabels names the scopes of API tokens in the admin area.
+var scopeLabels = map[string]string{
+	database.ScopeRefresh: "Abruf auslösen",
+	database.ScopeEvents:  "Termine verwalten",
+}
+
+// runLabels names the states of scrape runs in the admin area.
+var runLabels = map[string]string{
+	database.RunStatusQueued:   "Wartet",
+	database.RunStatusRunning:  "Läuft",
+	database.ScrapeStatusOK:    "OK",
+	database.ScrapeStatusError: "Fehler",
+}
+
+type tokenForm struct {
+	Name   string
+	Scopes []string
+}
+
+// HasScope is used by the template to keep checkboxes checked.
+func (f tokenForm) HasScope(scope string) bool {
+	for _, s := range f.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminTokens lists the API tokens of an organization and its latest
+// scrape runs.
+func (h *Handler) AdminTokens(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	h.renderAdminTokens(w, r, orgID, tokenForm{Scopes: []string{database.ScopeRefresh}}, "")
+}
+
+// AdminCreateToken creates an API token and shows it once, only its hash is
+// stored.
+func (h *Handler) AdminCreateToken(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+
+	form := tokenForm{
+		Name:   strings.TrimSpace(r.FormValue("name")),
+		Scopes: r.Form["scope"],
+	}
+
+	if formError := validateTokenForm(form); formError != "" {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		h.renderAdminTokens(w, r, orgID, form, formError)
+		return
+	}
+
+	secret, err := auth.NewAPIToken()
+	if err != nil {
+		log.Printf("Failed to create API token: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	token := &database.APIToken{
+		OrganizationID: orgID,
+		Name:           form.Name,
+		TokenHash:      auth.HashToken(secret),
+		Scopes:         form.Scopes,
+		CreatedBy:      currentAdmin(r).ID,
+	}
+	if err := h.db.CreateAPIToken(token); err != nil {
+		log.Printf("Failed to create API token: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	org, err := h.db.GetOrganization(orgID)
+	if err != nil {
+		log.Printf("Failed to get organization %d: %v", orgID, err)
+	}
+
+	data := struct {
+		OrganizationID    int
+		OrganizationTitle string
+		Token             *database.APIToken
+		Secret            string
+		RefreshURL        string
+		EventsURL         string
+		Version           string
+	}{
+		OrganizationID:    orgID,
+		OrganizationTitle: getOrganizationTitle(org),
+		Token:             token,
+		Secret:            secret,
+		RefreshURL:        fmt.Sprintf("%s/api/v1/orgs/%d/refresh", h.publicURL(r), orgID),
+		EventsURL:         fmt.Sprintf("%s/admin/api/org/%d/events", h.publicURL(r), orgID),
+		Version:           h.version,
+	}
+
+	h.renderAdmin(w, r, "admin-token-created.html", data)
+}
+
+func (h *Handler) AdminRevokeToken(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	tokenID, err := strconv.Atoi(chi.URLParam(r, "tokenID"))
+	if err != nil {
+		http.Error(w, "Invalid token ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.DeleteAPIToken(tokenID, orgID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Token not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to delete API token %d: %v", tokenID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/org/%d/tokens", orgID), http.StatusSeeOther)
+}
+
+func validateTokenForm(form tokenForm) string {
+	if form.Name == "" {
+		return "Name fehlt"
+	}
+	if utf8.RuneCountInString(form.Name) > 100 {
+		return "Der Name darf höchstens 100 Zeichen lang sein"
+	}
+	if len(form.Scopes) == 0 {
+		return "Bitte mindestens eine Berechtigung auswählen"
+	}
+	for _, scope := range form.Scopes {
+		if _, ok := scopeLabels[scope]; !ok {
+			return "Ungültige Berechtigung"
+		}
+	}
+	return ""
+}
+
+func (h *Handler) renderAdminTokens(w http.ResponseWriter, r *http.Request, orgID int, form tokenForm, formError string) {
+	tokens, err := h.db.GetAPITokens(orgID)
+	if err != nil {
+		log.Printf("Failed to get API tokens of organization %d: %v", orgID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	runs, err := h.db.GetScrapeRuns(orgID, adminRecentRuns)
+	if err != nil {
+		log.Printf("Failed to get scrape runs of organization %d: %v", orgID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	tokenNames := make(map[int64]string, len(tokens))
+	for _, token := range tokens {
+		tokenNames[int64(token.ID)] = token.Name
+	}
+
+	org, err := h.db.GetOrganization(orgID)
+	if err != nil {
+		log.Printf("Failed to get organization %d: %v", orgID, err)
+	}
+
+	data := struct {
+		OrganizationID    int
+		OrganizationTitle string
+		Tokens            []*database.APIToken
+		TokenNames        map[int64]string
+		Runs              []*database.ScrapeRun
+		Scopes            []string
+		Form              tokenForm
+		Error             string
+		Version           string
+	}{
+		OrganizationID:    orgID,
+		OrganizationTitle: getOrganizationTitle(org),
+		Tokens:            tokens,
+		TokenNames:        tokenNames,
+		Runs:              runs,
+		Scopes:            database.Scopes,
+		Form:              form,
+		Error:             formError,
+		Version:           h.version,
+	}
+
+	h.renderAdmin(w, r, "admin-tokens.html", data)
+}
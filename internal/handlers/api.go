@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/romanzipp/linke-calendar/internal/database"
+)
+
+const (
+	apiDefaultLimit = 50
+	apiMaxLimit     = 200
+)
+
+type apiOrganization struct {
+	ID          int     `json:"id"`
+	Title       string  `json:"title"`
+	LastScraped *string `json:"last_scraped"`
+}
+
+type apiEvent struct {
+	ID             int     `json:"id"`
+	UID            string  `json:"uid"`
+	OrganizationID int     `json:"organization_id"`
+	Title          string  `json:"title"`
+	Description    *string `json:"description"`
+	Start          string  `json:"start"`
+	End            *string `json:"end"`
+	AllDay         bool    `json:"all_day"`
+	Location       *string `json:"location"`
+	Activity       *string `json:"activity"`
+	Campaign       *string `json:"campaign"`
+	URL            *string `json:"url"`
+	Source         string  `json:"source"`
+	Highlight      bool    `json:"highlight"`
+	UpdatedAt      string  `json:"updated_at"`
+}
+
+type apiEventList struct {
+	Data       []apiEvent `json:"data"`
+	NextCursor *string    `json:"next_cursor"`
+}
+
+// CORS exposes the JSON API to browsers on the configured origins.
+func (h *Handler) CORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+
+		if origin != "" && h.config.AllowsOrigin(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			if r.Method == http.MethodOptions {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+				w.Header().Set("Access-Control-Max-Age", "86400")
+			}
+		}
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Handler) APIOrganization(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid organization ID")
+		return
+	}
+
+	if status, err := h.ensureScraped(orgID); err != nil {
+		writeJSONError(w, status, ensureScrapedMessage(err))
+		return
+	}
+
+	snap, err := h.snapshot(orgID)
+	if err != nil {
+		log.Printf("Failed to load organization %d: %v", orgID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	org := snap.Organization
+	if org == nil {
+		writeJSONError(w, http.StatusNotFound, "Organization not found")
+		return
+	}
+
+	if _, done := h.notModified(w, r, "api", snap.Version); done {
+		return
+	}
+
+	result := apiOrganization{
+		ID:    org.ID,
+		Title: getOrganizationTitle(org),
+	}
+	if org.LastScraped.Valid {
+		lastScraped := org.LastScraped.Time.UTC().Format(time.RFC3339)
+		result.LastScraped = &lastScraped
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": result})
+}
+
+func (h *Handler) APIEvents(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid organization ID")
+		return
+	}
+
+	query := r.URL.Query()
+
+	from := startOfDay(time.Now())
+	if v := query.Get("from"); v != "" {
+		if from, err = parseAPITime(v); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid from parameter")
+			return
+		}
+	}
+
+	to := from.AddDate(10, 0, 0)
+	if v := query.Get("to"); v != "" {
+		if to, err = parseAPITime(v); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid to parameter")
+			return
+		}
+	}
+
+	limit := apiDefaultLimit
+	if v := query.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > apiMaxLimit {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", apiMaxLimit))
+			return
+		}
+	}
+
+	var cursorStart time.Time
+	cursorID := 0
+	if v := query.Get("cursor"); v != "" {
+		if cursorStart, cursorID, err = decodeCursor(v); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+	}
+
+	if status, err := h.ensureScraped(orgID); err != nil {
+		writeJSONError(w, status, ensureScrapedMessage(err))
+		return
+	}
+
+	snap, err := h.snapshot(orgID)
+	if err != nil {
+		log.Printf("Failed to load organization %d: %v", orgID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	if _, done := h.notModified(w, r, "api", snap.Version); done {
+		return
+	}
+
+	events := parseEventFilter(r).Apply(eventsInRange(snap.Events, from, to))
+
+	result := apiEventList{Data: make([]apiEvent, 0, limit)}
+	var last *database.Event
+	for _, event := range events {
+		if cursorID != 0 && !isAfterCursor(event, cursorStart, cursorID) {
+			continue
+		}
+		if len(result.Data) == limit {
+			cursor := encodeCursor(last)
+			result.NextCursor = &cursor
+			break
+		}
+		result.Data = append(result.Data, newAPIEvent(event))
+		last = event
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h *Handler) APIEvent(w http.ResponseWriter, r *http.Request) {
+	event, snap, err := h.findEvent(r)
+	if errors.Is(err, errEventNotFound) {
+		writeJSONError(w, http.StatusNotFound, "Event not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to get event %s: %v", chi.URLParam(r, "eventID"), err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	if _, done := h.notModified(w, r, "api", snap.Version); done {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": newAPIEvent(event)})
+}
+
+func (h *Handler) APIOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+	http.ServeFile(w, r, "web/api/openapi.yaml")
+}
+
+func newAPIEvent(event *database.Event) apiEvent {
+	result := apiEvent{
+		ID:             event.ID,
+		UID:            event.UID(),
+		OrganizationID: event.OrganizationID,
+		Title:          event.Title,
+		Start:          reinterpretTimeInLocation(event.DatetimeStart, database.Berlin).Format(time.RFC3339),
+		AllDay:         event.AllDay,
+		Source:         event.Scraper,
+		Highlight:      event.Highlight,
+		UpdatedAt:      event.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+	if event.Description.Valid {
+		result.Description = &event.Description.String
+	}
+	if event.DatetimeEnd.Valid {
+		end := reinterpretTimeInLocation(event.DatetimeEnd.Time, database.Berlin).Format(time.RFC3339)
+		result.End = &end
+	}
+	if event.Location.Valid {
+		result.Location = &event.Location.String
+	}
+	if event.Activity.Valid {
+		result.Activity = &event.Activity.String
+	}
+	if event.Campaign.Valid {
+		result.Campaign = &event.Campaign.String
+	}
+	if event.HasLink() {
+		result.URL = &event.URL
+	}
+	return result
+}
+
+// parseAPITime accepts dates and RFC 3339 timestamps and returns them as
+// Berlin wall clock time, the way event times are stored.
+func parseAPITime(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return reinterpretTimeInLocation(t.In(database.Berlin), time.UTC), nil
+}
+
+// Cursors point behind the last returned event in (start, id) order.
+func encodeCursor(event *database.Event) string {
+	raw := event.DatetimeStart.UTC().Format(time.RFC3339) + "|" + strconv.Itoa(event.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (time.Time, int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return time.Time{}, 0, fmt.Errorf("malformed cursor")
+	}
+
+	start, err := time.Parse(time.RFC3339, parts[0])
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	id, err := strconv.Atoi(parts[1])
+	if err != nil || id < 1 {
+		return time.Time{}, 0, fmt.Errorf("malformed cursor")
+	}
+
+	return start, id, nil
+}
+
+func isAfterCursor(event *database.Event, start time.Time, id int) bool {
+	if event.DatetimeStart.Equal(start) {
+		return event.ID > id
+	}
+	return event.DatetimeStart.After(start)
+}
+
+func startOfDay(t time.Time) time.Time {
+	return database.WallClock(t).Truncate(24 * time.Hour)
+}
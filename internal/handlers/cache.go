@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/romanzipp/linke-calendar/internal/config"
+	"github.com/romanzipp/linke-calendar/internal/database"
+)
+
+// orgSnapshot is the cached public state of an organization. It is shared
+// between requests and must not be modified.
+type orgSnapshot struct {
+	Organization *database.Organization
+	// Events are all public events with rules applied, ordered by start.
+	Events []*database.Event
+	// Theme is the configured theme with the one from the admin area
+	// applied.
+	Theme   config.Theme
+	Version *database.OrganizationVersion
+}
+
+func snapshotKey(orgID int) string {
+	return fmt.Sprintf("org:%d:snapshot", orgID)
+}
+
+// snapshot returns the public state of an organization from the cache,
+// loading it from the database on a miss.
+func (h *Handler) snapshot(orgID int) (*orgSnapshot, error) {
+	if cached, ok := h.cache.Get(snapshotKey(orgID)); ok {
+		return cached.(*orgSnapshot), nil
+	}
+
+	generation := h.cache.Generation()
+	snap, err := h.loadSnapshot(orgID)
+	if err != nil {
+		return nil, err
+	}
+	h.cache.Set(snapshotKey(orgID), snap, snapshotSize(snap), generation)
+	return snap, nil
+}
+
+func (h *Handler) loadSnapshot(orgID int) (*orgSnapshot, error) {
+	version, err := h.db.GetOrganizationVersion(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := h.db.GetOrganization(orgID)
+	if err != nil {
+		// Organizations are only created by the scraper, the calendar
+		// still works without one.
+		org = nil
+	}
+
+	events, err := h.db.GetEventsByOrganization(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err = h.publicEvents(orgID, events)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply rules: %w", err)
+	}
+
+	theme, err := h.db.GetOrganizationTheme(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &orgSnapshot{
+		Organization: org,
+		Events:       events,
+		Theme:        mergeTheme(h.config.GetTheme(orgID), theme),
+		Version:      version,
+	}, nil
+}
+
+// cachedBody returns a rendered response body. Bodies are keyed by their
+// ETag, which changes with the organization's data and the request.
+func (h *Handler) cachedBody(orgID int, etag string, render func() ([]byte, error)) ([]byte, error) {
+	if etag == "" {
+		return render()
+	}
+
+	key := fmt.Sprintf("org:%d:body:%s", orgID, etag)
+	if cached, ok := h.cache.Get(key); ok {
+		return cached.([]byte), nil
+	}
+
+	generation := h.cache.Generation()
+	body, err := render()
+	if err != nil {
+		return nil, err
+	}
+	h.cache.Set(key, body, int64(len(body)), generation)
+	return body, nil
+}
+
+// InvalidateOrganization loads the state of an organization again and swaps
+// it in together with dropping the rendered bodies, so requests meanwhile
+// are still served the old state from memory.
+func (h *Handler) InvalidateOrganization(orgID int) {
+	prefix := fmt.Sprintf("org:%d:", orgID)
+
+	generation := h.cache.Generation()
+	snap, err := h.loadSnapshot(orgID)
+	if err != nil {
+		log.Printf("Failed to rebuild cache of organization %d: %v", orgID, err)
+		h.cache.DeletePrefix(prefix)
+		return
+	}
+	h.cache.Replace(prefix, snapshotKey(orgID), snap, snapshotSize(snap), generation)
+}
+
+// AdminCacheStats reports the hit and miss counters of the read cache.
+func (h *Handler) AdminCacheStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.cache.Stats())
+}
+
+// snapshotSize estimates the memory held by a snapshot.
+func snapshotSize(snap *orgSnapshot) int64 {
+	size := int64(512)
+	for _, event := range snap.Events {
+		size += 512 + int64(len(event.Title)+len(event.Description.String)+len(event.Location.String)+
+			len(event.URL)+len(event.Activity.String)+len(event.Campaign.String)+len(event.SourceID.String))
+		if event.Original != nil {
+			size += 512 + int64(len(event.Original.Title)+len(event.Original.Description.String)+len(event.Original.Location.String))
+		}
+	}
+	return size
+}
+
+func eventsInRange(events []*database.Event, from, to time.Time) []*database.Event {
+	result := make([]*database.Event, 0, len(events))
+	for _, event := range events {
+		if !event.DatetimeStart.Before(from) && event.DatetimeStart.Before(to) {
+			result = append(result, event)
+		}
+	}
+	return result
+}
+
+func upcomingEvents(events []*database.Event) []*database.Event {
+	return eventsInRange(events, database.WallClock(time.Now()), time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC))
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/romanzipp/linke-calendar/internal/database"
+)
+
+// writeNotModified sets the validators for a response and answers with 304
+// if the client already has this version. It reports whether it did.
+func writeNotModified(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if etag != "" && etagMatches(match, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+		return false
+	}
+
+	if since := r.Header.Get("If-Modified-Since"); since != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(since)
+		if err == nil && !lastModified.Truncate(time.Second).After(t) {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
+}
+
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		candidate = strings.TrimPrefix(candidate, "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified sets the caching headers of a public endpoint and answers with
+// 304 if the client's copy is current. The validators derive from the
+// organization's data version, so unchanged responses are not rendered again.
+// Output depending on the current time, like upcoming events, changes the
+// ETag at least hourly. Last-Modified is the time of the last data change,
+// so clients only sending If-Modified-Since keep their copy until the data
+// changes. Localized pages set Content-Language before.
+//
+// It returns the ETag, which also keys rendered bodies.
+func (h *Handler) notModified(w http.ResponseWriter, r *http.Request, endpoint string, version *database.OrganizationVersion) (string, bool) {
+	w.Header().Set("Cache-Control", h.config.GetCacheControl(endpoint))
+	w.Header().Add("Vary", "HX-Request")
+
+	hour := time.Now().UTC().Truncate(time.Hour)
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		version.Key,
+		hour.Format(time.RFC3339),
+		h.publicURL(r),
+		r.URL.RequestURI(),
+		r.Header.Get("HX-Request"),
+		w.Header().Get("Content-Language"),
+		h.version,
+	}, "\n")))
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	return etag, writeNotModified(w, r, etag, version.LastModified)
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/romanzipp/linke-calendar/internal/config"
+	"github.com/romanzipp/linke-calendar/internal/database"
+)
+
+func TestNotModified(t *testing.T) {
+	h := &Handler{config: &config.Config{}, version: "test"}
+	modified := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	version := &database.OrganizationVersion{Key: "1-42", LastModified: modified}
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		if _, done := h.notModified(w, r, "ical", version); !done {
+			w.WriteHeader(http.StatusOK)
+		}
+		return w
+	}
+
+	first := serve(httptest.NewRequest("GET", "/org/1/ical", nil))
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("first request: status %d, ETag %q", first.Code, etag)
+	}
+	if got := first.Header().Get("Last-Modified"); got != modified.Format(http.TimeFormat) {
+		t.Errorf("Last-Modified = %q, want the data version's %q", got, modified.Format(http.TimeFormat))
+	}
+
+	before := modified.Add(-time.Hour).Format(http.TimeFormat)
+	after := modified.Add(time.Hour).Format(http.TimeFormat)
+
+	tests := []struct {
+		name        string
+		target      string
+		noneMatch   string
+		sinceHeader string
+		want        int
+	}{
+		{"no validators", "/org/1/ical", "", "", http.StatusOK},
+		{"matching ETag", "/org/1/ical", etag, "", http.StatusNotModified},
+		{"weak ETag", "/org/1/ical", "W/" + etag, "", http.StatusNotModified},
+		{"ETag in list", "/org/1/ical", `"other", ` + etag, "", http.StatusNotModified},
+		{"wildcard", "/org/1/ical", "*", "", http.StatusNotModified},
+		{"other ETag", "/org/1/ical", `"other"`, "", http.StatusOK},
+		{"other URL", "/org/1/ical?alarm=1h", etag, "", http.StatusOK},
+		{"modified since", "/org/1/ical", "", before, http.StatusOK},
+		{"not modified since", "/org/1/ical", "", after, http.StatusNotModified},
+		{"ETag wins over date", "/org/1/ical", `"other"`, after, http.StatusOK},
+		{"matching ETag with old date", "/org/1/ical", etag, before, http.StatusNotModified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.target, nil)
+			if tt.noneMatch != "" {
+				r.Header.Set("If-None-Match", tt.noneMatch)
+			}
+			if tt.sinceHeader != "" {
+				r.Header.Set("If-Modified-Since", tt.sinceHeader)
+			}
+
+			w := serve(r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+			if w.Header().Get("ETag") == "" || w.Header().Get("Cache-Control") == "" {
+				t.Errorf("missing validators: %v", w.Header())
+			}
+			if w.Code == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("304 with body %q", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestNotModifiedVariesByScheme(t *testing.T) {
+	h := &Handler{config: &config.Config{}}
+	version := &database.OrganizationVersion{Key: "1-42"}
+
+	etag := func(r *http.Request) string {
+		got, _ := h.notModified(httptest.NewRecorder(), r, "feed", version)
+		return got
+	}
+
+	plain := httptest.NewRequest("GET", "http://example.org/org/1/feed.xml", nil)
+	spoofed := httptest.NewRequest("GET", "http://example.org/org/1/feed.xml", nil)
+	spoofed.Header.Set("X-Forwarded-Proto", "https")
+	if etag(plain) != etag(spoofed) {
+		t.Error("an untrusted X-Forwarded-Proto changed the ETag")
+	}
+
+	// httptest requests come from 192.0.2.1.
+	h.proxies = []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}
+	proxied := httptest.NewRequest("GET", "http://example.org/org/1/feed.xml", nil)
+	proxied.Header.Set("X-Forwarded-Proto", "https")
+	if etag(plain) == etag(proxied) {
+		t.Error("the ETag does not depend on the scheme")
+	}
+	if !strings.HasPrefix(h.publicURL(proxied), "https://") {
+		t.Errorf("publicURL() = %q", h.publicURL(proxied))
+	}
+}
@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/romanzipp/linke-calendar/internal/database"
+	"github.com/romanzipp/linke-calendar/internal/i18n"
+)
+
+type feed struct {
+	Language    string
+	Title       string
+	HomeURL     string
+	FeedURL     string
+	Updated     time.Time
+	Description string
+	Items       []feedItem
+}
+
+type feedItem struct {
+	ID          string
+	Title       string
+	URL         string
+	ContentHTML string
+	ContentText string
+	Published   time.Time
+	Updated     time.Time
+	Category    string
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description"`
+	Category    string  `xml:"category,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Lang    string      `xml:"xml:lang,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string        `xml:"id"`
+	Title     string        `xml:"title"`
+	Updated   string        `xml:"updated"`
+	Published string        `xml:"published"`
+	Link      atomLink      `xml:"link"`
+	Content   atomContent   `xml:"content"`
+	Category  *atomCategory `xml:"category"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Description string         `json:"description"`
+	Language    string         `json:"language"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string   `json:"id"`
+	URL           string   `json:"url"`
+	Title         string   `json:"title"`
+	ContentHTML   string   `json:"content_html"`
+	ContentText   string   `json:"content_text"`
+	DatePublished string   `json:"date_published"`
+	DateModified  string   `json:"date_modified"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+func (h *Handler) FeedRSS(w http.ResponseWriter, r *http.Request) {
+	h.serveFeed(w, r, "application/rss+xml; charset=utf-8", func(f *feed) ([]byte, error) {
+		doc := rssDocument{
+			Version: "2.0",
+			AtomNS:  "http://www.w3.org/2005/Atom",
+			Channel: rssChannel{
+				Title:         f.Title,
+				Link:          f.HomeURL,
+				Description:   f.Description,
+				Language:      f.Language,
+				LastBuildDate: f.Updated.Format(time.RFC1123Z),
+				AtomLink:      atomLink{Href: f.FeedURL, Rel: "self", Type: "application/rss+xml"},
+			},
+		}
+		for _, item := range f.Items {
+			doc.Channel.Items = append(doc.Channel.Items, rssItem{
+				Title:       item.Title,
+				Link:        item.URL,
+				GUID:        rssGUID{Value: item.ID},
+				PubDate:     item.Published.Format(time.RFC1123Z),
+				Description: item.ContentHTML,
+				Category:    item.Category,
+			})
+		}
+		return marshalXML(doc)
+	})
+}
+
+func (h *Handler) FeedAtom(w http.ResponseWriter, r *http.Request) {
+	h.serveFeed(w, r, "application/atom+xml; charset=utf-8", func(f *feed) ([]byte, error) {
+		doc := atomFeed{
+			Lang:    f.Language,
+			ID:      f.FeedURL,
+			Title:   f.Title,
+			Updated: f.Updated.Format(time.RFC3339),
+			Links: []atomLink{
+				{Href: f.FeedURL, Rel: "self", Type: "application/atom+xml"},
+				{Href: f.HomeURL, Rel: "alternate", Type: "text/html"},
+			},
+		}
+		for _, item := range f.Items {
+			entry := atomEntry{
+				ID:        item.ID,
+				Title:     item.Title,
+				Updated:   item.Updated.Format(time.RFC3339),
+				Published: item.Published.Format(time.RFC3339),
+				Link:      atomLink{Href: item.URL, Rel: "alternate", Type: "text/html"},
+				Content:   atomContent{Type: "html", Value: item.ContentHTML},
+			}
+			if item.Category != "" {
+				entry.Category = &atomCategory{Term: item.Category}
+			}
+			doc.Entries = append(doc.Entries, entry)
+		}
+		return marshalXML(doc)
+	})
+}
+
+func (h *Handler) FeedJSON(w http.ResponseWriter, r *http.Request) {
+	h.serveFeed(w, r, "application/feed+json; charset=utf-8", func(f *feed) ([]byte, error) {
+		doc := jsonFeed{
+			Version:     "https://jsonfeed.org/version/1.1",
+			Title:       f.Title,
+			HomePageURL: f.HomeURL,
+			FeedURL:     f.FeedURL,
+			Description: f.Description,
+			Language:    f.Language,
+			Items:       make([]jsonFeedItem, 0, len(f.Items)),
+		}
+		for _, item := range f.Items {
+			jsonItem := jsonFeedItem{
+				ID:            item.ID,
+				URL:           item.URL,
+				Title:         item.Title,
+				ContentHTML:   item.ContentHTML,
+				ContentText:   item.ContentText,
+				DatePublished: item.Published.Format(time.RFC3339),
+				DateModified:  item.Updated.Format(time.RFC3339),
+			}
+			if item.Category != "" {
+				jsonItem.Tags = []string{item.Category}
+			}
+			doc.Items = append(doc.Items, jsonItem)
+		}
+		return json.MarshalIndent(doc, "", "  ")
+	})
+}
+
+// serveFeed loads the upcoming events with the list view filters and renders
+// them with the given encoder, answering conditional requests with 304.
+func (h *Handler) serveFeed(w http.ResponseWriter, r *http.Request, contentType string, encode func(*feed) ([]byte, error)) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	if status, err := h.ensureScraped(orgID); err != nil {
+		http.Error(w, ensureScrapedMessage(err), status)
+		return
+	}
+
+	snap, err := h.snapshot(orgID)
+	if err != nil {
+		log.Printf("Failed to load organization %d: %v", orgID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	locale := h.locale(w, r)
+
+	etag, done := h.notModified(w, r, "feed", snap.Version)
+	if done {
+		return
+	}
+
+	body, err := h.cachedBody(orgID, etag, func() ([]byte, error) {
+		org := snap.Organization
+		events := parseEventFilter(r).Apply(upcomingEvents(snap.Events))
+
+		base := h.publicURL(r)
+		title := getOrganizationTitle(org)
+		updated := eventsLastModified(events)
+		if updated.IsZero() && org != nil && org.LastScraped.Valid {
+			updated = org.LastScraped.Time
+		}
+		if updated.IsZero() {
+			updated = time.Now()
+		}
+
+		f := &feed{
+			Language:    locale.Lang,
+			Title:       locale.T("list.title", "organization", title),
+			HomeURL:     fmt.Sprintf("%s/org/%d/list", base, orgID),
+			FeedURL:     base + r.URL.RequestURI(),
+			Updated:     updated.UTC(),
+			Description: locale.T("feed.description", "organization", title),
+		}
+		for _, event := range events {
+			f.Items = append(f.Items, newFeedItem(base, event, locale))
+		}
+
+		return encode(f)
+	})
+	if err != nil {
+		log.Printf("Failed to encode feed: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(body)
+}
+
+func newFeedItem(base string, event *database.Event, locale *i18n.Locale) feedItem {
+	detailURL := base + eventPath(event)
+	when := formatEventWhen(event, locale)
+
+	var htmlParts, textParts []string
+	htmlParts = append(htmlParts, "<p><strong>"+html.EscapeString(locale.T("feed.when"))+":</strong> "+html.EscapeString(when)+"</p>")
+	textParts = append(textParts, locale.T("feed.when")+": "+when)
+
+	if event.Location.Valid {
+		htmlParts = append(htmlParts, "<p><strong>"+html.EscapeString(locale.T("feed.where"))+":</strong> "+html.EscapeString(event.Location.String)+"</p>")
+		textParts = append(textParts, locale.T("feed.where")+": "+event.Location.String)
+	}
+
+	if event.Description.Valid {
+		description := html.EscapeString(event.Description.String)
+		htmlParts = append(htmlParts, "<p>"+strings.ReplaceAll(description, "\n", "<br>")+"</p>")
+		textParts = append(textParts, event.Description.String)
+	}
+
+	if event.HasLink() {
+		htmlParts = append(htmlParts, `<p><a href="`+html.EscapeString(event.URL)+`">`+html.EscapeString(locale.T("event.more_info"))+`</a></p>`)
+	}
+
+	updated := eventLastModified(event).UTC()
+
+	return feedItem{
+		ID:          detailURL,
+		Title:       event.Title,
+		URL:         detailURL,
+		ContentHTML: strings.Join(htmlParts, "\n"),
+		ContentText: strings.Join(textParts, "\n\n"),
+		Published:   event.CreatedAt.UTC(),
+		Updated:     updated,
+		Category:    event.Activity.String,
+	}
+}
+
+// formatEventWhen describes date and time of an event in the formats of a
+// locale.
+func formatEventWhen(event *database.Event, locale *i18n.Locale) string {
+	start := event.DatetimeStart
+	sameDay := !event.DatetimeEnd.Valid || event.DatetimeEnd.Time.Format("2006-01-02") == start.Format("2006-01-02")
+
+	if event.AllDay {
+		if sameDay {
+			return locale.T("event.all_day", "date", locale.Date(start))
+		}
+		return locale.T("event.all_day", "date", locale.Date(start)+" - "+locale.Date(event.DatetimeEnd.Time))
+	}
+
+	clock := locale.Clock(start)
+	if event.DatetimeEnd.Valid {
+		end := event.DatetimeEnd.Time
+		if sameDay {
+			clock += " - " + locale.Clock(end)
+		} else {
+			clock += " - " + locale.Date(end) + ", " + locale.Clock(end)
+		}
+	}
+	return locale.Date(start) + ", " + locale.T("time.clock", "time", clock)
+}
+
+func eventLastModified(event *database.Event) time.Time {
+	modified := event.UpdatedAt
+	if event.Override != nil && event.Override.UpdatedAt.After(modified) {
+		modified = event.Override.UpdatedAt
+	}
+	return modified
+}
+
+func eventsLastModified(events []*database.Event) time.Time {
+	var modified time.Time
+	for _, event := range events {
+		if t := eventLastModified(event); t.After(modified) {
+			modified = t
+		}
+	}
+	return modified
+}
+
+// publicURL is the absolute base URL of the service, taken from the config
+// or, if unset, from the request. X-Forwarded-Proto is only honored from
+// trusted proxies.
+func (h *Handler) publicURL(r *http.Request) string {
+	if h.config.Server.PublicURL != "" {
+		return strings.TrimRight(h.config.Server.PublicURL, "/")
+	}
+
+	scheme := "http"
+	if r.TLS != nil || (fromTrustedProxy(r, h.proxies) && r.Header.Get("X-Forwarded-Proto") == "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func marshalXML(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&buf)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
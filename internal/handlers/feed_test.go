@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/romanzipp/linke-calendar/internal/database"
+	"github.com/romanzipp/linke-calendar/internal/i18n"
+)
+
+func TestFormatEventWhen(t *testing.T) {
+	at := func(d, h, m int) time.Time {
+		return time.Date(2025, 3, d, h, m, 0, 0, time.UTC)
+	}
+	end := func(t time.Time) sql.NullTime {
+		return sql.NullTime{Time: t, Valid: true}
+	}
+
+	tests := []struct {
+		name  string
+		lang  string
+		event database.Event
+		want  string
+	}{
+		{"start only", "de", database.Event{DatetimeStart: at(1, 18, 0)}, "01.03.2025, 18:00 Uhr"},
+		{"same day", "de", database.Event{DatetimeStart: at(1, 18, 0), DatetimeEnd: end(at(1, 20, 30))}, "01.03.2025, 18:00 - 20:30 Uhr"},
+		{"several days", "de", database.Event{DatetimeStart: at(1, 18, 0), DatetimeEnd: end(at(2, 12, 0))}, "01.03.2025, 18:00 - 02.03.2025, 12:00 Uhr"},
+		{"all day", "de", database.Event{DatetimeStart: at(1, 0, 0), DatetimeEnd: end(at(1, 23, 59)), AllDay: true}, "01.03.2025 (ganztägig)"},
+		{"all day, several days", "de", database.Event{DatetimeStart: at(1, 0, 0), DatetimeEnd: end(at(3, 23, 59)), AllDay: true}, "01.03.2025 - 03.03.2025 (ganztägig)"},
+		{"english", "en", database.Event{DatetimeStart: at(1, 18, 0), DatetimeEnd: end(at(1, 20, 30))}, "Mar 1, 2025, 6:00 PM - 8:30 PM"},
+		{"english all day", "en", database.Event{DatetimeStart: at(1, 0, 0), AllDay: true}, "Mar 1, 2025 (all day)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatEventWhen(&tt.event, i18n.Get(tt.lang)); got != tt.want {
+				t.Errorf("formatEventWhen() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
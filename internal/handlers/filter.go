@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/romanzipp/linke-calendar/internal/database"
+)
+
+// eventFilter narrows event lists by the activity, campaign and text query
+// parameters shared by the public endpoints.
+type eventFilter struct {
+	Activities []string
+	Campaigns  []string
+	Query      string
+}
+
+func parseEventFilter(r *http.Request) eventFilter {
+	return eventFilter{
+		Activities: parseListParam(r, "activity"),
+		Campaigns:  parseListParam(r, "campaign"),
+		Query:      strings.ToLower(strings.TrimSpace(r.URL.Query().Get("q"))),
+	}
+}
+
+// parseListParam collects the lowercased values of a parameter that may be
+// repeated or hold a comma separated list.
+func parseListParam(r *http.Request, name string) []string {
+	var values []string
+	for _, value := range r.URL.Query()[name] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, strings.ToLower(item))
+			}
+		}
+	}
+	return values
+}
+
+func (f eventFilter) IsEmpty() bool {
+	return len(f.Activities) == 0 && len(f.Campaigns) == 0 && f.Query == ""
+}
+
+func (f eventFilter) Apply(events []*database.Event) []*database.Event {
+	if f.IsEmpty() {
+		return events
+	}
+
+	filtered := make([]*database.Event, 0, len(events))
+	for _, event := range events {
+		if f.matches(event) {
+			filtered = append(filtered, event)
+		}
+	}
+	return filtered
+}
+
+func (f eventFilter) matches(event *database.Event) bool {
+	if len(f.Activities) > 0 && !containsLower(f.Activities, event.Activity.String) {
+		return false
+	}
+
+	if len(f.Campaigns) > 0 && !containsLower(f.Campaigns, event.Campaign.String) {
+		return false
+	}
+
+	if f.Query != "" {
+		haystack := strings.ToLower(event.Title + "\n" + event.Description.String + "\n" + event.Location.String)
+		if !strings.Contains(haystack, f.Query) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsLower(list []string, value string) bool {
+	value = strings.ToLower(value)
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Default window of iCal feeds, so subscriptions don't grow with every past
+// event.
+const (
+	defaultPast   = "1m"
+	defaultFuture = "1y"
+)
+
+// parseWindow reads the past and future parameters, e.g. "30d" or "1y",
+// into a range of event start times around today. Missing parameters fall
+// back to defaultPast and defaultFuture.
+func parseWindow(r *http.Request, today time.Time) (time.Time, time.Time, error) {
+	past := r.URL.Query().Get("past")
+	if past == "" {
+		past = defaultPast
+	}
+	years, months, days, err := parsePeriod(past)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid past parameter: %w", err)
+	}
+	from := today.AddDate(-years, -months, -days)
+
+	future := r.URL.Query().Get("future")
+	if future == "" {
+		future = defaultFuture
+	}
+	years, months, days, err = parsePeriod(future)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid future parameter: %w", err)
+	}
+	to := today.AddDate(years, months, days+1)
+
+	return from, to, nil
+}
+
+// parsePeriod parses a number followed by d (days), w (weeks), m (months)
+// or y (years).
+func parsePeriod(value string) (int, int, int, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if len(value) < 2 {
+		return 0, 0, 0, fmt.Errorf("%q is not a period", value)
+	}
+
+	n, err := strconv.Atoi(value[:len(value)-1])
+	if err != nil || n < 0 || n > 100*366 {
+		return 0, 0, 0, fmt.Errorf("%q is not a period", value)
+	}
+
+	switch value[len(value)-1] {
+	case 'd':
+		return 0, 0, n, nil
+	case 'w':
+		return 0, 0, 7 * n, nil
+	case 'm':
+		return 0, n, 0, nil
+	case 'y':
+		return n, 0, 0, nil
+	}
+	return 0, 0, 0, fmt.Errorf("%q is not a period", value)
+}
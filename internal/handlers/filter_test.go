@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	today := time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		wantFrom time.Time
+		wantTo   time.Time
+		wantErr  bool
+	}{
+		{"defaults", "", date(2025, 2, 15), date(2026, 3, 16), false},
+		{"days", "past=30d&future=10d", date(2025, 2, 13), date(2025, 3, 26), false},
+		{"weeks", "past=2w&future=1w", date(2025, 3, 1), date(2025, 3, 23), false},
+		{"months and years", "past=6m&future=2y", date(2024, 9, 15), date(2027, 3, 16), false},
+		{"only past", "past=0d", today, date(2026, 3, 16), false},
+		{"only future", "future=0d", date(2025, 2, 15), date(2025, 3, 16), false},
+		{"upper case", "past=1Y", date(2024, 3, 15), date(2026, 3, 16), false},
+		{"invalid past", "past=soon", time.Time{}, time.Time{}, true},
+		{"invalid unit", "future=3h", time.Time{}, time.Time{}, true},
+		{"negative", "past=-1d", time.Time{}, time.Time{}, true},
+		{"too long", "future=40000d", time.Time{}, time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/org/1/ical?"+tt.query, nil)
+			from, to, err := parseWindow(r, today)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseWindow() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !from.Equal(tt.wantFrom) || !to.Equal(tt.wantTo) {
+				t.Errorf("parseWindow() = %s - %s, want %s - %s", from.Format("2006-01-02"), to.Format("2006-01-02"), tt.wantFrom.Format("2006-01-02"), tt.wantTo.Format("2006-01-02"))
+			}
+		})
+	}
+}
@@ -1,41 +1,73 @@
 package handlers
 
 import (
+	"bytes"
+	"database/sql"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
-	ics "github.com/arran4/golang-ical"
 	"github.com/go-chi/chi/v5"
+	"github.com/romanzipp/linke-calendar/internal/cache"
 	"github.com/romanzipp/linke-calendar/internal/calendar"
+	"github.com/romanzipp/linke-calendar/internal/config"
 	"github.com/romanzipp/linke-calendar/internal/database"
+	"github.com/romanzipp/linke-calendar/internal/i18n"
+	"github.com/romanzipp/linke-calendar/internal/ical"
+	"github.com/romanzipp/linke-calendar/internal/rules"
 )
 
 type Scraper interface {
 	ScrapeOrganization(orgID int) error
+	Enqueue(orgID int, tokenID sql.NullInt64) (*database.ScrapeRun, error)
 }
 
 type Handler struct {
-	db        *database.DB
-	scraper   Scraper
-	templates *template.Template
-	version   string
+	db          *database.DB
+	scraper     Scraper
+	config      *config.Config
+	templates   *template.Template
+	localized   map[string]*template.Template
+	admin       *template.Template
+	version     string
+	submissions *rateLimiter
+	logins      *rateLimiter
+	refreshes   *rateLimiter
+	cache       *cache.Cache
+	proxies     []netip.Prefix
 }
 
-func New(db *database.DB, scraper Scraper, version string) (*Handler, error) {
-	tmpl, err := template.ParseGlob("web/templates/*.html")
+func New(db *database.DB, scraper Scraper, cfg *config.Config, version string) (*Handler, error) {
+	localized, err := parseTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	admin, err := parseAdminTemplates()
 	if err != nil {
 		return nil, err
 	}
 
 	return &Handler{
-		db:        db,
-		scraper:   scraper,
-		templates: tmpl,
-		version:   version,
+		db:          db,
+		scraper:     scraper,
+		config:      cfg,
+		templates:   localized[i18n.DefaultLanguage],
+		localized:   localized,
+		admin:       admin,
+		version:     version,
+		submissions: newRateLimiter(5, time.Hour),
+		logins:      newRateLimiter(10, 15*time.Minute),
+		refreshes:   newRateLimiter(20, time.Hour),
+		cache:       cache.New(cfg.GetCacheMaxBytes()),
+		proxies:     cfg.GetTrustedProxies(),
 	}, nil
 }
 
@@ -52,133 +84,316 @@ func (h *Handler) Calendar(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hasEvents, err := h.db.HasEventsForOrganization(orgID)
+	if status, err := h.ensureScraped(orgID); err != nil {
+		http.Error(w, ensureScrapedMessage(err), status)
+		return
+	}
+
+	snap, err := h.snapshot(orgID)
 	if err != nil {
-		log.Printf("Failed to check events for organization %d: %v", orgID, err)
+		log.Printf("Failed to load organization %d: %v", orgID, err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	if !hasEvents {
-		log.Printf("No events for organization %d, scraping synchronously", orgID)
-		if err := h.scraper.ScrapeOrganization(orgID); err != nil {
-			log.Printf("Failed to scrape organization %d: %v", orgID, err)
-			http.Error(w, "Failed to fetch events", http.StatusInternalServerError)
-			return
-		}
+	locale := h.locale(w, r)
+	if _, done := h.notModified(w, r, "calendar", snap.Version); done {
+		return
 	}
 
-	yearStr := r.URL.Query().Get("year")
-	monthStr := r.URL.Query().Get("month")
+	query := r.URL.Query()
+	keep := keepParams(r)
+	view := calendar.ParseView(query.Get("view"))
+	events := parseEventFilter(r).Apply(snap.Events)
+
+	anchor := startOfDay(time.Now())
+	if v := query.Get("date"); v != "" {
+		if date, err := time.Parse("2006-01-02", v); err == nil {
+			anchor = date
+		}
+	}
 
-	now := time.Now()
-	year := now.Year()
-	month := now.Month()
+	year := anchor.Year()
+	month := anchor.Month()
 
-	if yearStr != "" {
+	if yearStr := query.Get("year"); yearStr != "" {
 		if y, err := strconv.Atoi(yearStr); err == nil {
 			year = y
 		}
 	}
 
-	if monthStr != "" {
+	if monthStr := query.Get("month"); monthStr != "" {
 		if m, err := strconv.Atoi(monthStr); err == nil && m >= 1 && m <= 12 {
 			month = time.Month(m)
 		}
 	}
 
-	startDate := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
-	endDate := startDate.AddDate(0, 1, 0)
+	if year != anchor.Year() || month != anchor.Month() {
+		anchor = time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	}
 
-	events, err := h.db.GetEventsByOrganizationInRange(orgID, startDate, endDate)
-	if err != nil {
-		log.Printf("Failed to get events: %v", err)
+	data := calendarPage{
+		OrganizationID:    orgID,
+		OrganizationTitle: getOrganizationTitle(snap.Organization),
+		View:              view,
+		Views: []calendarViewLink{
+			{Label: locale.T("view.month"), URL: calendarURL(orgID, calendar.ViewMonth, anchor, keep), Active: view == calendar.ViewMonth},
+			{Label: locale.T("view.week"), URL: calendarURL(orgID, calendar.ViewWeek, anchor, keep), Active: view == calendar.ViewWeek},
+			{Label: locale.T("view.day"), URL: calendarURL(orgID, calendar.ViewDay, anchor, keep), Active: view == calendar.ViewDay},
+			{Label: locale.T("view.agenda"), URL: calendarURL(orgID, calendar.ViewAgenda, anchor, keep), Active: view == calendar.ViewAgenda},
+		},
+		OEmbedURL: h.oembedLink(r),
+		Theme:     h.theme(r, snap),
+		Version:   h.version,
+	}
+
+	switch view {
+	case calendar.ViewWeek:
+		start := calendar.WeekStart(anchor)
+		data.Grid = calendar.GenerateWeek(start, eventsInRange(events, start, start.AddDate(0, 0, 7)))
+		data.Title = locale.Range(data.Grid.Start, data.Grid.End)
+		data.PrevURL = calendarURL(orgID, view, start.AddDate(0, 0, -7), keep)
+		data.NextURL = calendarURL(orgID, view, start.AddDate(0, 0, 7), keep)
+	case calendar.ViewDay:
+		data.Grid = calendar.GenerateDay(anchor, eventsInRange(events, anchor, anchor.AddDate(0, 0, 1)))
+		data.Title = locale.WeekdayDate(anchor)
+		data.PrevURL = calendarURL(orgID, view, anchor.AddDate(0, 0, -1), keep)
+		data.NextURL = calendarURL(orgID, view, anchor.AddDate(0, 0, 1), keep)
+	case calendar.ViewAgenda:
+		data.Agenda = calendar.GenerateAgenda(anchor, eventsInRange(events, anchor, anchor.AddDate(0, 0, calendar.AgendaDays)))
+		data.Title = locale.Range(data.Agenda.Start, data.Agenda.End)
+		data.PrevURL = calendarURL(orgID, view, anchor.AddDate(0, 0, -calendar.AgendaDays), keep)
+		data.NextURL = calendarURL(orgID, view, anchor.AddDate(0, 0, calendar.AgendaDays), keep)
+	default:
+		startDate := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+		data.Calendar = calendar.Generate(year, month, eventsInRange(events, startDate, startDate.AddDate(0, 1, 0)))
+		data.Title = locale.MonthYear(month, year)
+		data.PrevURL = calendarURL(orgID, view, startDate.AddDate(0, -1, 0), keep)
+		data.NextURL = calendarURL(orgID, view, startDate.AddDate(0, 1, 0), keep)
+	}
+
+	templates := h.templatesFor(locale)
+
+	if r.Header.Get("HX-Request") == "true" {
+		if err := templates.ExecuteTemplate(w, "calendar-content", data); err != nil {
+			log.Printf("Failed to render calendar content: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := templates.ExecuteTemplate(w, "calendar.html", data); err != nil {
+		log.Printf("Failed to render calendar: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+type calendarPage struct {
+	OrganizationID    int
+	OrganizationTitle string
+	View              string
+	Views             []calendarViewLink
+	Title             string
+	PrevURL           string
+	NextURL           string
+	Calendar          *calendar.Month
+	Grid              *calendar.TimeGrid
+	Agenda            *calendar.Agenda
+	OEmbedURL         string
+	Theme             pageTheme
+	Version           string
+}
+
+type calendarViewLink struct {
+	Label  string
+	URL    string
+	Active bool
+}
+
+// calendarURL links a view of the calendar around date. Month links keep the
+// year and month parameters of older embeds.
+func calendarURL(orgID int, view string, date time.Time, keep map[string]string) string {
+	if view == calendar.ViewMonth {
+		return fmt.Sprintf("/org/%d/calendar?year=%d&month=%d", orgID, date.Year(), int(date.Month())) + keepQuery(keep)
+	}
+	return fmt.Sprintf("/org/%d/calendar?view=%s&date=%s", orgID, view, date.Format("2006-01-02")) + keepQuery(keep)
+}
+
+// Year shows all months of a year with days shaded by their number of events.
+func (h *Handler) Year(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
 		return
 	}
 
-	org, err := h.db.GetOrganization(orgID)
+	if status, err := h.ensureScraped(orgID); err != nil {
+		http.Error(w, ensureScrapedMessage(err), status)
+		return
+	}
+
+	snap, err := h.snapshot(orgID)
 	if err != nil {
-		log.Printf("Failed to get organization %d: %v", orgID, err)
+		log.Printf("Failed to load organization %d: %v", orgID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
 
-	cal := calendar.Generate(year, month, events)
+	locale := h.locale(w, r)
+	if _, done := h.notModified(w, r, "calendar", snap.Version); done {
+		return
+	}
+
+	year := startOfDay(time.Now()).Year()
+	if y, err := strconv.Atoi(r.URL.Query().Get("year")); err == nil && y > 0 && y < 10000 {
+		year = y
+	}
+
+	startDate := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	events := eventsInRange(parseEventFilter(r).Apply(snap.Events), startDate, startDate.AddDate(1, 0, 0))
 
 	data := struct {
 		OrganizationID    int
 		OrganizationTitle string
-		Calendar          *calendar.Month
-		Year              int
-		Month             int
-		PrevYear          int
-		PrevMonth         int
-		NextYear          int
-		NextMonth         int
+		Year              *calendar.Year
+		PrevURL           string
+		NextURL           string
+		EventCount        int
+		KeepQuery         template.URL
+		OEmbedURL         string
+		Theme             pageTheme
 		Version           string
 	}{
 		OrganizationID:    orgID,
-		OrganizationTitle: getOrganizationTitle(org),
-		Calendar:          cal,
-		Year:              year,
-		Month:             int(month),
+		OrganizationTitle: getOrganizationTitle(snap.Organization),
+		Year:              calendar.GenerateYear(year, events),
+		PrevURL:           fmt.Sprintf("/org/%d/year?year=%d", orgID, year-1),
+		NextURL:           fmt.Sprintf("/org/%d/year?year=%d", orgID, year+1),
+		EventCount:        len(events),
+		KeepQuery:         template.URL(keepQuery(keepParams(r))),
+		OEmbedURL:         h.oembedLink(r),
+		Theme:             h.theme(r, snap),
 		Version:           h.version,
 	}
+	data.PrevURL += string(data.KeepQuery)
+	data.NextURL += string(data.KeepQuery)
 
-	prevMonth := month - 1
-	prevYear := year
-	if prevMonth == 0 {
-		prevMonth = 12
-		prevYear--
+	name := "year.html"
+	if r.Header.Get("HX-Request") == "true" {
+		name = "year-content"
 	}
-	data.PrevYear = prevYear
-	data.PrevMonth = int(prevMonth)
 
-	nextMonth := month + 1
-	nextYear := year
-	if nextMonth == 13 {
-		nextMonth = 1
-		nextYear++
+	if err := h.templatesFor(locale).ExecuteTemplate(w, name, data); err != nil {
+		log.Printf("Failed to render year: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 	}
-	data.NextYear = nextYear
-	data.NextMonth = int(nextMonth)
+}
 
-	if r.Header.Get("HX-Request") == "true" {
-		if err := h.templates.ExecuteTemplate(w, "calendar-content", data); err != nil {
-			log.Printf("Failed to render calendar content: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+// eventPath is the public path of an event. It uses the source ID, which
+// unlike the database ID survives rebuilds of the database.
+func eventPath(event *database.Event) string {
+	return "/event/" + url.PathEscape(event.UID())
+}
+
+// errEventNotFound is returned by findEvent for unknown and hidden events.
+var errEventNotFound = errors.New("event not found")
+
+// findEvent finds the public event of an {eventID} route in the snapshot of
+// its organization. Events are addressed by source ID, numeric database IDs
+// of older links are still accepted. Only the organization of a key is read
+// from the database, once.
+func (h *Handler) findEvent(r *http.Request) (*database.Event, *orgSnapshot, error) {
+	key, err := url.PathUnescape(chi.URLParam(r, "eventID"))
+	if err != nil || key == "" {
+		return nil, nil, errEventNotFound
+	}
+	id, _ := strconv.Atoi(key)
+
+	cacheKey := "event:" + key + ":org"
+	cached, ok := h.cache.Get(cacheKey)
+	if !ok {
+		generation := h.cache.Generation()
+		sourceID := key
+		if id != 0 {
+			sourceID = ""
 		}
-		return
+		orgID, err := h.db.GetEventOrganizationID(id, sourceID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, errEventNotFound
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		h.cache.Set(cacheKey, orgID, 64, generation)
+		cached = orgID
 	}
 
-	if err := h.templates.ExecuteTemplate(w, "calendar.html", data); err != nil {
-		log.Printf("Failed to render calendar: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	snap, err := h.snapshot(cached.(int))
+	if err != nil {
+		return nil, nil, err
 	}
+	if event := findSnapshotEvent(snap.Events, id, key); event != nil {
+		return event, snap, nil
+	}
+	return nil, nil, errEventNotFound
+}
+
+func findSnapshotEvent(events []*database.Event, id int, key string) *database.Event {
+	for _, event := range events {
+		if (id != 0 && event.ID == id) || (id == 0 && event.UID() == key) {
+			return event
+		}
+	}
+	return nil
 }
 
 func (h *Handler) EventDetail(w http.ResponseWriter, r *http.Request) {
-	eventIDStr := chi.URLParam(r, "eventID")
-	eventID, err := strconv.Atoi(eventIDStr)
+	event, snap, err := h.findEvent(r)
+	if errors.Is(err, errEventNotFound) {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Invalid event ID", http.StatusBadRequest)
+		log.Printf("Failed to get event %s: %v", chi.URLParam(r, "eventID"), err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	event, err := h.db.GetEvent(eventID)
-	if err != nil {
-		log.Printf("Failed to get event %d: %v", eventID, err)
-		http.Error(w, "Event not found", http.StatusNotFound)
+	locale := h.locale(w, r)
+	if _, done := h.notModified(w, r, "event", snap.Version); done {
+		return
+	}
+
+	templates := h.templatesFor(locale)
+
+	if r.Header.Get("HX-Request") == "true" {
+		data := struct {
+			Event *database.Event
+		}{
+			Event: event,
+		}
+
+		if err := templates.ExecuteTemplate(w, "event-modal", data); err != nil {
+			log.Printf("Failed to render event modal: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
 		return
 	}
 
 	data := struct {
-		Event *database.Event
+		Event             *database.Event
+		OrganizationTitle string
+		Theme             pageTheme
+		Version           string
 	}{
-		Event: event,
+		Event:             event,
+		OrganizationTitle: getOrganizationTitle(snap.Organization),
+		Theme:             h.theme(r, snap),
+		Version:           h.version,
 	}
 
-	if err := h.templates.ExecuteTemplate(w, "event-modal", data); err != nil {
-		log.Printf("Failed to render event modal: %v", err)
+	if err := templates.ExecuteTemplate(w, "event.html", data); err != nil {
+		log.Printf("Failed to render event: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 	}
 }
@@ -191,54 +406,55 @@ func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hasEvents, err := h.db.HasEventsForOrganization(orgID)
-	if err != nil {
-		log.Printf("Failed to check events for organization %d: %v", orgID, err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	if status, err := h.ensureScraped(orgID); err != nil {
+		http.Error(w, ensureScrapedMessage(err), status)
 		return
 	}
 
-	if !hasEvents {
-		log.Printf("No events for organization %d, scraping synchronously", orgID)
-		if err := h.scraper.ScrapeOrganization(orgID); err != nil {
-			log.Printf("Failed to scrape organization %d: %v", orgID, err)
-			http.Error(w, "Failed to fetch events", http.StatusInternalServerError)
-			return
-		}
-	}
-
-	org, err := h.db.GetOrganization(orgID)
+	snap, err := h.snapshot(orgID)
 	if err != nil {
-		log.Printf("Failed to get organization %d: %v", orgID, err)
-	}
-
-	color := r.URL.Query().Get("color")
-
-	events, err := h.db.GetAllUpcomingEventsByOrganization(orgID)
-	if err != nil {
-		log.Printf("Failed to get upcoming events: %v", err)
+		log.Printf("Failed to load organization %d: %v", orgID, err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	data := struct {
-		OrganizationID    int
-		OrganizationTitle string
-		Events            []*database.Event
-		Color             string
-		Version           string
-	}{
-		OrganizationID:    orgID,
-		OrganizationTitle: getOrganizationTitle(org),
-		Events:            events,
-		Color:             color,
-		Version:           h.version,
+	locale := h.locale(w, r)
+	etag, done := h.notModified(w, r, "list", snap.Version)
+	if done {
+		return
 	}
 
-	if err := h.templates.ExecuteTemplate(w, "list.html", data); err != nil {
+	body, err := h.cachedBody(orgID, etag, func() ([]byte, error) {
+		data := struct {
+			OrganizationID    int
+			OrganizationTitle string
+			Events            []*database.Event
+			Color             string
+			OEmbedURL         string
+			Theme             pageTheme
+			Version           string
+		}{
+			OrganizationID:    orgID,
+			OrganizationTitle: getOrganizationTitle(snap.Organization),
+			Events:            parseEventFilter(r).Apply(upcomingEvents(snap.Events)),
+			Color:             r.URL.Query().Get("color"),
+			OEmbedURL:         h.oembedLink(r),
+			Theme:             h.theme(r, snap),
+			Version:           h.version,
+		}
+
+		var buf bytes.Buffer
+		err := h.templatesFor(locale).ExecuteTemplate(&buf, "list.html", data)
+		return buf.Bytes(), err
+	})
+	if err != nil {
 		log.Printf("Failed to render list: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(body)
 }
 
 func (h *Handler) ICalendar(w http.ResponseWriter, r *http.Request) {
@@ -249,88 +465,255 @@ func (h *Handler) ICalendar(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hasEvents, err := h.db.HasEventsForOrganization(orgID)
+	from, to, err := parseWindow(r, startOfDay(time.Now()))
 	if err != nil {
-		log.Printf("Failed to check events for organization %d: %v", orgID, err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if !hasEvents {
-		log.Printf("No events for organization %d, scraping synchronously", orgID)
-		if err := h.scraper.ScrapeOrganization(orgID); err != nil {
-			log.Printf("Failed to scrape organization %d: %v", orgID, err)
-			http.Error(w, "Failed to fetch events", http.StatusInternalServerError)
-			return
-		}
+	alarms, alarmsSet, err := parseAlarms(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if status, err := h.ensureScraped(orgID); err != nil {
+		http.Error(w, ensureScrapedMessage(err), status)
+		return
 	}
 
-	org, err := h.db.GetOrganization(orgID)
+	snap, err := h.snapshot(orgID)
 	if err != nil {
-		log.Printf("Failed to get organization %d: %v", orgID, err)
+		log.Printf("Failed to load organization %d: %v", orgID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
 
-	title := getOrganizationTitle(org)
+	etag, done := h.notModified(w, r, "ical", snap.Version)
+	if done {
+		return
+	}
+
+	body, err := h.cachedBody(orgID, etag, func() ([]byte, error) {
+		title := getOrganizationTitle(snap.Organization)
 
-	events, err := h.db.GetEventsByOrganization(orgID)
+		name := title
+		if v := strings.TrimSpace(r.URL.Query().Get("name")); v != "" {
+			name = truncate(v, 100)
+		}
+
+		cal := &ical.Calendar{
+			Name:            name,
+			Description:     fmt.Sprintf("Events calendar for %s", title),
+			Location:        database.Berlin,
+			RefreshInterval: time.Hour,
+		}
+		organizer := newICalOrganizer(orgID, title)
+
+		for _, event := range parseEventFilter(r).Apply(eventsInRange(snap.Events, from, to)) {
+			icalEvent := newICalEvent(event, organizer)
+
+			eventAlarms := alarms
+			if !alarmsSet {
+				eventAlarms = h.config.GetAlarms(event.Activity.String)
+			}
+			for _, before := range eventAlarms {
+				icalEvent.Alarms = append(icalEvent.Alarms, ical.Alarm{Before: before})
+			}
+
+			cal.Events = append(cal.Events, icalEvent)
+		}
+
+		var buf bytes.Buffer
+		err := cal.Encode(&buf)
+		return buf.Bytes(), err
+	})
 	if err != nil {
-		log.Printf("Failed to get events for organization %d: %v", orgID, err)
+		log.Printf("Failed to serialize iCal: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	berlin, err := time.LoadLocation("Europe/Berlin")
-	if err != nil {
-		log.Printf("Failed to load Berlin timezone: %v", err)
-		berlin = time.UTC
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%d.ics\"", orgID))
+	w.Write(body)
+}
+
+// newICalOrganizer points to the Zetkin page of an organization. It is only
+// set on events scraped from Zetkin, see newICalEvent.
+func newICalOrganizer(orgID int, title string) *ical.Organizer {
+	return &ical.Organizer{
+		Name: title,
+		URI:  fmt.Sprintf("https://app.zetkin.die-linke.de/o/%d", orgID),
 	}
+}
 
-	cal := ics.NewCalendar()
-	cal.SetMethod(ics.MethodPublish)
-	cal.SetName(title)
-	cal.SetDescription(fmt.Sprintf("Events calendar for %s", title))
-	cal.SetXPublishedTTL("PT1H")
-	cal.SetTimezoneId("Europe/Berlin")
+func newICalEvent(event *database.Event, organizer *ical.Organizer) *ical.Event {
+	start, end := eventTimes(event)
 
-	for _, event := range events {
-		icalEvent := cal.AddEvent(fmt.Sprintf("%d-%d@linke-calendar", orgID, event.ID))
-		icalEvent.SetCreatedTime(event.CreatedAt)
-		icalEvent.SetModifiedAt(event.UpdatedAt)
-		icalEvent.SetStartAt(reinterpretTimeInLocation(event.DatetimeStart, berlin))
-
-		if event.DatetimeEnd.Valid {
-			duration := event.DatetimeEnd.Time.Sub(event.DatetimeStart)
-			if duration > 4*24*time.Hour {
-				icalEvent.SetEndAt(reinterpretTimeInLocation(event.DatetimeStart, berlin).Add(1 * time.Hour))
-			} else {
-				icalEvent.SetEndAt(reinterpretTimeInLocation(event.DatetimeEnd.Time, berlin))
-			}
-		} else {
-			icalEvent.SetEndAt(reinterpretTimeInLocation(event.DatetimeStart, berlin).Add(1 * time.Hour))
+	icalEvent := &ical.Event{
+		UID:      event.UID(),
+		Created:  event.CreatedAt,
+		Modified: eventLastModified(event),
+		Start:    start,
+		End:      end,
+		AllDay:   event.AllDay,
+		Summary:  event.Title,
+		Status:   ical.StatusConfirmed,
+		Sequence: event.Sequence,
+	}
+	if event.Scraper == database.ScraperZetkin {
+		icalEvent.Organizer = organizer
+	}
+
+	if event.Description.Valid {
+		icalEvent.Description = event.Description.String
+	}
+
+	if event.Location.Valid {
+		icalEvent.Location = event.Location.String
+	}
+
+	if event.Latitude.Valid && event.Longitude.Valid {
+		icalEvent.Geo = &ical.Geo{Latitude: event.Latitude.Float64, Longitude: event.Longitude.Float64}
+	}
+
+	if event.Activity.Valid {
+		icalEvent.Categories = append(icalEvent.Categories, event.Activity.String)
+	}
+
+	if event.Campaign.Valid {
+		icalEvent.Categories = append(icalEvent.Categories, event.Campaign.String)
+	}
+
+	if event.HasLink() {
+		icalEvent.URL = event.URL
+	}
+
+	return icalEvent
+}
+
+// eventTimes returns start and end of an event in Berlin time. All-day
+// events get an exclusive end date, events without a usable end last an hour.
+func eventTimes(event *database.Event) (time.Time, time.Time) {
+	if event.AllDay {
+		return event.DatetimeStart, allDayEnd(event)
+	}
+
+	start := reinterpretTimeInLocation(event.DatetimeStart, database.Berlin)
+
+	if event.DatetimeEnd.Valid {
+		duration := event.DatetimeEnd.Time.Sub(event.DatetimeStart)
+		if duration <= 4*24*time.Hour {
+			return start, reinterpretTimeInLocation(event.DatetimeEnd.Time, database.Berlin)
 		}
+	}
+	return start, start.Add(1 * time.Hour)
+}
 
-		icalEvent.SetSummary(event.Title)
+// parseAlarms reads reminders like "?alarm=1h,1d", which replace the
+// configured defaults. "?alarm=none" turns reminders off.
+func parseAlarms(r *http.Request) ([]time.Duration, bool, error) {
+	if _, ok := r.URL.Query()["alarm"]; !ok {
+		return nil, false, nil
+	}
+
+	values := parseListParam(r, "alarm")
+	if len(values) == 1 && values[0] == "none" {
+		return nil, true, nil
+	}
+	if len(values) > 5 {
+		return nil, false, errors.New("invalid alarm parameter: at most 5 reminders")
+	}
+
+	alarms := make([]time.Duration, 0, len(values))
+	for _, value := range values {
+		d, err := ical.ParseTrigger(value)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid alarm parameter: %w", err)
+		}
+		alarms = append(alarms, d)
+	}
+	return alarms, true, nil
+}
+
+// errScrapeFailed is returned by ensureScraped if the first scrape of an
+// organization failed.
+var errScrapeFailed = errors.New("failed to fetch events")
+
+// ensureScraped scrapes an organization synchronously on first access, so
+// new organizations are discovered via their URL. Callers answer with the
+// returned status and ensureScrapedMessage.
+func (h *Handler) ensureScraped(orgID int) (int, error) {
+	if cached, ok := h.cache.Get(snapshotKey(orgID)); ok && len(cached.(*orgSnapshot).Events) > 0 {
+		return http.StatusOK, nil
+	}
+
+	hasEvents, err := h.db.HasEventsForOrganization(orgID)
+	if err != nil {
+		log.Printf("Failed to check events for organization %d: %v", orgID, err)
+		return http.StatusInternalServerError, err
+	}
 
-		if event.Description.Valid {
-			icalEvent.SetDescription(event.Description.String)
+	if !hasEvents {
+		if org, err := h.db.GetOrganization(orgID); err == nil && org.Paused {
+			return http.StatusOK, nil
 		}
 
-		if event.Location.Valid {
-			icalEvent.SetLocation(event.Location.String)
+		log.Printf("No events for organization %d, scraping synchronously", orgID)
+		if err := h.scraper.ScrapeOrganization(orgID); err != nil {
+			log.Printf("Failed to scrape organization %d: %v", orgID, err)
+			return http.StatusInternalServerError, errScrapeFailed
 		}
+	}
 
-		if event.URL != "" {
-			icalEvent.SetURL(event.URL)
+	return http.StatusOK, nil
+}
+
+// ensureScrapedMessage returns the response message of an ensureScraped
+// error.
+func ensureScrapedMessage(err error) string {
+	if errors.Is(err, errScrapeFailed) {
+		return "Failed to fetch events"
+	}
+	return "Internal server error"
+}
+
+// publicEvents applies the organization's rules and drops every event that
+// is hidden by a rule or an override.
+func (h *Handler) publicEvents(orgID int, events []*database.Event) ([]*database.Event, error) {
+	orgRules, err := h.db.GetRulesByOrganization(orgID)
+	if err != nil {
+		return nil, err
+	}
+	rules.Apply(orgRules, events)
+
+	visible := make([]*database.Event, 0, len(events))
+	for _, event := range events {
+		if !event.Hidden {
+			visible = append(visible, event)
 		}
 	}
+	return visible, nil
+}
 
-	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%d.ics\"", orgID))
+// allDayEnd returns the exclusive end date of an all-day event as required
+// by DTEND;VALUE=DATE.
+func allDayEnd(event *database.Event) time.Time {
+	start := time.Date(event.DatetimeStart.Year(), event.DatetimeStart.Month(), event.DatetimeStart.Day(), 0, 0, 0, 0, time.UTC)
+	if !event.DatetimeEnd.Valid {
+		return start.AddDate(0, 0, 1)
+	}
 
-	if err := cal.SerializeTo(w); err != nil {
-		log.Printf("Failed to serialize iCal: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	end := event.DatetimeEnd.Time
+	endDay := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+	if end.Hour() != 0 || end.Minute() != 0 {
+		endDay = endDay.AddDate(0, 0, 1)
+	}
+	if !endDay.After(start) {
+		return start.AddDate(0, 0, 1)
 	}
+	return endDay
 }
 
 func reinterpretTimeInLocation(t time.Time, loc *time.Location) time.Time {
@@ -346,3 +729,12 @@ func getOrganizationTitle(org *database.Organization) string {
 	}
 	return "Unknown Organization"
 }
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/romanzipp/linke-calendar/internal/database"
+)
+
+func TestNewICalEventOrganizer(t *testing.T) {
+	organizer := newICalOrganizer(192, "Die Linke Fulda")
+
+	tests := []struct {
+		scraper string
+		want    bool
+	}{
+		{database.ScraperZetkin, true},
+		{"mobilizon", false},
+		{database.ScraperManual, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scraper, func(t *testing.T) {
+			event := &database.Event{
+				ID:             1,
+				OrganizationID: 192,
+				Title:          "Infostand",
+				Scraper:        tt.scraper,
+				DatetimeStart:  time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC),
+			}
+			if got := newICalEvent(event, organizer).Organizer != nil; got != tt.want {
+				t.Errorf("organizer set = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAlarms(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    []time.Duration
+		wantSet bool
+		wantErr bool
+	}{
+		{"not set", "", nil, false, false},
+		{"none", "alarm=none", nil, true, false},
+		{"empty", "alarm=", []time.Duration{}, true, false},
+		{"single", "alarm=30m", []time.Duration{30 * time.Minute}, true, false},
+		{"list", "alarm=1d,2h", []time.Duration{24 * time.Hour, 2 * time.Hour}, true, false},
+		{"repeated parameter", "alarm=1w&alarm=1H", []time.Duration{7 * 24 * time.Hour, time.Hour}, true, false},
+		{"spaces", "alarm=+1h+,+15m", []time.Duration{time.Hour, 15 * time.Minute}, true, false},
+		{"invalid unit", "alarm=1s", nil, false, true},
+		{"invalid number", "alarm=xh", nil, false, true},
+		{"more than 4 weeks", "alarm=5w", nil, false, true},
+		{"exactly 4 weeks", "alarm=28d", []time.Duration{28 * 24 * time.Hour}, true, false},
+		{"overflowing hours", "alarm=3000000h", nil, false, true},
+		{"overflowing minutes", "alarm=9223372036854775807m", nil, false, true},
+		{"too many", "alarm=1m,2m,3m,4m,5m,6m", nil, false, true},
+		{"none with others", "alarm=none,1h", nil, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/org/1/ical?"+tt.query, nil)
+			got, set, err := parseAlarms(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAlarms() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if set != tt.wantSet {
+				t.Errorf("parseAlarms() set = %v, want %v", set, tt.wantSet)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseAlarms() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnsureScrapedMessage(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{errScrapeFailed, "Failed to fetch events"},
+		{fmt.Errorf("scrape: %w", errScrapeFailed), "Failed to fetch events"},
+		{errors.New("database is locked"), "Internal server error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			if got := ensureScrapedMessage(tt.err); got != tt.want {
+				t.Errorf("ensureScrapedMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindSnapshotEvent(t *testing.T) {
+	events := []*database.Event{
+		{ID: 7, OrganizationID: 1, SourceID: sql.NullString{String: database.ZetkinSourceID(4711), Valid: true}},
+		{ID: 8, OrganizationID: 1},
+	}
+
+	tests := []struct {
+		name string
+		id   int
+		key  string
+		want *database.Event
+	}{
+		{"source ID", 0, database.ZetkinSourceID(4711), events[0]},
+		{"legacy database ID", 7, "7", events[0]},
+		{"UID without source ID", 0, "1-8@linke-calendar", events[1]},
+		{"unknown source ID", 0, database.ZetkinSourceID(1), nil},
+		{"unknown database ID", 9, "9", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findSnapshotEvent(events, tt.id, tt.key); got != tt.want {
+				t.Errorf("findSnapshotEvent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/romanzipp/linke-calendar/internal/i18n"
+)
+
+// parseTemplates parses the public templates once per language, each with
+// the translation functions of its locale.
+func parseTemplates() (map[string]*template.Template, error) {
+	files, err := filepath.Glob("web/templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	var public []string
+	for _, file := range files {
+		if !strings.HasPrefix(filepath.Base(file), "admin-") {
+			public = append(public, file)
+		}
+	}
+
+	result := make(map[string]*template.Template)
+	for _, locale := range i18n.Languages() {
+		tmpl, err := template.New("").Funcs(template.FuncMap{
+			"calendarLinks": newCalendarLinks,
+			"eventPath":     eventPath,
+		}).Funcs(localeFuncs(locale)).ParseFiles(public...)
+		if err != nil {
+			return nil, err
+		}
+		result[locale.Lang] = tmpl
+	}
+	return result, nil
+}
+
+// parseAdminTemplates parses the German admin area. The event fields shared
+// with the submission form take their labels from the German catalog. The
+// set is only cloned by renderAdmin, which replaces the placeholder
+// functions per request.
+func parseAdminTemplates() (*template.Template, error) {
+	files, err := filepath.Glob("web/templates/admin-*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	return template.New("").Funcs(template.FuncMap{
+		"t":          i18n.Get(i18n.DefaultLanguage).T,
+		"adminUser":  func() *adminUser { return nil },
+		"csrfField":  func() template.HTML { return "" },
+		"roleLabel":  func(role string) string { return roleLabels[role] },
+		"scopeLabel": func(scope string) string { return scopeLabels[scope] },
+		"runLabel":   func(status string) string { return runLabels[status] },
+	}).ParseFiles(append(files, "web/templates/event-fields.html")...)
+}
+
+func localeFuncs(locale *i18n.Locale) template.FuncMap {
+	return template.FuncMap{
+		"t":    locale.T,
+		"tn":   locale.N,
+		"lang": func() string { return locale.Lang },
+		"dir":  locale.Dir,
+		"weekday": func(t time.Time) string {
+			return locale.Weekday(t.Weekday())
+		},
+		"weekdayShort": func(t time.Time) string {
+			return locale.WeekdayShort(t.Weekday())
+		},
+		"weekdaysShort":     locale.WeekdaysShort,
+		"monthName":         locale.Month,
+		"formatDate":        locale.Date,
+		"formatDayMonth":    locale.DayMonth,
+		"formatClock":       locale.Clock,
+		"formatTime":        locale.Time,
+		"formatLongDate":    locale.LongDate,
+		"formatWeekdayDate": locale.WeekdayDate,
+		"formatMonthYear":   locale.MonthYear,
+	}
+}
+
+// locale picks the language of a public page from ?lang= or the
+// Accept-Language header. notModified includes the Content-Language in the
+// ETag, so it must be called first.
+func (h *Handler) locale(w http.ResponseWriter, r *http.Request) *i18n.Locale {
+	locale := i18n.Negotiate(r.URL.Query().Get("lang"), r.Header.Get("Accept-Language"))
+	w.Header().Set("Content-Language", locale.Lang)
+	w.Header().Add("Vary", "Accept-Language")
+	return locale
+}
+
+func (h *Handler) templatesFor(locale *i18n.Locale) *template.Template {
+	if tmpl, ok := h.localized[locale.Lang]; ok {
+		return tmpl
+	}
+	return h.templates
+}
+
+// langParam returns the explicitly requested language to keep it in links.
+func langParam(r *http.Request) string {
+	lang := strings.ToLower(r.URL.Query().Get("lang"))
+	if !i18n.Supported(lang) {
+		return ""
+	}
+	return lang
+}
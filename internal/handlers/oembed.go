@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/romanzipp/linke-calendar/internal/i18n"
+)
+
+var oembedPathPattern = regexp.MustCompile(`^/org/(\d+)/(calendar|year|list)/?$`)
+
+const oembedWidth = 800
+
+// oembedHeights are the iframe heights of the embeddable pages, as
+// recommended for manual embeds.
+var oembedHeights = map[string]int{
+	"calendar": 930,
+	"year":     1100,
+	"list":     630,
+}
+
+type oembedResponse struct {
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	Title        string `json:"title"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	CacheAge     int    `json:"cache_age"`
+}
+
+// OEmbed describes the calendar, year and list pages as rich oEmbed content,
+// so editors that support it turn a pasted link into an iframe.
+func (h *Handler) OEmbed(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if format := query.Get("format"); format != "" && format != "json" {
+		http.Error(w, "Only JSON is supported", http.StatusNotImplemented)
+		return
+	}
+
+	base := h.publicURL(r)
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		log.Printf("Invalid public URL %q: %v", base, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	target, orgID, page, ok := parseOEmbedURL(query.Get("url"), baseURL.Host)
+	if !ok {
+		http.Error(w, "Unsupported URL", http.StatusNotFound)
+		return
+	}
+
+	if status, err := h.ensureScraped(orgID); err != nil {
+		http.Error(w, ensureScrapedMessage(err), status)
+		return
+	}
+
+	snap, err := h.snapshot(orgID)
+	if err != nil {
+		log.Printf("Failed to load organization %d: %v", orgID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if snap.Organization == nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+
+	locale := i18n.Negotiate(target.Query().Get("lang"), r.Header.Get("Accept-Language"))
+	w.Header().Set("Content-Language", locale.Lang)
+	w.Header().Add("Vary", "Accept-Language")
+
+	if _, done := h.notModified(w, r, "oembed", snap.Version); done {
+		return
+	}
+
+	width, height := oembedSize(page, query)
+
+	year := strconv.Itoa(startOfDay(time.Now()).Year())
+	if y, err := strconv.Atoi(target.Query().Get("year")); err == nil && y > 0 && y < 10000 {
+		year = strconv.Itoa(y)
+	}
+	title := locale.T(page+".title", "organization", getOrganizationTitle(snap.Organization), "year", year)
+
+	src := fmt.Sprintf("%s/org/%d/%s", base, orgID, page)
+	if target.RawQuery != "" {
+		src += "?" + target.Query().Encode()
+	}
+
+	writeJSON(w, http.StatusOK, oembedResponse{
+		Version:      "1.0",
+		Type:         "rich",
+		ProviderName: "Linke Calendar",
+		ProviderURL:  base,
+		Title:        title,
+		HTML: fmt.Sprintf(`<iframe src="%s" title="%s" width="%d" height="%d" style="border:0;width:100%%"></iframe>`,
+			html.EscapeString(src), html.EscapeString(title), width, height),
+		Width:    width,
+		Height:   height,
+		CacheAge: 3600,
+	})
+}
+
+// parseOEmbedURL validates the url parameter, which must point to an
+// embeddable page of this host.
+func parseOEmbedURL(value, host string) (*url.URL, int, string, bool) {
+	target, err := url.Parse(value)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || !strings.EqualFold(target.Host, host) {
+		return nil, 0, "", false
+	}
+
+	match := oembedPathPattern.FindStringSubmatch(target.Path)
+	if match == nil {
+		return nil, 0, "", false
+	}
+	orgID, err := strconv.Atoi(match[1])
+	if err != nil {
+		return nil, 0, "", false
+	}
+	return target, orgID, match[2], true
+}
+
+// oembedSize returns the iframe size of a page, limited by maxwidth and
+// maxheight.
+func oembedSize(page string, query url.Values) (int, int) {
+	width := oembedWidth
+	if v, err := strconv.Atoi(query.Get("maxwidth")); err == nil && v > 0 && v < width {
+		width = v
+	}
+	height := oembedHeights[page]
+	if v, err := strconv.Atoi(query.Get("maxheight")); err == nil && v > 0 && v < height {
+		height = v
+	}
+	return width, height
+}
+
+// oembedLink is the discovery URL of the oEmbed description of a page.
+func (h *Handler) oembedLink(r *http.Request) string {
+	base := h.publicURL(r)
+	return base + "/oembed?format=json&url=" + url.QueryEscape(base+r.URL.RequestURI())
+}
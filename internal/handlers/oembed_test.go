@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/romanzipp/linke-calendar/internal/config"
+)
+
+func TestParseOEmbedURL(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		ok    bool
+		orgID int
+		page  string
+	}{
+		{"calendar", "https://example.org/org/42/calendar", true, 42, "calendar"},
+		{"year with query", "https://example.org/org/42/year?year=2024", true, 42, "year"},
+		{"list with slash", "http://example.org/org/7/list/", true, 7, "list"},
+		{"host case", "https://EXAMPLE.org/org/42/list", true, 42, "list"},
+		{"other host", "https://evil.example/org/42/calendar", false, 0, ""},
+		{"host with port", "https://example.org:8080/org/42/calendar", false, 0, ""},
+		{"other scheme", "javascript://example.org/org/42/calendar", false, 0, ""},
+		{"relative", "/org/42/calendar", false, 0, ""},
+		{"empty", "", false, 0, ""},
+		{"event page", "https://example.org/org/42/event/1", false, 0, ""},
+		{"feed", "https://example.org/org/42/ical", false, 0, ""},
+		{"no org", "https://example.org/org/abc/calendar", false, 0, ""},
+		{"suffix", "https://example.org/org/42/calendarx", false, 0, ""},
+		{"org out of range", "https://example.org/org/99999999999999999999/calendar", false, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, orgID, page, ok := parseOEmbedURL(tt.value, "example.org")
+			if ok != tt.ok || orgID != tt.orgID || page != tt.page {
+				t.Errorf("parseOEmbedURL() = %d, %q, %v, want %d, %q, %v", orgID, page, ok, tt.orgID, tt.page, tt.ok)
+			}
+		})
+	}
+}
+
+func TestOEmbedSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		page          string
+		query         string
+		width, height int
+	}{
+		{"calendar", "calendar", "", 800, 930},
+		{"year", "year", "", 800, 1100},
+		{"list", "list", "", 800, 630},
+		{"smaller", "calendar", "maxwidth=600&maxheight=500", 600, 500},
+		{"larger is ignored", "list", "maxwidth=1200&maxheight=2000", 800, 630},
+		{"zero is ignored", "list", "maxwidth=0&maxheight=0", 800, 630},
+		{"negative is ignored", "list", "maxwidth=-1&maxheight=-1", 800, 630},
+		{"invalid is ignored", "list", "maxwidth=abc&maxheight=1e3", 800, 630},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if width, height := oembedSize(tt.page, query); width != tt.width || height != tt.height {
+				t.Errorf("oembedSize() = %d, %d, want %d, %d", width, height, tt.width, tt.height)
+			}
+		})
+	}
+}
+
+func TestOEmbedRejects(t *testing.T) {
+	h := &Handler{config: &config.Config{}}
+
+	tests := []struct {
+		name   string
+		query  url.Values
+		status int
+	}{
+		{"xml", url.Values{"format": {"xml"}, "url": {"http://example.com/org/1/calendar"}}, http.StatusNotImplemented},
+		{"missing url", url.Values{}, http.StatusNotFound},
+		{"other host", url.Values{"url": {"http://evil.example/org/1/calendar"}}, http.StatusNotFound},
+		{"other page", url.Values{"url": {"http://example.com/admin"}}, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.OEmbed(w, httptest.NewRequest("GET", "/oembed?"+tt.query.Encode(), nil))
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimiter allows limit hits per key within a sliding window.
+type rateLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	hits   map[string][]time.Time
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:  limit,
+		window: window,
+		hits:   make(map[string][]time.Time),
+	}
+}
+
+func (l *rateLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	cutoff := now.Add(-l.window)
+
+	for k, hits := range l.hits {
+		if len(hits) > 0 && hits[len(hits)-1].Before(cutoff) {
+			delete(l.hits, k)
+		}
+	}
+
+	recent := l.hits[key][:0]
+	for _, t := range l.hits[key] {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+
+	if len(recent) >= l.limit {
+		l.hits[key] = recent
+		return false
+	}
+
+	l.hits[key] = append(recent, now)
+	return true
+}
+
+// Limited reports whether key has used up its hits without recording one,
+// so callers can count only failed attempts with Allow.
+func (l *rateLimiter) Limited(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := time.Now().Add(-l.window)
+	recent := 0
+	for _, t := range l.hits[key] {
+		if t.After(cutoff) {
+			recent++
+		}
+	}
+	return recent >= l.limit
+}
+
+// clientIP returns the address a request came from. Forwarding headers are
+// only honored if the connection comes from a trusted proxy, anyone else
+// could set them to get around the rate limits.
+func clientIP(r *http.Request, proxies []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if !fromTrustedProxy(r, proxies) {
+		return host
+	}
+
+	// Proxies append to X-Forwarded-For, so the first untrusted address
+	// from the right is the client. Entries left of it may be forged.
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			break
+		}
+		if !trusted(hop, proxies) {
+			return hop.Unmap().String()
+		}
+	}
+
+	if realIP, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return realIP.Unmap().String()
+	}
+
+	return host
+}
+
+// fromTrustedProxy reports whether a request was sent by a trusted proxy,
+// whose forwarding headers may be honored.
+func fromTrustedProxy(r *http.Request, proxies []netip.Prefix) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	return err == nil && trusted(addr, proxies)
+}
+
+func trusted(addr netip.Addr, proxies []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, proxy := range proxies {
+		if proxy.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/romanzipp/linke-calendar/internal/config"
+)
+
+func TestRateLimiter(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int
+		window  time.Duration
+		keys    []string
+		want    []bool
+		limited map[string]bool
+	}{
+		{
+			name:    "within limit",
+			limit:   2,
+			window:  time.Hour,
+			keys:    []string{"a", "a"},
+			want:    []bool{true, true},
+			limited: map[string]bool{"a": true},
+		},
+		{
+			name:    "over limit",
+			limit:   2,
+			window:  time.Hour,
+			keys:    []string{"a", "a", "a"},
+			want:    []bool{true, true, false},
+			limited: map[string]bool{"a": true},
+		},
+		{
+			name:    "keys are separate",
+			limit:   1,
+			window:  time.Hour,
+			keys:    []string{"a", "b", "a"},
+			want:    []bool{true, true, false},
+			limited: map[string]bool{"a": true, "b": true, "c": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := newRateLimiter(tt.limit, tt.window)
+			for i, key := range tt.keys {
+				if got := limiter.Allow(key); got != tt.want[i] {
+					t.Errorf("Allow(%q) #%d = %v, want %v", key, i, got, tt.want[i])
+				}
+			}
+			for key, want := range tt.limited {
+				if got := limiter.Limited(key); got != want {
+					t.Errorf("Limited(%q) = %v, want %v", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterWindow(t *testing.T) {
+	limiter := newRateLimiter(1, 10*time.Millisecond)
+	if !limiter.Allow("a") || limiter.Allow("a") {
+		t.Fatal("expected the second hit to be limited")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if limiter.Limited("a") {
+		t.Error("still limited after the window")
+	}
+	if !limiter.Allow("a") {
+		t.Error("hit not allowed after the window")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies := []netip.Prefix{
+		netip.MustParsePrefix("127.0.0.1/32"),
+		netip.MustParsePrefix("10.0.0.0/8"),
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		realIP     string
+		proxies    []netip.Prefix
+		want       string
+	}{
+		{"direct", "203.0.113.7:4321", "", "", proxies, "203.0.113.7"},
+		{"spoofed without proxy", "203.0.113.7:4321", "198.51.100.1", "198.51.100.2", proxies, "203.0.113.7"},
+		{"no proxies configured", "127.0.0.1:4321", "198.51.100.1", "", nil, "127.0.0.1"},
+		{"behind proxy", "127.0.0.1:4321", "198.51.100.1", "", proxies, "198.51.100.1"},
+		{"forged entry left of client", "127.0.0.1:4321", "192.0.2.9, 198.51.100.1", "", proxies, "198.51.100.1"},
+		{"chain of proxies", "127.0.0.1:4321", "198.51.100.1, 10.1.2.3", "", proxies, "198.51.100.1"},
+		{"real ip header", "10.0.0.2:4321", "", "198.51.100.3", proxies, "198.51.100.3"},
+		{"invalid header", "127.0.0.1:4321", "unknown", "", proxies, "127.0.0.1"},
+		{"ipv6 client", "[::1]:4321", "", "", proxies, "::1"},
+		{"ipv4 mapped proxy", "[::ffff:127.0.0.1]:4321", "198.51.100.1", "", proxies, "198.51.100.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			if got := clientIP(r, tt.proxies); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubmitColor(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", ""},
+		{"color=white", "white"},
+		{"color=black", "black"},
+		{"color=red", ""},
+		{"color=white%26sent%3D0", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/org/1/submit?"+tt.query, nil)
+			if got := submitColor(r); got != tt.want {
+				t.Errorf("submitColor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublicURL(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("127.0.0.1/32")}
+
+	tests := []struct {
+		name       string
+		publicURL  string
+		remoteAddr string
+		proto      string
+		tls        bool
+		want       string
+	}{
+		{"plain", "", "203.0.113.7:4321", "", false, "http://example.org"},
+		{"tls", "", "203.0.113.7:4321", "", true, "https://example.org"},
+		{"spoofed proto", "", "203.0.113.7:4321", "https", false, "http://example.org"},
+		{"proto from proxy", "", "127.0.0.1:4321", "https", false, "https://example.org"},
+		{"configured", "https://calendar.example.org/", "203.0.113.7:4321", "http", false, "https://calendar.example.org"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Server.PublicURL = tt.publicURL
+			h := &Handler{config: cfg, proxies: proxies}
+
+			r := httptest.NewRequest("GET", "http://example.org/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.proto != "" {
+				r.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			if tt.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+
+			if got := h.publicURL(r); got != tt.want {
+				t.Errorf("publicURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
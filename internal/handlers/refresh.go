@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/romanzipp/linke-calendar/internal/auth"
+	"github.com/romanzipp/linke-calendar/internal/database"
+	"github.com/romanzipp/linke-calendar/internal/scraper"
+)
+
+type apiScrapeRun struct {
+	ID             int     `json:"id"`
+	OrganizationID int     `json:"organization_id"`
+	Status         string  `json:"status"`
+	Error          *string `json:"error"`
+	QueuedAt       string  `json:"queued_at"`
+	StartedAt      *string `json:"started_at"`
+	FinishedAt     *string `json:"finished_at"`
+}
+
+// APIRefresh queues a scrape of an organization for a token with the
+// refresh scope. A refresh that is still waiting is returned instead of
+// queueing another one.
+func (h *Handler) APIRefresh(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid organization ID")
+		return
+	}
+
+	token := h.requireAPIToken(w, r, orgID, database.ScopeRefresh)
+	if token == nil {
+		return
+	}
+
+	if !h.refreshes.Allow(strconv.Itoa(token.ID)) {
+		writeJSONError(w, http.StatusTooManyRequests, "Too many refreshes, try again later")
+		return
+	}
+
+	run, err := h.scraper.Enqueue(orgID, sql.NullInt64{Int64: int64(token.ID), Valid: true})
+	if err != nil {
+		if errors.Is(err, scraper.ErrQueueFull) {
+			writeJSONError(w, http.StatusServiceUnavailable, "Too many queued refreshes, try again later")
+			return
+		}
+		log.Printf("Failed to queue scrape of organization %d: %v", orgID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/orgs/%d/runs/%d", orgID, run.ID))
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"data": newAPIScrapeRun(run)})
+}
+
+// APIScrapeRun returns the status of a queued scrape.
+func (h *Handler) APIScrapeRun(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid organization ID")
+		return
+	}
+
+	runID, err := strconv.Atoi(chi.URLParam(r, "runID"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid run ID")
+		return
+	}
+
+	if h.requireAPIToken(w, r, orgID, database.ScopeRefresh) == nil {
+		return
+	}
+
+	run, err := h.db.GetScrapeRun(runID, orgID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, "Run not found")
+			return
+		}
+		log.Printf("Failed to get scrape run %d: %v", runID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": newAPIScrapeRun(run)})
+}
+
+// requireAPIToken checks the bearer token of a request against an
+// organization and scope. It writes the error response and returns nil if
+// the token is missing or not allowed.
+func (h *Handler) requireAPIToken(w http.ResponseWriter, r *http.Request, orgID int, scope string) *database.APIToken {
+	token := h.apiToken(r)
+	if token == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="linke-calendar"`)
+		writeJSONError(w, http.StatusUnauthorized, "Missing or invalid API token")
+		return nil
+	}
+	if token.OrganizationID != orgID || !token.HasScope(scope) {
+		writeJSONError(w, http.StatusForbidden, "API token is not allowed to do this")
+		return nil
+	}
+	return token
+}
+
+// apiToken returns the token of the Authorization header, or nil.
+func (h *Handler) apiToken(r *http.Request) *database.APIToken {
+	scheme, secret, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || secret == "" {
+		return nil
+	}
+
+	token, err := h.db.GetAPITokenByHash(auth.HashToken(strings.TrimSpace(secret)))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Failed to get API token: %v", err)
+		}
+		return nil
+	}
+
+	if err := h.db.UpdateAPITokenLastUsed(token.ID, time.Now().UTC()); err != nil {
+		log.Printf("Failed to update API token %d: %v", token.ID, err)
+	}
+	return token
+}
+
+func newAPIScrapeRun(run *database.ScrapeRun) apiScrapeRun {
+	result := apiScrapeRun{
+		ID:             run.ID,
+		OrganizationID: run.OrganizationID,
+		Status:         run.Status,
+		QueuedAt:       run.QueuedAt.UTC().Format(time.RFC3339),
+	}
+	if run.Error.Valid {
+		result.Error = &run.Error.String
+	}
+	if run.StartedAt.Valid {
+		startedAt := run.StartedAt.Time.UTC().Format(time.RFC3339)
+		result.StartedAt = &startedAt
+	}
+	if run.FinishedAt.Valid {
+		finishedAt := run.FinishedAt.Time.UTC().Format(time.RFC3339)
+		result.FinishedAt = &finishedAt
+	}
+	return result
+}
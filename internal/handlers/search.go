@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/romanzipp/linke-calendar/internal/database"
+)
+
+// searchResult is a public event matching a search. The texts are escaped
+// with the matches wrapped in <mark>.
+type searchResult struct {
+	Event    *database.Event
+	Title    template.HTML
+	Snippet  template.HTML
+	Location template.HTML
+	Past     bool
+}
+
+type apiSearchResult struct {
+	apiEvent
+	Matches apiSearchMatches `json:"matches"`
+}
+
+type apiSearchMatches struct {
+	Title    string `json:"title"`
+	Snippet  string `json:"snippet"`
+	Location string `json:"location"`
+}
+
+type hiddenParam struct {
+	Name  string
+	Value string
+}
+
+// Search finds events of an organization by title, description and location.
+// The embed updates the results via htmx while typing.
+func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	if status, err := h.ensureScraped(orgID); err != nil {
+		http.Error(w, ensureScrapedMessage(err), status)
+		return
+	}
+
+	snap, err := h.snapshot(orgID)
+	if err != nil {
+		log.Printf("Failed to load organization %d: %v", orgID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	locale := h.locale(w, r)
+	if _, done := h.notModified(w, r, "search", snap.Version); done {
+		return
+	}
+
+	results, err := h.searchResults(r, orgID, snap)
+	if err != nil {
+		log.Printf("Failed to search events of organization %d: %v", orgID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// The form carries the kept parameters except the query itself.
+	var hidden []hiddenParam
+	for name, value := range keepParams(r) {
+		if name != "q" {
+			hidden = append(hidden, hiddenParam{Name: name, Value: value})
+		}
+	}
+	sort.Slice(hidden, func(i, j int) bool {
+		return hidden[i].Name < hidden[j].Name
+	})
+
+	data := struct {
+		OrganizationID    int
+		OrganizationTitle string
+		Query             string
+		Results           []searchResult
+		Hidden            []hiddenParam
+		Theme             pageTheme
+		Version           string
+	}{
+		OrganizationID:    orgID,
+		OrganizationTitle: getOrganizationTitle(snap.Organization),
+		Query:             strings.TrimSpace(r.URL.Query().Get("q")),
+		Results:           results,
+		Hidden:            hidden,
+		Theme:             h.theme(r, snap),
+		Version:           h.version,
+	}
+
+	name := "search.html"
+	if r.Header.Get("HX-Request") == "true" {
+		name = "search-results"
+	}
+
+	if err := h.templatesFor(locale).ExecuteTemplate(w, name, data); err != nil {
+		log.Printf("Failed to render search: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) APISearch(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid organization ID")
+		return
+	}
+
+	if strings.TrimSpace(r.URL.Query().Get("q")) == "" {
+		writeJSONError(w, http.StatusBadRequest, "Missing q parameter")
+		return
+	}
+
+	limit := apiDefaultLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > apiMaxLimit {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", apiMaxLimit))
+			return
+		}
+	}
+
+	if status, err := h.ensureScraped(orgID); err != nil {
+		writeJSONError(w, status, ensureScrapedMessage(err))
+		return
+	}
+
+	snap, err := h.snapshot(orgID)
+	if err != nil {
+		log.Printf("Failed to load organization %d: %v", orgID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	if _, done := h.notModified(w, r, "api", snap.Version); done {
+		return
+	}
+
+	results, err := h.searchResults(r, orgID, snap)
+	if err != nil {
+		log.Printf("Failed to search events of organization %d: %v", orgID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	data := make([]apiSearchResult, 0, len(results))
+	for _, result := range results {
+		data = append(data, apiSearchResult{
+			apiEvent: newAPIEvent(result.Event),
+			Matches: apiSearchMatches{
+				Title:    string(result.Title),
+				Snippet:  string(result.Snippet),
+				Location: string(result.Location),
+			},
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": data})
+}
+
+// searchResults runs the search of a request against the public events of
+// a snapshot, so hidden events never show up. Upcoming events come first,
+// followed by past events from the most recent.
+func (h *Handler) searchResults(r *http.Request, orgID int, snap *orgSnapshot) ([]searchResult, error) {
+	hits, err := h.db.SearchEvents(orgID, r.URL.Query().Get("q"), snap.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(map[int]*database.Event, len(snap.Events))
+	for _, event := range snap.Events {
+		events[event.ID] = event
+	}
+
+	// The query is matched by the search, only activity and campaign apply.
+	filter := parseEventFilter(r)
+	filter.Query = ""
+
+	today := startOfDay(time.Now())
+	var results []searchResult
+	for _, hit := range hits {
+		event := events[hit.EventID]
+		if !filter.IsEmpty() && !filter.matches(event) {
+			continue
+		}
+		results = append(results, searchResult{
+			Event:    event,
+			Title:    highlightHTML(hit.Title),
+			Snippet:  highlightHTML(hit.Snippet),
+			Location: highlightHTML(hit.Location),
+			Past:     event.DatetimeStart.Before(today),
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Past != b.Past {
+			return b.Past
+		}
+		if a.Past {
+			return a.Event.DatetimeStart.After(b.Event.DatetimeStart)
+		}
+		return a.Event.DatetimeStart.Before(b.Event.DatetimeStart)
+	})
+
+	return results, nil
+}
+
+// highlightHTML escapes a search hit text and turns its highlight markers
+// into <mark> elements.
+func highlightHTML(text string) template.HTML {
+	text = template.HTMLEscapeString(text)
+	text = strings.ReplaceAll(text, database.HighlightStart, "<mark>")
+	text = strings.ReplaceAll(text, database.HighlightEnd, "</mark>")
+	return template.HTML(text)
+}
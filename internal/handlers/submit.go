@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/romanzipp/linke-calendar/internal/database"
+	"github.com/romanzipp/linke-calendar/internal/i18n"
+)
+
+type submitForm struct {
+	Event eventPayload
+	Name  string
+	Email string
+}
+
+func (h *Handler) Submit(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	org, err := h.db.GetOrganization(orgID)
+	if err != nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+
+	h.renderSubmit(w, r, h.locale(w, r), org, submitForm{}, "")
+}
+
+func (h *Handler) SubmitPost(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(chi.URLParam(r, "org"))
+	if err != nil {
+		http.Error(w, "Invalid organization ID", http.StatusBadRequest)
+		return
+	}
+
+	org, err := h.db.GetOrganization(orgID)
+	if err != nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+
+	locale := h.locale(w, r)
+
+	query := submitQuery(r)
+	query.Set("sent", "1")
+	redirect := fmt.Sprintf("/org/%d/submit?%s", orgID, query.Encode())
+
+	// Bots fill every field, people never see this one.
+	if r.FormValue("website") != "" {
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+		return
+	}
+
+	form := submitForm{
+		Event: eventPayloadFromForm(r),
+		Name:  strings.TrimSpace(r.FormValue("name")),
+		Email: strings.TrimSpace(r.FormValue("email")),
+	}
+
+	if !h.submissions.Allow(clientIP(r, h.proxies)) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		h.renderSubmit(w, r, locale, org, form, locale.T("submit.error.rate_limit"))
+		return
+	}
+
+	if form.Name == "" {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		h.renderSubmit(w, r, locale, org, form, locale.T("submit.error.name"))
+		return
+	}
+
+	event := &database.Event{OrganizationID: orgID}
+	if err := form.Event.apply(event); err != nil {
+		var invalid payloadError
+		if !errors.As(err, &invalid) {
+			log.Printf("Failed to prepare submission for organization %d: %v", orgID, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		h.renderSubmit(w, r, locale, org, form, locale.T(invalid.Key()))
+		return
+	}
+
+	submission := &database.Submission{
+		OrganizationID: orgID,
+		Title:          event.Title,
+		Description:    event.Description,
+		DatetimeStart:  event.DatetimeStart,
+		DatetimeEnd:    event.DatetimeEnd,
+		AllDay:         event.AllDay,
+		Location:       event.Location,
+		SubmitterName:  form.Name,
+		SubmitterEmail: sql.NullString{String: form.Email, Valid: form.Email != ""},
+	}
+	if event.HasLink() {
+		submission.URL = sql.NullString{String: event.URL, Valid: true}
+	}
+
+	if err := h.db.CreateSubmission(submission); err != nil {
+		log.Printf("Failed to create submission for organization %d: %v", orgID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+// renderSubmit shows the form in the language picked by h.locale, which has
+// to be called before the status is written.
+func (h *Handler) renderSubmit(w http.ResponseWriter, r *http.Request, locale *i18n.Locale, org *database.Organization, form submitForm, formError string) {
+	action := fmt.Sprintf("/org/%d/submit", org.ID)
+	if query := submitQuery(r); len(query) > 0 {
+		action += "?" + query.Encode()
+	}
+
+	data := struct {
+		OrganizationID    int
+		OrganizationTitle string
+		Form              submitForm
+		Error             string
+		Sent              bool
+		Color             string
+		Action            string
+		Version           string
+	}{
+		OrganizationID:    org.ID,
+		OrganizationTitle: getOrganizationTitle(org),
+		Form:              form,
+		Error:             formError,
+		Sent:              r.URL.Query().Get("sent") != "",
+		Color:             submitColor(r),
+		Action:            action,
+		Version:           h.version,
+	}
+
+	if err := h.templatesFor(locale).ExecuteTemplate(w, "submit.html", data); err != nil {
+		log.Printf("Failed to render submit form: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// submitQuery keeps the color and language of the embedded form across
+// posts and the redirect after sending.
+func submitQuery(r *http.Request) url.Values {
+	query := url.Values{}
+	if color := submitColor(r); color != "" {
+		query.Set("color", color)
+	}
+	if lang := langParam(r); lang != "" {
+		query.Set("lang", lang)
+	}
+	return query
+}
+
+// submitColor returns the text color of the embedded form, only white and
+// black are supported.
+func submitColor(r *http.Request) string {
+	switch color := r.URL.Query().Get("color"); color {
+	case "white", "black":
+		return color
+	}
+	return ""
+}
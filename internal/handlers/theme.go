@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/romanzipp/linke-calendar/internal/config"
+	"github.com/romanzipp/linke-calendar/internal/database"
+)
+
+// themeParams are the query parameters overriding the configured theme.
+var themeParams = []string{"primary", "text", "font", "density", "mode"}
+
+// pageTheme is the theme of a rendered page.
+type pageTheme struct {
+	config.Theme
+}
+
+// theme combines the theme of an organization with overrides from the
+// query. Invalid values are ignored.
+func (h *Handler) theme(r *http.Request, snap *orgSnapshot) pageTheme {
+	theme := snap.Theme
+	query := r.URL.Query()
+
+	if v := themeColorParam(query.Get("primary")); v != "" {
+		theme.Primary = v
+	}
+	if v := themeColorParam(query.Get("text")); v != "" {
+		theme.Text = v
+	}
+	if v := query.Get("font"); config.ValidThemeOption(v, config.ThemeFonts) {
+		theme.Font = v
+	}
+	if v := query.Get("density"); config.ValidThemeOption(v, config.ThemeDensities) {
+		theme.Density = v
+	}
+	if v := query.Get("mode"); config.ValidThemeOption(v, config.ThemeModes) {
+		theme.Mode = v
+	}
+
+	return pageTheme{Theme: theme}
+}
+
+// mergeTheme applies the non-empty values of the theme set in the admin
+// area to the configured theme.
+func mergeTheme(theme config.Theme, saved *database.OrganizationTheme) config.Theme {
+	if saved.Primary != "" {
+		theme.Primary = saved.Primary
+	}
+	if saved.Text != "" {
+		theme.Text = saved.Text
+	}
+	if saved.Font != "" {
+		theme.Font = saved.Font
+	}
+	if saved.Density != "" {
+		theme.Density = saved.Density
+	}
+	if saved.Mode != "" {
+		theme.Mode = saved.Mode
+	}
+	return theme
+}
+
+// themeColorParam accepts colors with or without "#", since it needs
+// escaping in URLs.
+func themeColorParam(value string) string {
+	if value != "" && !strings.HasPrefix(value, "#") {
+		value = "#" + value
+	}
+	if !config.ValidThemeColor(value) {
+		return ""
+	}
+	return value
+}
+
+// Classes returns the body classes of the theme.
+func (t pageTheme) Classes() string {
+	var classes []string
+	if t.Text != "" {
+		classes = append(classes, "themed-text")
+	}
+	if t.Font == "work-sans" {
+		classes = append(classes, "font-work-sans")
+	}
+	if t.Density == "compact" {
+		classes = append(classes, "density-compact")
+	}
+	if t.Mode == "dark" {
+		classes = append(classes, "theme-dark")
+	}
+	return strings.Join(classes, " ")
+}
+
+// Style sets the theme colors as CSS variables. Colors are validated, so
+// the result is safe to use unescaped.
+func (t pageTheme) Style() template.CSS {
+	var style []string
+	if t.Primary != "" {
+		style = append(style, "--theme-primary: "+t.Primary)
+	}
+	if t.Text != "" {
+		style = append(style, "--theme-text: "+t.Text)
+	}
+	return template.CSS(strings.Join(style, "; "))
+}
+
+// keepParams returns the valid language, theme and filter parameters of a
+// request, which links between pages carry along.
+func keepParams(r *http.Request) map[string]string {
+	query := r.URL.Query()
+	keep := make(map[string]string)
+
+	if lang := langParam(r); lang != "" {
+		keep["lang"] = lang
+	}
+	for _, name := range themeParams {
+		v := query.Get(name)
+		switch name {
+		case "primary", "text":
+			v = themeColorParam(v)
+		case "font":
+			v = validOption(v, config.ThemeFonts)
+		case "density":
+			v = validOption(v, config.ThemeDensities)
+		case "mode":
+			v = validOption(v, config.ThemeModes)
+		}
+		if v != "" {
+			keep[name] = v
+		}
+	}
+
+	filter := parseEventFilter(r)
+	if len(filter.Activities) > 0 {
+		keep["activity"] = strings.Join(filter.Activities, ",")
+	}
+	if len(filter.Campaigns) > 0 {
+		keep["campaign"] = strings.Join(filter.Campaigns, ",")
+	}
+	if filter.Query != "" {
+		keep["q"] = filter.Query
+	}
+	return keep
+}
+
+// keepQuery encodes kept parameters to append them to a URL with a query.
+func keepQuery(keep map[string]string) string {
+	query := url.Values{}
+	for name, value := range keep {
+		query.Set(name, value)
+	}
+	if len(query) == 0 {
+		return ""
+	}
+	return "&" + query.Encode()
+}
+
+func validOption(value string, options []string) string {
+	if !config.ValidThemeOption(value, options) {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,260 @@
+// Package i18n holds the message catalogs and date formats of the public
+// pages.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultLanguage = "de"
+
+//go:embed locales/*.json
+var files embed.FS
+
+type Locale struct {
+	Lang string
+	Name string
+	RTL  bool
+
+	dateFormat     string
+	dayMonthFormat string
+	timeFormat     string
+	months         []string
+	weekdays       []string
+	weekdaysShort  []string
+	messages       map[string]string
+	fallback       *Locale
+}
+
+type catalog struct {
+	Name           string            `json:"name"`
+	RTL            bool              `json:"rtl"`
+	DateFormat     string            `json:"date_format"`
+	DayMonthFormat string            `json:"day_month_format"`
+	TimeFormat     string            `json:"time_format"`
+	Months         []string          `json:"months"`
+	Weekdays       []string          `json:"weekdays"`
+	WeekdaysShort  []string          `json:"weekdays_short"`
+	Messages       map[string]string `json:"messages"`
+}
+
+var locales = mustLoad()
+
+func mustLoad() map[string]*Locale {
+	entries, err := files.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+
+	result := make(map[string]*Locale)
+	for _, entry := range entries {
+		data, err := files.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+
+		var c catalog
+		if err := json.Unmarshal(data, &c); err != nil {
+			panic(fmt.Sprintf("invalid catalog %s: %v", entry.Name(), err))
+		}
+		if len(c.Months) != 12 || len(c.Weekdays) != 7 || len(c.WeekdaysShort) != 7 {
+			panic(fmt.Sprintf("invalid catalog %s: incomplete month or weekday names", entry.Name()))
+		}
+
+		lang := strings.TrimSuffix(entry.Name(), ".json")
+		result[lang] = &Locale{
+			Lang:           lang,
+			Name:           c.Name,
+			RTL:            c.RTL,
+			dateFormat:     c.DateFormat,
+			dayMonthFormat: c.DayMonthFormat,
+			timeFormat:     c.TimeFormat,
+			months:         c.Months,
+			weekdays:       c.Weekdays,
+			weekdaysShort:  c.WeekdaysShort,
+			messages:       c.Messages,
+		}
+	}
+
+	fallback, ok := result[DefaultLanguage]
+	if !ok {
+		panic("missing catalog of the default language")
+	}
+	for lang, locale := range result {
+		if lang != DefaultLanguage {
+			locale.fallback = fallback
+		}
+	}
+
+	return result
+}
+
+// Get returns the locale of a language, the default locale if there is no
+// catalog for it.
+func Get(lang string) *Locale {
+	if locale, ok := locales[strings.ToLower(lang)]; ok {
+		return locale
+	}
+	return locales[DefaultLanguage]
+}
+
+// Supported reports whether there is a catalog for lang.
+func Supported(lang string) bool {
+	_, ok := locales[strings.ToLower(lang)]
+	return ok
+}
+
+// Languages returns all locales ordered by language code.
+func Languages() []*Locale {
+	result := make([]*Locale, 0, len(locales))
+	for _, locale := range locales {
+		result = append(result, locale)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Lang < result[j].Lang
+	})
+	return result
+}
+
+// Negotiate picks the locale from an explicit language parameter or else
+// the preferred supported language of an Accept-Language header.
+func Negotiate(param, acceptLanguage string) *Locale {
+	if Supported(param) {
+		return Get(param)
+	}
+
+	best, bestQuality := "", 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if i := strings.IndexAny(tag, "-_"); i >= 0 {
+			tag = tag[:i]
+		}
+
+		quality := 1.0
+		for _, field := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(field), "q="); ok {
+				if q, err := strconv.ParseFloat(v, 64); err == nil {
+					quality = q
+				}
+			}
+		}
+
+		if quality > bestQuality && Supported(tag) {
+			best, bestQuality = tag, quality
+		}
+	}
+
+	return Get(best)
+}
+
+func (l *Locale) Dir() string {
+	if l.RTL {
+		return "rtl"
+	}
+	return "ltr"
+}
+
+// T returns the message for key with its {placeholders} replaced by the
+// given name/value pairs. Missing messages fall back to the default
+// language.
+func (l *Locale) T(key string, args ...string) string {
+	message, ok := l.messages[key]
+	if !ok && l.fallback != nil {
+		message, ok = l.fallback.messages[key]
+	}
+	if !ok {
+		return key
+	}
+
+	for i := 0; i+1 < len(args); i += 2 {
+		message = strings.ReplaceAll(message, "{"+args[i]+"}", args[i+1])
+	}
+	return message
+}
+
+// N returns the singular ("key.one") or plural ("key.other") message for
+// count, with {count} replaced.
+func (l *Locale) N(key string, count int) string {
+	form := key + ".other"
+	if count == 1 {
+		form = key + ".one"
+	}
+	return l.T(form, "count", strconv.Itoa(count))
+}
+
+func (l *Locale) Month(month time.Month) string {
+	return l.months[month-1]
+}
+
+func (l *Locale) Weekday(weekday time.Weekday) string {
+	return l.weekdays[weekday]
+}
+
+func (l *Locale) WeekdayShort(weekday time.Weekday) string {
+	return l.weekdaysShort[weekday]
+}
+
+// WeekdaysShort returns the abbreviated weekdays starting on Monday.
+func (l *Locale) WeekdaysShort() []string {
+	return append(append([]string{}, l.weekdaysShort[1:]...), l.weekdaysShort[0])
+}
+
+func (l *Locale) Date(t time.Time) string {
+	return t.Format(l.dateFormat)
+}
+
+func (l *Locale) DayMonth(t time.Time) string {
+	return t.Format(l.dayMonthFormat)
+}
+
+// Clock formats the time of day without suffix, for compact views.
+func (l *Locale) Clock(t time.Time) string {
+	return t.Format(l.timeFormat)
+}
+
+func (l *Locale) Time(t time.Time) string {
+	return l.T("time.clock", "time", l.Clock(t))
+}
+
+// LongDate formats a date with the month name, like "19. Oktober 2026".
+func (l *Locale) LongDate(t time.Time) string {
+	return l.T("format.long_date", l.dateArgs(t)...)
+}
+
+// WeekdayDate formats a date with weekday and month name.
+func (l *Locale) WeekdayDate(t time.Time) string {
+	return l.T("format.weekday_date", l.dateArgs(t)...)
+}
+
+func (l *Locale) MonthYear(month time.Month, year int) string {
+	return l.T("format.month_year", "month", l.Month(month), "year", strconv.Itoa(year))
+}
+
+// Range formats a period of days, like "19. – 25. Oktober 2026".
+func (l *Locale) Range(start, end time.Time) string {
+	if start.Year() == end.Year() && start.Month() == end.Month() {
+		return l.T("format.range_same_month",
+			"start_day", strconv.Itoa(start.Day()),
+			"end_day", strconv.Itoa(end.Day()),
+			"month", l.Month(start.Month()),
+			"year", strconv.Itoa(start.Year()))
+	}
+	return l.T("format.range", "start", l.LongDate(start), "end", l.LongDate(end))
+}
+
+func (l *Locale) dateArgs(t time.Time) []string {
+	return []string{
+		"weekday", l.Weekday(t.Weekday()),
+		"day", strconv.Itoa(t.Day()),
+		"month", l.Month(t.Month()),
+		"year", strconv.Itoa(t.Year()),
+	}
+}
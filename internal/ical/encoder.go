@@ -0,0 +1,76 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLineOctets is the line length limit of RFC 5545, section 3.1, without
+// the line break.
+const maxLineOctets = 75
+
+type encoder struct {
+	buf *bytes.Buffer
+}
+
+// line writes a content line, folding it into continuation lines of at most
+// 75 octets without splitting UTF-8 sequences.
+func (e *encoder) line(name, value string) {
+	content := name + ":" + value
+
+	limit := maxLineOctets
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		e.buf.WriteString(content[:cut])
+		e.buf.WriteString("\r\n ")
+		content = content[cut:]
+		// The leading space of continuation lines counts towards the limit.
+		limit = maxLineOctets - 1
+	}
+	e.buf.WriteString(content)
+	e.buf.WriteString("\r\n")
+}
+
+// dateTime writes a local time with a TZID reference, or as UTC if the
+// calendar has no time zone definition.
+func (e *encoder) dateTime(name string, t time.Time, tz *timezone) {
+	if tz == nil {
+		e.line(name, formatUTC(t))
+		return
+	}
+	e.line(name+";TZID="+tz.ID, t.Format("20060102T150405"))
+}
+
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`;`, `\;`,
+	`,`, `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+// escapeText escapes a TEXT value as described in RFC 5545, section 3.3.11.
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// paramValue quotes a parameter value if needed. Double quotes are not
+// allowed in parameter values and are dropped.
+func paramValue(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '"' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, s)
+	if strings.ContainsAny(s, ":;,") {
+		return `"` + s + `"`
+	}
+	return s
+}
@@ -0,0 +1,233 @@
+// Package ical renders calendars in the iCalendar format of RFC 5545.
+package ical
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const productID = "-//linke-calendar//linke-calendar//DE"
+
+const (
+	StatusConfirmed = "CONFIRMED"
+	StatusTentative = "TENTATIVE"
+	StatusCancelled = "CANCELLED"
+)
+
+type Calendar struct {
+	Name        string
+	Description string
+	// Location is the time zone of the event times. Zones without a known
+	// VTIMEZONE definition are written as UTC.
+	Location *time.Location
+	// RefreshInterval is the suggested polling interval for subscribers.
+	RefreshInterval time.Duration
+	Events          []*Event
+}
+
+type Event struct {
+	UID         string
+	Created     time.Time
+	Modified    time.Time
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+	Summary     string
+	Description string
+	Location    string
+	URL         string
+	Categories  []string
+	Status      string
+	Sequence    int
+	Geo         *Geo
+	Organizer   *Organizer
+	Alarms      []Alarm
+}
+
+type Geo struct {
+	Latitude  float64
+	Longitude float64
+}
+
+type Organizer struct {
+	Name string
+	URI  string
+}
+
+// Alarm is a display reminder the given time before the event starts.
+type Alarm struct {
+	Before time.Duration
+}
+
+// Encode writes the calendar with CRLF line endings and folded lines.
+func (c *Calendar) Encode(w io.Writer) error {
+	var buf bytes.Buffer
+	e := &encoder{buf: &buf}
+
+	tz := timezones[c.timezoneID()]
+
+	e.line("BEGIN", "VCALENDAR")
+	e.line("VERSION", "2.0")
+	e.line("PRODID", productID)
+	e.line("CALSCALE", "GREGORIAN")
+	e.line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		e.line("X-WR-CALNAME", escapeText(c.Name))
+	}
+	if c.Description != "" {
+		e.line("X-WR-CALDESC", escapeText(c.Description))
+	}
+	if tz != nil {
+		e.line("X-WR-TIMEZONE", tz.ID)
+	}
+	if c.RefreshInterval > 0 {
+		e.line("REFRESH-INTERVAL;VALUE=DURATION", formatDuration(c.RefreshInterval))
+		e.line("X-PUBLISHED-TTL", formatDuration(c.RefreshInterval))
+	}
+	if tz != nil {
+		tz.encode(e)
+	}
+
+	for _, event := range c.Events {
+		c.encodeEvent(e, event, tz)
+	}
+
+	e.line("END", "VCALENDAR")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (c *Calendar) timezoneID() string {
+	if c.Location == nil {
+		return ""
+	}
+	return c.Location.String()
+}
+
+func (c *Calendar) encodeEvent(e *encoder, event *Event, tz *timezone) {
+	e.line("BEGIN", "VEVENT")
+	e.line("UID", escapeText(event.UID))
+
+	stamp := event.Modified
+	if stamp.IsZero() {
+		stamp = event.Created
+	}
+	e.line("DTSTAMP", formatUTC(stamp))
+	if !event.Created.IsZero() {
+		e.line("CREATED", formatUTC(event.Created))
+	}
+	if !event.Modified.IsZero() {
+		e.line("LAST-MODIFIED", formatUTC(event.Modified))
+	}
+
+	if event.AllDay {
+		e.line("DTSTART;VALUE=DATE", event.Start.Format("20060102"))
+		if !event.End.IsZero() {
+			e.line("DTEND;VALUE=DATE", event.End.Format("20060102"))
+		}
+	} else {
+		e.dateTime("DTSTART", event.Start, tz)
+		if !event.End.IsZero() {
+			e.dateTime("DTEND", event.End, tz)
+		}
+	}
+
+	e.line("SUMMARY", escapeText(event.Summary))
+	if event.Description != "" {
+		e.line("DESCRIPTION", escapeText(event.Description))
+	}
+	if event.Location != "" {
+		e.line("LOCATION", escapeText(event.Location))
+	}
+	if event.Geo != nil {
+		e.line("GEO", fmt.Sprintf("%.6f;%.6f", event.Geo.Latitude, event.Geo.Longitude))
+	}
+	if len(event.Categories) > 0 {
+		categories := make([]string, 0, len(event.Categories))
+		for _, category := range event.Categories {
+			categories = append(categories, escapeText(category))
+		}
+		e.line("CATEGORIES", strings.Join(categories, ","))
+	}
+	if event.URL != "" {
+		e.line("URL", event.URL)
+	}
+	if event.Organizer != nil && event.Organizer.URI != "" {
+		name := "ORGANIZER"
+		if event.Organizer.Name != "" {
+			name += ";CN=" + paramValue(event.Organizer.Name)
+		}
+		e.line(name, event.Organizer.URI)
+	}
+	if event.Status != "" {
+		e.line("STATUS", event.Status)
+	}
+	e.line("SEQUENCE", fmt.Sprintf("%d", event.Sequence))
+	e.line("TRANSP", "OPAQUE")
+	for _, alarm := range event.Alarms {
+		e.line("BEGIN", "VALARM")
+		e.line("ACTION", "DISPLAY")
+		e.line("DESCRIPTION", escapeText(event.Summary))
+		e.line("TRIGGER", "-"+formatDuration(alarm.Before))
+		e.line("END", "VALARM")
+	}
+	e.line("END", "VEVENT")
+}
+
+func formatUTC(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// formatDuration renders a duration as an RFC 5545 dur-value, rounded down
+// to whole minutes.
+func formatDuration(d time.Duration) string {
+	minutes := int(d / time.Minute)
+	if minutes%(24*60) == 0 {
+		return fmt.Sprintf("P%dD", minutes/(24*60))
+	}
+	if minutes%60 == 0 {
+		return fmt.Sprintf("PT%dH", minutes/60)
+	}
+	return fmt.Sprintf("PT%dM", minutes)
+}
+
+// maxTrigger is the longest reminder offset accepted by ParseTrigger.
+const maxTrigger = 4 * 7 * 24 * time.Hour
+
+// ParseTrigger parses a reminder offset like "30m", "2h", "1d" or "1w".
+func ParseTrigger(value string) (time.Duration, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if len(value) < 2 {
+		return 0, fmt.Errorf("%q is not a reminder offset", value)
+	}
+
+	n, err := strconv.Atoi(value[:len(value)-1])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a reminder offset", value)
+	}
+
+	var unit time.Duration
+	switch value[len(value)-1] {
+	case 'm':
+		unit = time.Minute
+	case 'h':
+		unit = time.Hour
+	case 'd':
+		unit = 24 * time.Hour
+	case 'w':
+		unit = 7 * 24 * time.Hour
+	default:
+		return 0, fmt.Errorf("%q is not a reminder offset", value)
+	}
+
+	// Compare before multiplying, large numbers would overflow.
+	if n > int(maxTrigger/unit) {
+		return 0, fmt.Errorf("%q is more than 4 weeks", value)
+	}
+	return time.Duration(n) * unit, nil
+}
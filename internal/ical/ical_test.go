@@ -0,0 +1,187 @@
+package ical
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func goldenCalendars(t *testing.T) map[string]*Calendar {
+	t.Helper()
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+
+	created := time.Date(2025, 1, 10, 9, 30, 0, 0, time.UTC)
+	modified := time.Date(2025, 2, 1, 12, 0, 0, 0, time.UTC)
+
+	return map[string]*Calendar{
+		"timezone": {
+			Name:            "Die Linke Fulda",
+			Description:     "Termine von Die Linke Fulda",
+			Location:        berlin,
+			RefreshInterval: time.Hour,
+			Events: []*Event{{
+				UID:         "zetkin-4711@app.zetkin.die-linke.de",
+				Created:     created,
+				Modified:    modified,
+				Start:       time.Date(2025, 3, 30, 18, 0, 0, 0, berlin),
+				End:         time.Date(2025, 3, 30, 20, 30, 0, 0, berlin),
+				Summary:     "Mitgliederversammlung; Wahl des Vorstands, Teil 2",
+				Description: "Tagesordnung:\n1. Begrüßung\n2. Wahlen",
+				Location:    "Bürgerhaus, Hauptstraße 1, 36037 Fulda",
+				URL:         "https://app.zetkin.die-linke.de/o/192/events/4711",
+				Categories:  []string{"Versammlung", "Wahl, Vorstand"},
+				Status:      StatusConfirmed,
+				Sequence:    3,
+				Geo:         &Geo{Latitude: 50.555809, Longitude: 9.680845},
+				Organizer:   &Organizer{Name: "Die Linke: Fulda", URI: "https://app.zetkin.die-linke.de/o/192"},
+				Alarms:      []Alarm{{Before: 24 * time.Hour}, {Before: 90 * time.Minute}},
+			}},
+		},
+		"all_day": {
+			Name:     "Die Linke Fulda",
+			Location: berlin,
+			Events: []*Event{
+				{
+					UID:      "mobilizon-1@mobilizon.example.org",
+					Created:  created,
+					Start:    time.Date(2025, 5, 1, 0, 0, 0, 0, berlin),
+					End:      time.Date(2025, 5, 2, 0, 0, 0, 0, berlin),
+					AllDay:   true,
+					Summary:  "Tag der Arbeit",
+					Status:   StatusConfirmed,
+					Sequence: 0,
+				},
+				{
+					UID:      "mobilizon-2@mobilizon.example.org",
+					Created:  created,
+					Modified: modified,
+					Start:    time.Date(2025, 6, 20, 0, 0, 0, 0, berlin),
+					End:      time.Date(2025, 6, 23, 0, 0, 0, 0, berlin),
+					AllDay:   true,
+					Summary:  "Sommercamp",
+					Status:   StatusCancelled,
+					Sequence: 2,
+				},
+			},
+		},
+		"folding": {
+			Events: []*Event{{
+				UID:         "1-7@linke-calendar",
+				Created:     created,
+				Start:       time.Date(2025, 4, 2, 17, 0, 0, 0, time.UTC),
+				End:         time.Date(2025, 4, 2, 19, 0, 0, 0, time.UTC),
+				Summary:     "Lesekreis: „Das Kapital“ – Band 1, Kapitel 1 bis 4, mit anschließender Diskussion",
+				Description: strings.Repeat("Überlänge äöü ß € 😀 ", 12),
+				URL:         "https://example.org/veranstaltungen/2025/04/lesekreis-das-kapital-band-1-kapitel-1-bis-4",
+				Status:      StatusTentative,
+				Sequence:    1,
+			}},
+		},
+	}
+}
+
+func TestEncodeGolden(t *testing.T) {
+	for name, cal := range goldenCalendars(t) {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := cal.Encode(&buf); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+
+			if err := validateRFC5545(buf.Bytes()); err != nil {
+				t.Errorf("invalid iCalendar: %v", err)
+			}
+
+			path := filepath.Join("testdata", name+".ics")
+			if *update {
+				if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("missing golden file, run with -update: %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("output differs from %s:\n%s", path, buf.String())
+			}
+		})
+	}
+}
+
+func TestGoldenFilesValid(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no golden files")
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := validateRFC5545(data); err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+}
+
+func TestValidatorRejects(t *testing.T) {
+	valid := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\n" +
+		"BEGIN:VEVENT\r\nUID:1\r\nDTSTAMP:20250101T000000Z\r\nDTSTART:20250101T100000Z\r\nSUMMARY:Test\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	if err := validateRFC5545([]byte(valid)); err != nil {
+		t.Fatalf("minimal calendar rejected: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		replace string
+		with    string
+		all     bool
+	}{
+		{"LF line endings", "\r\n", "\n", true},
+		{"long line", "SUMMARY:Test", "SUMMARY:" + strings.Repeat("x", 80), false},
+		{"missing UID", "UID:1\r\n", "", false},
+		{"missing DTSTAMP", "DTSTAMP:20250101T000000Z\r\n", "", false},
+		{"unclosed component", "END:VEVENT\r\n", "", false},
+		{"unescaped comma", "SUMMARY:Test", "SUMMARY:a,b", false},
+		{"unknown escape", "SUMMARY:Test", `SUMMARY:a\b`, false},
+		{"unknown TZID", "DTSTART:20250101T100000Z", "DTSTART;TZID=Europe/Berlin:20250101T100000", false},
+		{"DTEND before DTSTART", "SUMMARY:Test", "DTEND:20250101T090000Z\r\nSUMMARY:Test", false},
+		{"DATE end of DATE-TIME", "SUMMARY:Test", "DTEND;VALUE=DATE:20250102\r\nSUMMARY:Test", false},
+		{"invalid GEO", "SUMMARY:Test", "GEO:50.5,9.6\r\nSUMMARY:Test", false},
+		{"invalid STATUS", "SUMMARY:Test", "STATUS:DONE\r\nSUMMARY:Test", false},
+		{"negative SEQUENCE", "SUMMARY:Test", "SEQUENCE:-1\r\nSUMMARY:Test", false},
+		{"unquoted parameter", "SUMMARY:Test", "ORGANIZER;CN=a,b:https://example.org\r\nSUMMARY:Test", false},
+		{"ORGANIZER without URI", "SUMMARY:Test", "ORGANIZER:Die Linke\r\nSUMMARY:Test", false},
+		{"wrong version", "VERSION:2.0", "VERSION:1.0", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := 1
+			if tt.all {
+				n = -1
+			}
+			data := strings.Replace(valid, tt.replace, tt.with, n)
+			if err := validateRFC5545([]byte(data)); err == nil {
+				t.Errorf("accepted:\n%s", data)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package ical
+
+// timezone is a VTIMEZONE definition with yearly recurring transitions.
+type timezone struct {
+	ID          string
+	Transitions []transition
+}
+
+type transition struct {
+	// Kind is either STANDARD or DAYLIGHT.
+	Kind       string
+	Name       string
+	OffsetFrom string
+	OffsetTo   string
+	Start      string
+	Rule       string
+}
+
+// timezones holds the VTIMEZONE definitions embedded into calendars, keyed by
+// their IANA name.
+var timezones = map[string]*timezone{
+	"Europe/Berlin": {
+		ID: "Europe/Berlin",
+		Transitions: []transition{
+			{
+				Kind:       "DAYLIGHT",
+				Name:       "CEST",
+				OffsetFrom: "+0100",
+				OffsetTo:   "+0200",
+				Start:      "19700329T020000",
+				Rule:       "FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU",
+			},
+			{
+				Kind:       "STANDARD",
+				Name:       "CET",
+				OffsetFrom: "+0200",
+				OffsetTo:   "+0100",
+				Start:      "19701025T030000",
+				Rule:       "FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU",
+			},
+		},
+	},
+}
+
+func (tz *timezone) encode(e *encoder) {
+	e.line("BEGIN", "VTIMEZONE")
+	e.line("TZID", tz.ID)
+	e.line("X-LIC-LOCATION", tz.ID)
+	for _, t := range tz.Transitions {
+		e.line("BEGIN", t.Kind)
+		e.line("TZOFFSETFROM", t.OffsetFrom)
+		e.line("TZOFFSETTO", t.OffsetTo)
+		e.line("TZNAME", t.Name)
+		e.line("DTSTART", t.Start)
+		e.line("RRULE", t.Rule)
+		e.line("END", t.Kind)
+	}
+	e.line("END", "VTIMEZONE")
+}
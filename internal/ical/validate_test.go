@@ -0,0 +1,433 @@
+package ical
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// This file holds a validator for the subset of RFC 5545 the encoder
+// produces. It checks the calendars of the tests independently of the
+// encoder, so it shares no code with it.
+
+type property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+type component struct {
+	Name       string
+	Properties []property
+	Children   []*component
+}
+
+func (c *component) all(name string) []property {
+	var result []property
+	for _, p := range c.Properties {
+		if p.Name == name {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+var (
+	nameRe       = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+	dateRe       = regexp.MustCompile(`^\d{8}$`)
+	dateTimeRe   = regexp.MustCompile(`^\d{8}T\d{6}Z?$`)
+	utcOffsetRe  = regexp.MustCompile(`^[+-]\d{4}$`)
+	durationRe   = regexp.MustCompile(`^[+-]?P(\d+W|\d+D|(\d+D)?T(\d+H)?(\d+M)?(\d+S)?)$`)
+	uriRe        = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*:\S+$`)
+	textEscapeRe = regexp.MustCompile(`\\[^\\;,nN]|\\$`)
+)
+
+// validateRFC5545 checks line endings, folding, the component structure and
+// the properties of VCALENDAR, VTIMEZONE, VEVENT and VALARM.
+func validateRFC5545(data []byte) error {
+	lines, err := unfold(data)
+	if err != nil {
+		return err
+	}
+
+	var stack []*component
+	var root *component
+	for i, line := range lines {
+		p, err := parseLine(line)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		switch p.Name {
+		case "BEGIN":
+			c := &component{Name: p.Value}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, c)
+			} else if root != nil {
+				return fmt.Errorf("line %d: more than one top-level component", i+1)
+			} else {
+				root = c
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != p.Value {
+				return fmt.Errorf("line %d: END:%s does not close the open component", i+1, p.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return fmt.Errorf("line %d: property %s outside of a component", i+1, p.Name)
+			}
+			c := stack[len(stack)-1]
+			c.Properties = append(c.Properties, p)
+		}
+	}
+	if len(stack) > 0 {
+		return fmt.Errorf("%s is not closed", stack[len(stack)-1].Name)
+	}
+	if root == nil || root.Name != "VCALENDAR" {
+		return fmt.Errorf("missing VCALENDAR")
+	}
+
+	return validateCalendar(root)
+}
+
+// unfold splits the data into content lines, checking CRLF line endings and
+// the 75 octet limit of folded lines.
+func unfold(data []byte) ([]string, error) {
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("not valid UTF-8")
+	}
+
+	s := string(data)
+	if !strings.HasSuffix(s, "\r\n") {
+		return nil, fmt.Errorf("missing CRLF after the last line")
+	}
+
+	var lines []string
+	for i, raw := range strings.Split(strings.TrimSuffix(s, "\r\n"), "\r\n") {
+		if strings.ContainsAny(raw, "\r\n") {
+			return nil, fmt.Errorf("line %d: bare CR or LF", i+1)
+		}
+		if len(raw) > 75 {
+			return nil, fmt.Errorf("line %d: %d octets, at most 75 are allowed", i+1, len(raw))
+		}
+		if !utf8.ValidString(raw) {
+			return nil, fmt.Errorf("line %d: folded within a UTF-8 sequence", i+1)
+		}
+
+		if strings.HasPrefix(raw, " ") || strings.HasPrefix(raw, "\t") {
+			if len(lines) == 0 {
+				return nil, fmt.Errorf("line %d: continuation without a content line", i+1)
+			}
+			lines[len(lines)-1] += raw[1:]
+			continue
+		}
+		if raw == "" {
+			return nil, fmt.Errorf("line %d: empty line", i+1)
+		}
+		lines = append(lines, raw)
+	}
+	return lines, nil
+}
+
+// parseLine parses name *(";" param) ":" value.
+func parseLine(line string) (property, error) {
+	p := property{Params: map[string]string{}}
+
+	end := strings.IndexAny(line, ";:")
+	if end < 0 {
+		return p, fmt.Errorf("missing ':' in %q", line)
+	}
+	p.Name = strings.ToUpper(line[:end])
+	if !nameRe.MatchString(p.Name) {
+		return p, fmt.Errorf("invalid property name %q", p.Name)
+	}
+
+	rest := line[end:]
+	for strings.HasPrefix(rest, ";") {
+		rest = rest[1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			return p, fmt.Errorf("%s: parameter without value", p.Name)
+		}
+		name := strings.ToUpper(rest[:eq])
+		if !nameRe.MatchString(name) {
+			return p, fmt.Errorf("%s: invalid parameter name %q", p.Name, name)
+		}
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			closing := strings.IndexByte(rest[1:], '"')
+			if closing < 0 {
+				return p, fmt.Errorf("%s: unterminated quoted parameter", p.Name)
+			}
+			value, rest = rest[1:closing+1], rest[closing+2:]
+		} else {
+			stop := strings.IndexAny(rest, ";:")
+			if stop < 0 {
+				return p, fmt.Errorf("%s: missing ':'", p.Name)
+			}
+			value, rest = rest[:stop], rest[stop:]
+			if strings.ContainsAny(value, `",`) {
+				return p, fmt.Errorf("%s: parameter %s must be quoted", p.Name, name)
+			}
+		}
+		p.Params[name] = value
+	}
+
+	if !strings.HasPrefix(rest, ":") {
+		return p, fmt.Errorf("%s: missing ':'", p.Name)
+	}
+	p.Value = rest[1:]
+
+	for _, r := range p.Value {
+		if r < 0x20 && r != '\t' || r == 0x7f {
+			return p, fmt.Errorf("%s: control character in value", p.Name)
+		}
+	}
+	return p, nil
+}
+
+func validateCalendar(cal *component) error {
+	if err := requireOnce(cal, "VERSION", "PRODID"); err != nil {
+		return err
+	}
+	if v := cal.all("VERSION")[0].Value; v != "2.0" {
+		return fmt.Errorf("VCALENDAR: VERSION is %q, want 2.0", v)
+	}
+
+	tzids := map[string]bool{}
+	for _, child := range cal.Children {
+		if child.Name != "VTIMEZONE" {
+			continue
+		}
+		if err := validateTimezone(child); err != nil {
+			return err
+		}
+		tzids[child.all("TZID")[0].Value] = true
+	}
+
+	uids := map[string]bool{}
+	for _, child := range cal.Children {
+		switch child.Name {
+		case "VTIMEZONE":
+		case "VEVENT":
+			if err := validateEvent(child, tzids); err != nil {
+				return err
+			}
+			uid := child.all("UID")[0].Value
+			if uids[uid] {
+				return fmt.Errorf("VEVENT: duplicate UID %q", uid)
+			}
+			uids[uid] = true
+		default:
+			return fmt.Errorf("unexpected component %s in VCALENDAR", child.Name)
+		}
+	}
+	return nil
+}
+
+func validateTimezone(tz *component) error {
+	if err := requireOnce(tz, "TZID"); err != nil {
+		return err
+	}
+	if len(tz.Children) == 0 {
+		return fmt.Errorf("VTIMEZONE: needs a STANDARD or DAYLIGHT component")
+	}
+	for _, child := range tz.Children {
+		if child.Name != "STANDARD" && child.Name != "DAYLIGHT" {
+			return fmt.Errorf("VTIMEZONE: unexpected component %s", child.Name)
+		}
+		if err := requireOnce(child, "DTSTART", "TZOFFSETFROM", "TZOFFSETTO"); err != nil {
+			return err
+		}
+		if v := child.all("DTSTART")[0].Value; !dateTimeRe.MatchString(v) || strings.HasSuffix(v, "Z") {
+			return fmt.Errorf("%s: DTSTART must be a local date-time, got %q", child.Name, v)
+		}
+		for _, name := range []string{"TZOFFSETFROM", "TZOFFSETTO"} {
+			if v := child.all(name)[0].Value; !utcOffsetRe.MatchString(v) {
+				return fmt.Errorf("%s: invalid %s %q", child.Name, name, v)
+			}
+		}
+	}
+	return nil
+}
+
+func validateEvent(event *component, tzids map[string]bool) error {
+	if err := requireOnce(event, "UID", "DTSTAMP", "DTSTART"); err != nil {
+		return err
+	}
+	if err := atMostOnce(event, "DTEND", "SUMMARY", "DESCRIPTION", "LOCATION", "GEO", "URL", "ORGANIZER", "STATUS", "SEQUENCE", "CREATED", "LAST-MODIFIED"); err != nil {
+		return err
+	}
+
+	for _, name := range []string{"DTSTAMP", "CREATED", "LAST-MODIFIED"} {
+		for _, p := range event.all(name) {
+			if !dateTimeRe.MatchString(p.Value) || !strings.HasSuffix(p.Value, "Z") {
+				return fmt.Errorf("VEVENT: %s must be UTC, got %q", name, p.Value)
+			}
+		}
+	}
+
+	start := event.all("DTSTART")[0]
+	startType, err := dateValue(start, tzids)
+	if err != nil {
+		return err
+	}
+	if ends := event.all("DTEND"); len(ends) > 0 {
+		endType, err := dateValue(ends[0], tzids)
+		if err != nil {
+			return err
+		}
+		if endType != startType {
+			return fmt.Errorf("VEVENT: DTEND is a %s, DTSTART a %s", endType, startType)
+		}
+		if ends[0].Value <= start.Value {
+			return fmt.Errorf("VEVENT: DTEND %s is not after DTSTART %s", ends[0].Value, start.Value)
+		}
+	}
+
+	for _, name := range []string{"SUMMARY", "DESCRIPTION", "LOCATION"} {
+		for _, p := range event.all(name) {
+			if err := checkText(name, p.Value); err != nil {
+				return err
+			}
+		}
+	}
+	for _, p := range event.all("CATEGORIES") {
+		for _, category := range splitUnescaped(p.Value, ',') {
+			if category == "" {
+				return fmt.Errorf("VEVENT: empty category in %q", p.Value)
+			}
+			if err := checkText("CATEGORIES", category); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, p := range event.all("GEO") {
+		parts := strings.Split(p.Value, ";")
+		if len(parts) != 2 {
+			return fmt.Errorf("VEVENT: GEO needs latitude;longitude, got %q", p.Value)
+		}
+		for i, limit := range []float64{90, 180} {
+			f, err := strconv.ParseFloat(parts[i], 64)
+			if err != nil || f < -limit || f > limit {
+				return fmt.Errorf("VEVENT: invalid GEO %q", p.Value)
+			}
+		}
+	}
+
+	for _, p := range event.all("STATUS") {
+		switch p.Value {
+		case "TENTATIVE", "CONFIRMED", "CANCELLED":
+		default:
+			return fmt.Errorf("VEVENT: invalid STATUS %q", p.Value)
+		}
+	}
+	for _, p := range event.all("SEQUENCE") {
+		if n, err := strconv.Atoi(p.Value); err != nil || n < 0 {
+			return fmt.Errorf("VEVENT: invalid SEQUENCE %q", p.Value)
+		}
+	}
+	for _, name := range []string{"ORGANIZER", "URL"} {
+		for _, p := range event.all(name) {
+			if !uriRe.MatchString(p.Value) {
+				return fmt.Errorf("VEVENT: %s is not a URI: %q", name, p.Value)
+			}
+		}
+	}
+
+	for _, child := range event.Children {
+		if child.Name != "VALARM" {
+			return fmt.Errorf("VEVENT: unexpected component %s", child.Name)
+		}
+		if err := requireOnce(child, "ACTION", "TRIGGER"); err != nil {
+			return err
+		}
+		if child.all("ACTION")[0].Value == "DISPLAY" {
+			if err := requireOnce(child, "DESCRIPTION"); err != nil {
+				return err
+			}
+		}
+		if v := child.all("TRIGGER")[0].Value; !durationRe.MatchString(v) {
+			return fmt.Errorf("VALARM: invalid TRIGGER %q", v)
+		}
+	}
+	return nil
+}
+
+// dateValue checks a DATE or DATE-TIME property and returns its type.
+func dateValue(p property, tzids map[string]bool) (string, error) {
+	if p.Params["VALUE"] == "DATE" {
+		if !dateRe.MatchString(p.Value) {
+			return "", fmt.Errorf("%s: invalid DATE %q", p.Name, p.Value)
+		}
+		if _, ok := p.Params["TZID"]; ok {
+			return "", fmt.Errorf("%s: DATE values have no TZID", p.Name)
+		}
+		return "DATE", nil
+	}
+
+	if !dateTimeRe.MatchString(p.Value) {
+		return "", fmt.Errorf("%s: invalid DATE-TIME %q", p.Name, p.Value)
+	}
+	if tzid, ok := p.Params["TZID"]; ok {
+		if strings.HasSuffix(p.Value, "Z") {
+			return "", fmt.Errorf("%s: UTC time with TZID", p.Name)
+		}
+		if !tzids[tzid] {
+			return "", fmt.Errorf("%s: TZID %q has no VTIMEZONE", p.Name, tzid)
+		}
+	}
+	return "DATE-TIME", nil
+}
+
+// checkText rejects unescaped separators and unknown escapes in a TEXT value.
+func checkText(name, value string) error {
+	if textEscapeRe.MatchString(value) {
+		return fmt.Errorf("%s: invalid escape in %q", name, value)
+	}
+	if len(splitUnescaped(value, ';')) > 1 || len(splitUnescaped(value, ',')) > 1 {
+		return fmt.Errorf("%s: unescaped separator in %q", name, value)
+	}
+	return nil
+}
+
+func splitUnescaped(value string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+func requireOnce(c *component, names ...string) error {
+	for _, name := range names {
+		if n := len(c.all(name)); n != 1 {
+			return fmt.Errorf("%s: %s occurs %d times, want 1", c.Name, name, n)
+		}
+	}
+	return nil
+}
+
+func atMostOnce(c *component, names ...string) error {
+	for _, name := range names {
+		if n := len(c.all(name)); n > 1 {
+			return fmt.Errorf("%s: %s occurs %d times, want at most 1", c.Name, name, n)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package rules
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/romanzipp/linke-calendar/internal/database"
+)
+
+// Errors of Validate.
+var (
+	ErrUnknownField   = errors.New("unknown field")
+	ErrUnknownAction  = errors.New("unknown action")
+	ErrMissingPattern = errors.New("hide rules need a pattern")
+	ErrInvalidRegex   = errors.New("invalid regular expression")
+)
+
+var contactPattern = regexp.MustCompile(`(?m)^Kontakt: .*$`)
+
+var compiled sync.Map
+
+func Validate(rule *database.Rule) error {
+	switch rule.Field {
+	case database.RuleFieldTitle, database.RuleFieldActivity, database.RuleFieldLocation, database.RuleFieldAny:
+	default:
+		return fmt.Errorf("%w %q", ErrUnknownField, rule.Field)
+	}
+
+	switch rule.Action {
+	case database.RuleActionHide, database.RuleActionStripContact:
+	default:
+		return fmt.Errorf("%w %q", ErrUnknownAction, rule.Action)
+	}
+
+	if rule.Action == database.RuleActionHide && strings.TrimSpace(rule.Pattern) == "" {
+		return ErrMissingPattern
+	}
+
+	if rule.Regex {
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidRegex, err)
+		}
+	}
+
+	return nil
+}
+
+// Apply marks events hidden or strips their contact according to the rules.
+// Events are modified in place.
+func Apply(rules []*database.Rule, events []*database.Event) {
+	for _, event := range events {
+		for _, rule := range rules {
+			if !Matches(rule, event) {
+				continue
+			}
+
+			switch rule.Action {
+			case database.RuleActionHide:
+				event.Hidden = true
+			case database.RuleActionStripContact:
+				event.Description = StripContact(event.Description)
+			}
+		}
+	}
+}
+
+func Matches(rule *database.Rule, event *database.Event) bool {
+	if strings.TrimSpace(rule.Pattern) == "" {
+		return true
+	}
+
+	for _, value := range fieldValues(rule.Field, event) {
+		if value == "" {
+			continue
+		}
+		if rule.Regex {
+			if re := compile(rule.Pattern); re != nil && re.MatchString(value) {
+				return true
+			}
+			continue
+		}
+		for _, keyword := range Keywords(rule.Pattern) {
+			if strings.Contains(strings.ToLower(value), keyword) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Keywords splits a keyword list on commas and newlines.
+func Keywords(pattern string) []string {
+	var keywords []string
+	for _, keyword := range strings.FieldsFunc(pattern, func(r rune) bool { return r == ',' || r == '\n' }) {
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		if keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
+}
+
+// StripContact removes the "Kontakt: …" line the Zetkin scraper appends to
+// descriptions.
+func StripContact(description sql.NullString) sql.NullString {
+	if !description.Valid {
+		return description
+	}
+
+	stripped := strings.TrimSpace(contactPattern.ReplaceAllString(description.String, ""))
+	return sql.NullString{String: stripped, Valid: stripped != ""}
+}
+
+func fieldValues(field string, event *database.Event) []string {
+	switch field {
+	case database.RuleFieldTitle:
+		return []string{event.Title}
+	case database.RuleFieldActivity:
+		return []string{event.Activity.String}
+	case database.RuleFieldLocation:
+		return []string{event.Location.String}
+	default:
+		return []string{event.Title, event.Activity.String, event.Location.String, event.Description.String}
+	}
+}
+
+func compile(pattern string) *regexp.Regexp {
+	if re, ok := compiled.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil
+	}
+	compiled.Store(pattern, re)
+	return re
+}
@@ -0,0 +1,131 @@
+package rules
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/romanzipp/linke-calendar/internal/database"
+)
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func TestStripContact(t *testing.T) {
+	tests := []struct {
+		name        string
+		description sql.NullString
+		want        sql.NullString
+	}{
+		{"null", sql.NullString{}, sql.NullString{}},
+		{"without contact", nullString("Treffen im Büro"), nullString("Treffen im Büro")},
+		{"with contact", nullString("Treffen im Büro\n\nKontakt: Anna Muster"), nullString("Treffen im Büro")},
+		{"only contact", nullString("Kontakt: Anna Muster"), sql.NullString{}},
+		{"contact in text", nullString("Kontakt: Anna\nAnmeldung per Mail"), nullString("Anmeldung per Mail")},
+		{"not at line start", nullString("Bei Fragen Kontakt: Anna"), nullString("Bei Fragen Kontakt: Anna")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripContact(tt.description); got != tt.want {
+				t.Errorf("StripContact(%q) = %+v, want %+v", tt.description.String, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	newEvent := func() *database.Event {
+		return &database.Event{
+			Title:       "Vorstandssitzung",
+			Activity:    nullString("Sitzung"),
+			Location:    nullString("Geschäftsstelle"),
+			Description: nullString("Intern\n\nKontakt: Anna Muster"),
+		}
+	}
+
+	tests := []struct {
+		name            string
+		rule            database.Rule
+		wantHidden      bool
+		wantDescription string
+	}{
+		{
+			name:            "hide by title keyword",
+			rule:            database.Rule{Field: database.RuleFieldTitle, Pattern: "Infostand, vorstand", Action: database.RuleActionHide},
+			wantHidden:      true,
+			wantDescription: "Intern\n\nKontakt: Anna Muster",
+		},
+		{
+			name:            "keyword of other field",
+			rule:            database.Rule{Field: database.RuleFieldLocation, Pattern: "vorstand", Action: database.RuleActionHide},
+			wantDescription: "Intern\n\nKontakt: Anna Muster",
+		},
+		{
+			name:            "hide by activity regex",
+			rule:            database.Rule{Field: database.RuleFieldActivity, Pattern: "^Sitz", Regex: true, Action: database.RuleActionHide},
+			wantHidden:      true,
+			wantDescription: "Intern\n\nKontakt: Anna Muster",
+		},
+		{
+			name:            "regex is case sensitive",
+			rule:            database.Rule{Field: database.RuleFieldActivity, Pattern: "^sitz", Regex: true, Action: database.RuleActionHide},
+			wantDescription: "Intern\n\nKontakt: Anna Muster",
+		},
+		{
+			name:            "any field matches description",
+			rule:            database.Rule{Field: database.RuleFieldAny, Pattern: "intern", Action: database.RuleActionHide},
+			wantHidden:      true,
+			wantDescription: "Intern\n\nKontakt: Anna Muster",
+		},
+		{
+			name:            "strip contact of all events",
+			rule:            database.Rule{Field: database.RuleFieldAny, Action: database.RuleActionStripContact},
+			wantDescription: "Intern",
+		},
+		{
+			name:            "strip contact of matching events",
+			rule:            database.Rule{Field: database.RuleFieldTitle, Pattern: "infostand", Action: database.RuleActionStripContact},
+			wantDescription: "Intern\n\nKontakt: Anna Muster",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := newEvent()
+			Apply([]*database.Rule{&tt.rule}, []*database.Event{event})
+
+			if event.Hidden != tt.wantHidden {
+				t.Errorf("Hidden = %v, want %v", event.Hidden, tt.wantHidden)
+			}
+			if event.Description.String != tt.wantDescription {
+				t.Errorf("Description = %q, want %q", event.Description.String, tt.wantDescription)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		rule database.Rule
+		want error
+	}{
+		{"keyword rule", database.Rule{Field: database.RuleFieldTitle, Pattern: "a", Action: database.RuleActionHide}, nil},
+		{"strip contact without pattern", database.Rule{Field: database.RuleFieldAny, Action: database.RuleActionStripContact}, nil},
+		{"hide without pattern", database.Rule{Field: database.RuleFieldAny, Pattern: " ", Action: database.RuleActionHide}, ErrMissingPattern},
+		{"unknown field", database.Rule{Field: "url", Pattern: "a", Action: database.RuleActionHide}, ErrUnknownField},
+		{"unknown action", database.Rule{Field: database.RuleFieldTitle, Pattern: "a", Action: "delete"}, ErrUnknownAction},
+		{"invalid regex", database.Rule{Field: database.RuleFieldTitle, Pattern: "(", Regex: true, Action: database.RuleActionHide}, ErrInvalidRegex},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(&tt.rule)
+			if (err == nil) != (tt.want == nil) || (tt.want != nil && !errors.Is(err, tt.want)) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
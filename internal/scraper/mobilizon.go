@@ -0,0 +1,284 @@
+package scraper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/romanzipp/linke-calendar/internal/database"
+)
+
+const mobilizonPageSize = 50
+
+const mobilizonGroupEventsQuery = `
+query GroupEvents($preferredUsername: String!, $afterDatetime: DateTime, $page: Int, $limit: Int) {
+  group(preferredUsername: $preferredUsername) {
+    name
+    organizedEvents(afterDatetime: $afterDatetime, page: $page, limit: $limit) {
+      total
+      elements {
+        uuid
+        title
+        url
+        beginsOn
+        endsOn
+        description
+        status
+        options {
+          timezone
+          showStartTime
+          showEndTime
+        }
+        physicalAddress {
+          description
+          street
+          postalCode
+          locality
+        }
+      }
+    }
+  }
+}`
+
+type MobilizonClient struct {
+	instance string
+	group    string
+	client   *http.Client
+}
+
+type mobilizonRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+type MobilizonResponse struct {
+	Data struct {
+		Group *MobilizonGroup `json:"group"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+type MobilizonGroup struct {
+	Name            string `json:"name"`
+	OrganizedEvents struct {
+		Total    int              `json:"total"`
+		Elements []MobilizonEvent `json:"elements"`
+	} `json:"organizedEvents"`
+}
+
+type MobilizonEvent struct {
+	UUID            string            `json:"uuid"`
+	Title           string            `json:"title"`
+	URL             string            `json:"url"`
+	BeginsOn        string            `json:"beginsOn"`
+	EndsOn          *string           `json:"endsOn"`
+	Description     string            `json:"description"`
+	Status          string            `json:"status"`
+	Options         *MobilizonOptions `json:"options"`
+	PhysicalAddress *MobilizonAddress `json:"physicalAddress"`
+}
+
+type MobilizonOptions struct {
+	Timezone      string `json:"timezone"`
+	ShowStartTime *bool  `json:"showStartTime"`
+	ShowEndTime   *bool  `json:"showEndTime"`
+}
+
+type MobilizonAddress struct {
+	Description string `json:"description"`
+	Street      string `json:"street"`
+	PostalCode  string `json:"postalCode"`
+	Locality    string `json:"locality"`
+}
+
+func NewMobilizonClient(instance, group string, timeout time.Duration) *MobilizonClient {
+	return &MobilizonClient{
+		instance: strings.TrimRight(instance, "/"),
+		group:    group,
+		client: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+// FetchUpcomingEvents pages through the public events organized by the group,
+// starting at the given time. Cancelled events are dropped.
+func (m *MobilizonClient) FetchUpcomingEvents(after time.Time) ([]MobilizonEvent, error) {
+	var events []MobilizonEvent
+
+	for page := 1; ; page++ {
+		group, err := m.fetchPage(after, page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, event := range group.OrganizedEvents.Elements {
+			if event.Status != "CANCELLED" {
+				events = append(events, event)
+			}
+		}
+
+		fetched := (page-1)*mobilizonPageSize + len(group.OrganizedEvents.Elements)
+		if len(group.OrganizedEvents.Elements) < mobilizonPageSize || fetched >= group.OrganizedEvents.Total {
+			break
+		}
+	}
+
+	return events, nil
+}
+
+func (m *MobilizonClient) fetchPage(after time.Time, page int) (*MobilizonGroup, error) {
+	payload, err := json.Marshal(mobilizonRequest{
+		Query: mobilizonGroupEventsQuery,
+		Variables: map[string]interface{}{
+			"preferredUsername": m.group,
+			"afterDatetime":     after.UTC().Format(time.RFC3339),
+			"page":              page,
+			"limit":             mobilizonPageSize,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode query: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", m.instance+"/api", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		preview := string(body)
+		if len(preview) > 500 {
+			preview = preview[:500] + "..."
+		}
+		log.Printf("Mobilizon API error response: %s", preview)
+		return nil, fmt.Errorf("HTTP status %d", resp.StatusCode)
+	}
+
+	var mobilizonResp MobilizonResponse
+	if err := json.Unmarshal(body, &mobilizonResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	if len(mobilizonResp.Errors) > 0 {
+		return nil, fmt.Errorf("GraphQL error: %s", mobilizonResp.Errors[0].Message)
+	}
+
+	if mobilizonResp.Data.Group == nil {
+		return nil, fmt.Errorf("group %q not found on %s", m.group, m.instance)
+	}
+
+	return mobilizonResp.Data.Group, nil
+}
+
+// WallClock converts a Mobilizon UTC timestamp into the event's local wall
+// clock time, stored as UTC like the Zetkin times.
+func (e MobilizonEvent) WallClock(timestamp string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	loc := database.Berlin
+	if e.Options != nil && e.Options.Timezone != "" {
+		if l, err := time.LoadLocation(e.Options.Timezone); err == nil {
+			loc = l
+		}
+	}
+
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), 0, time.UTC), nil
+}
+
+// SourceID identifies the event by its UUID and the host of the instance it
+// was published on.
+func (e MobilizonEvent) SourceID(instance string) string {
+	host := ""
+	if u, err := url.Parse(e.URL); err == nil {
+		host = u.Host
+	}
+	if host == "" {
+		if u, err := url.Parse(instance); err == nil {
+			host = u.Host
+		}
+	}
+	return database.MobilizonSourceID(e.UUID, host)
+}
+
+func (e MobilizonEvent) HidesTimes() bool {
+	if e.Options == nil || e.Options.ShowStartTime == nil {
+		return false
+	}
+	return !*e.Options.ShowStartTime
+}
+
+func (e MobilizonEvent) LocationText() string {
+	if e.PhysicalAddress == nil {
+		return ""
+	}
+
+	a := e.PhysicalAddress
+	city := strings.TrimSpace(a.PostalCode + " " + a.Locality)
+
+	var parts []string
+	for _, part := range []string{a.Description, a.Street, city} {
+		if part != "" && !containsFold(parts, part) {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+var (
+	htmlBreakPattern = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</li>|</h[1-6]>`)
+	htmlTagPattern   = regexp.MustCompile(`<[^>]*>`)
+	blankLinePattern = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText reduces the rich text descriptions of Mobilizon to plain text.
+func htmlToText(s string) string {
+	s = htmlBreakPattern.ReplaceAllString(s, "\n")
+	s = htmlTagPattern.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	s = strings.Join(lines, "\n")
+
+	return strings.TrimSpace(blankLinePattern.ReplaceAllString(s, "\n\n"))
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
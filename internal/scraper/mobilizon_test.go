@@ -0,0 +1,170 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// mobilizonStub answers group event queries with total events, of which
+// every tenth is cancelled.
+func mobilizonStub(t *testing.T, group string, total int) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api" {
+			http.NotFound(w, r)
+			return
+		}
+
+		var req mobilizonRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		if req.Variables["preferredUsername"] != group {
+			fmt.Fprint(w, `{"data":{"group":null}}`)
+			return
+		}
+		if _, err := time.Parse(time.RFC3339, req.Variables["afterDatetime"].(string)); err != nil {
+			t.Errorf("afterDatetime is not RFC 3339: %v", err)
+		}
+
+		page := int(req.Variables["page"].(float64))
+		limit := int(req.Variables["limit"].(float64))
+
+		var elements []MobilizonEvent
+		for i := (page - 1) * limit; i < total && i < page*limit; i++ {
+			status := "CONFIRMED"
+			if i%10 == 9 {
+				status = "CANCELLED"
+			}
+			elements = append(elements, MobilizonEvent{
+				UUID:     fmt.Sprintf("uuid-%d", i),
+				Title:    fmt.Sprintf("Event %d", i),
+				BeginsOn: "2025-06-01T16:00:00Z",
+				Status:   status,
+			})
+		}
+
+		var resp MobilizonResponse
+		resp.Data.Group = &MobilizonGroup{Name: group}
+		resp.Data.Group.OrganizedEvents.Total = total
+		resp.Data.Group.OrganizedEvents.Elements = elements
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func TestFetchUpcomingEvents(t *testing.T) {
+	server := mobilizonStub(t, "linke_fulda", 60)
+	defer server.Close()
+
+	client := NewMobilizonClient(server.URL+"/", "linke_fulda", 5*time.Second)
+	events, err := client.FetchUpcomingEvents(time.Now())
+	if err != nil {
+		t.Fatalf("FetchUpcomingEvents: %v", err)
+	}
+
+	// Two pages of 50 and 10 events, without the 6 cancelled ones.
+	if len(events) != 54 {
+		t.Fatalf("got %d events, want 54", len(events))
+	}
+	for _, event := range events {
+		if event.Status == "CANCELLED" {
+			t.Errorf("cancelled event %s was returned", event.UUID)
+		}
+	}
+	if events[len(events)-1].UUID != "uuid-58" {
+		t.Errorf("last event is %s, want uuid-58", events[len(events)-1].UUID)
+	}
+}
+
+func TestFetchUpcomingEventsErrors(t *testing.T) {
+	server := mobilizonStub(t, "linke_fulda", 1)
+	defer server.Close()
+
+	if _, err := NewMobilizonClient(server.URL, "unknown", 5*time.Second).FetchUpcomingEvents(time.Now()); err == nil {
+		t.Error("expected an error for an unknown group")
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"errors":[{"message":"boom"}]}`)
+	}))
+	defer failing.Close()
+
+	if _, err := NewMobilizonClient(failing.URL, "linke_fulda", 5*time.Second).FetchUpcomingEvents(time.Now()); err == nil {
+		t.Error("expected an error for a GraphQL error response")
+	}
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	if _, err := NewMobilizonClient(down.URL, "linke_fulda", 5*time.Second).FetchUpcomingEvents(time.Now()); err == nil {
+		t.Error("expected an error for HTTP status 503")
+	}
+}
+
+func TestMobilizonWallClock(t *testing.T) {
+	tests := []struct {
+		name      string
+		timezone  string
+		timestamp string
+		want      string
+	}{
+		{"default summer", "", "2025-06-01T16:00:00Z", "2025-06-01T18:00:00Z"},
+		{"default winter", "", "2025-01-15T18:30:00Z", "2025-01-15T19:30:00Z"},
+		{"event timezone", "Europe/London", "2025-06-01T16:00:00Z", "2025-06-01T17:00:00Z"},
+		{"unknown timezone", "Mars/Olympus", "2025-06-01T16:00:00Z", "2025-06-01T18:00:00Z"},
+		{"offset", "", "2025-06-01T18:00:00+02:00", "2025-06-01T18:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := MobilizonEvent{}
+			if tt.timezone != "" {
+				event.Options = &MobilizonOptions{Timezone: tt.timezone}
+			}
+
+			got, err := event.WallClock(tt.timestamp)
+			if err != nil {
+				t.Fatalf("WallClock: %v", err)
+			}
+			if got.Format(time.RFC3339) != tt.want {
+				t.Errorf("got %s, want %s", got.Format(time.RFC3339), tt.want)
+			}
+		})
+	}
+
+	if _, err := (MobilizonEvent{}).WallClock("tomorrow"); err == nil {
+		t.Error("expected an error for an invalid timestamp")
+	}
+}
+
+func TestMobilizonHidesTimes(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name    string
+		options *MobilizonOptions
+		want    bool
+	}{
+		{"no options", nil, false},
+		{"not set", &MobilizonOptions{}, false},
+		{"start time shown", &MobilizonOptions{ShowStartTime: &yes}, false},
+		{"start time hidden", &MobilizonOptions{ShowStartTime: &no}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (MobilizonEvent{Options: tt.options}).HidesTimes(); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
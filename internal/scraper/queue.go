@@ -0,0 +1,80 @@
+package scraper
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/romanzipp/linke-calendar/internal/database"
+)
+
+// queueSize is the number of runs that may wait to be started.
+const queueSize = 32
+
+var ErrQueueFull = errors.New("scrape queue is full")
+
+// Enqueue requests a scrape of an organization, which is run in the
+// background after the runs before it. An organization that already waits
+// to be scraped gets its queued run back instead of a second one.
+func (s *Scraper) Enqueue(orgID int, tokenID sql.NullInt64) (*database.ScrapeRun, error) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	run, err := s.db.GetQueuedScrapeRun(orgID)
+	if err == nil {
+		return run, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	if len(s.queue) == cap(s.queue) {
+		return nil, ErrQueueFull
+	}
+
+	run = &database.ScrapeRun{
+		OrganizationID: orgID,
+		TokenID:        tokenID,
+		Status:         database.RunStatusQueued,
+		QueuedAt:       time.Now().UTC(),
+	}
+	if err := s.db.CreateScrapeRun(run); err != nil {
+		return nil, err
+	}
+
+	// The worker updates its own copy, the caller may still read run.
+	queued := *run
+	s.queue <- &queued
+	return run, nil
+}
+
+// startQueue fails the runs left over from before a restart and works off
+// new ones in the background.
+func (s *Scraper) startQueue() {
+	if err := s.db.AbortScrapeRuns("interrupted by restart", time.Now().UTC()); err != nil {
+		log.Printf("Failed to abort old scrape runs: %v", err)
+	}
+
+	go s.runQueue()
+}
+
+func (s *Scraper) runQueue() {
+	for run := range s.queue {
+		log.Printf("Starting queued scrape run %d of organization %d", run.ID, run.OrganizationID)
+
+		if err := s.db.StartScrapeRun(run.ID, time.Now().UTC()); err != nil {
+			log.Printf("Failed to start scrape run %d: %v", run.ID, err)
+		}
+
+		errs, err := s.scrapeOrganization(run.OrganizationID)
+		if err != nil {
+			log.Printf("Error scraping organization %d: %v", run.OrganizationID, err)
+			errs = append(errs, err.Error())
+		}
+
+		if err := s.db.FinishScrapeRun(run, errs, time.Now().UTC()); err != nil {
+			log.Printf("Failed to finish scrape run %d: %v", run.ID, err)
+		}
+	}
+}
@@ -28,6 +28,8 @@ func (s *Scheduler) Start() error {
 
 	log.Printf("Starting scraper scheduler with interval: %v", interval)
 
+	s.scraper.startQueue()
+
 	go func() {
 		log.Println("Running initial scrape")
 		if err := s.scraper.ScrapeAll(); err != nil {
@@ -2,8 +2,10 @@ package scraper
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/romanzipp/linke-calendar/internal/config"
@@ -11,14 +13,23 @@ import (
 )
 
 type Scraper struct {
-	db     *database.DB
-	config *config.Config
+	db        *database.DB
+	config    *config.Config
+	onScraped []func(orgID int)
+
+	queueMu sync.Mutex
+	queue   chan *database.ScrapeRun
+
+	// orgLocks holds a *sync.Mutex per organization, so the queue worker,
+	// the scheduler and synchronous scrapes never scrape one concurrently.
+	orgLocks sync.Map
 }
 
 func New(db *database.DB, cfg *config.Config) *Scraper {
 	return &Scraper{
 		db:     db,
 		config: cfg,
+		queue:  make(chan *database.ScrapeRun, queueSize),
 	}
 }
 
@@ -30,7 +41,20 @@ func (s *Scraper) ScrapeAll() error {
 		return fmt.Errorf("failed to get organizations: %w", err)
 	}
 
+	orgs, err := s.db.GetAllOrganizations()
+	if err != nil {
+		return fmt.Errorf("failed to get organizations: %w", err)
+	}
+	paused := make(map[int]bool)
+	for _, org := range orgs {
+		paused[org.ID] = org.Paused
+	}
+
 	for _, orgID := range orgIDs {
+		if paused[orgID] {
+			log.Printf("Skipping paused organization %d", orgID)
+			continue
+		}
 		if err := s.ScrapeOrganization(orgID); err != nil {
 			log.Printf("Error scraping organization %d: %v", orgID, err)
 			continue
@@ -42,26 +66,75 @@ func (s *Scraper) ScrapeAll() error {
 }
 
 func (s *Scraper) ScrapeOrganization(orgID int) error {
+	_, err := s.scrapeOrganization(orgID)
+	return err
+}
+
+// scrapeOrganization returns the errors of single sources and an error if
+// the Zetkin events could not be fetched or the organization could not be
+// stored. Both are recorded for the organization. Without the Zetkin events
+// the organization keeps its title and last scrape, and the OnScraped
+// functions are not called.
+func (s *Scraper) scrapeOrganization(orgID int) ([]string, error) {
+	lock, _ := s.orgLocks.LoadOrStore(orgID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
 	log.Printf("Scraping organization: %d", orgID)
 
-	totalEvents, orgTitle := s.scrapeZetkin(orgID)
+	var errs []string
+
+	totalEvents, orgTitle, zetkinErr := s.scrapeZetkin(orgID)
+
+	mobilizonEvents, mobilizonErrs := s.scrapeMobilizon(orgID)
+	totalEvents += mobilizonEvents
+	for _, err := range mobilizonErrs {
+		errs = append(errs, err.Error())
+	}
+
+	if zetkinErr != nil {
+		if recordErr := s.db.RecordScrapeResult(orgID, append(errs, zetkinErr.Error())); recordErr != nil {
+			log.Printf("Failed to record scrape result for organization %d: %v", orgID, recordErr)
+		}
+		return errs, zetkinErr
+	}
 
 	if err := s.db.UpsertOrganization(&database.Organization{
 		ID:    orgID,
 		Title: toNullString(orgTitle),
 	}); err != nil {
-		return fmt.Errorf("failed to upsert organization: %w", err)
+		err = fmt.Errorf("failed to upsert organization: %w", err)
+		if recordErr := s.db.RecordScrapeResult(orgID, append(errs, err.Error())); recordErr != nil {
+			log.Printf("Failed to record scrape result for organization %d: %v", orgID, recordErr)
+		}
+		return errs, err
 	}
 
 	if err := s.db.UpdateOrganizationLastScraped(orgID, time.Now()); err != nil {
 		log.Printf("Failed to update last_scraped for organization %d: %v", orgID, err)
 	}
 
+	if err := s.db.RecordScrapeResult(orgID, errs); err != nil {
+		log.Printf("Failed to record scrape result for organization %d: %v", orgID, err)
+	}
+
 	log.Printf("Scraped %d total events from organization %d", totalEvents, orgID)
-	return nil
+
+	for _, fn := range s.onScraped {
+		fn(orgID)
+	}
+	return errs, nil
+}
+
+// OnScraped registers a function called after each successful scrape of an
+// organization. Register before the scheduler is started.
+func (s *Scraper) OnScraped(fn func(orgID int)) {
+	s.onScraped = append(s.onScraped, fn)
 }
 
-func (s *Scraper) scrapeZetkin(orgID int) (int, string) {
+// scrapeZetkin returns the number of stored events, the organization title
+// and the error if the events could not be fetched.
+func (s *Scraper) scrapeZetkin(orgID int) (int, string, error) {
 	log.Printf("Fetching Zetkin events for organization ID: %d", orgID)
 
 	client := NewZetkinClient(orgID, s.config.GetScraperTimeout())
@@ -69,7 +142,7 @@ func (s *Scraper) scrapeZetkin(orgID int) (int, string) {
 	events, err := client.FetchAllEvents()
 	if err != nil {
 		log.Printf("Failed to fetch Zetkin events: %v", err)
-		return 0, ""
+		return 0, "", fmt.Errorf("zetkin: %w", err)
 	}
 
 	log.Printf("Fetched %d events from Zetkin for organization %d", len(events), orgID)
@@ -80,7 +153,11 @@ func (s *Scraper) scrapeZetkin(orgID int) (int, string) {
 	}
 
 	totalEvents := 0
+	sourceIDs := make([]string, 0, len(events))
 	for _, event := range events {
+		sourceID := database.ZetkinSourceID(event.ID)
+		sourceIDs = append(sourceIDs, sourceID)
+
 		startTime, err := parseZetkinTime(event.StartTime)
 		if err != nil {
 			log.Printf("Failed to parse start time for event %s: %v", event.Title, err)
@@ -94,8 +171,23 @@ func (s *Scraper) scrapeZetkin(orgID int) (int, string) {
 		}
 
 		location := ""
+		var latitude, longitude sql.NullFloat64
 		if event.Location != nil {
 			location = event.Location.Title
+			if event.Location.Lat != 0 || event.Location.Lng != 0 {
+				latitude = sql.NullFloat64{Float64: event.Location.Lat, Valid: true}
+				longitude = sql.NullFloat64{Float64: event.Location.Lng, Valid: true}
+			}
+		}
+
+		activity := ""
+		if event.Activity != nil {
+			activity = event.Activity.Title
+		}
+
+		campaign := ""
+		if event.Campaign != nil {
+			campaign = event.Campaign.Title
 		}
 
 		description := event.InfoText
@@ -119,18 +211,139 @@ func (s *Scraper) scrapeZetkin(orgID int) (int, string) {
 			DatetimeEnd:    sql.NullTime{Time: endTime, Valid: true},
 			URL:            eventURL,
 			Location:       toNullString(location),
-			Scraper:        "zetkin",
+			Activity:       toNullString(activity),
+			Campaign:       toNullString(campaign),
+			Scraper:        database.ScraperZetkin,
+			AllDay:         isAllDay(startTime, endTime),
+			Latitude:       latitude,
+			Longitude:      longitude,
+			SourceID:       toNullString(sourceID),
 		}
 
-		if err := s.db.UpsertEvent(dbEvent); err != nil {
+		if err := s.db.UpsertEvent(dbEvent); errors.Is(err, database.ErrDuplicateURL) {
+			// Nothing is stored under this source ID, so it is not counted
+			// and not kept by reconcile.
+			log.Printf("Skipping Zetkin event %s, another event has its link %s", event.Title, dbEvent.URL)
+			sourceIDs = sourceIDs[:len(sourceIDs)-1]
+			continue
+		} else if err != nil {
 			log.Printf("Failed to upsert Zetkin event %s: %v", event.Title, err)
 			continue
 		}
 		totalEvents++
 	}
 
+	s.reconcile(orgID, database.ScraperZetkin, sourceIDs)
+
 	log.Printf("Scraped %d events from Zetkin for organization %d", totalEvents, orgID)
-	return totalEvents, orgTitle
+	return totalEvents, orgTitle, nil
+}
+
+func (s *Scraper) scrapeMobilizon(orgID int) (int, []error) {
+	totalEvents := 0
+	var errs []error
+	var sourceIDs []string
+
+	for _, source := range s.config.GetMobilizonSources(orgID) {
+		log.Printf("Fetching Mobilizon events of %s from %s for organization ID: %d", source.Group, source.Instance, orgID)
+
+		client := NewMobilizonClient(source.Instance, source.Group, s.config.GetScraperTimeout())
+
+		events, err := client.FetchUpcomingEvents(time.Now().AddDate(0, 0, -1))
+		if err != nil {
+			log.Printf("Failed to fetch Mobilizon events of %s: %v", source.Group, err)
+			errs = append(errs, fmt.Errorf("mobilizon %s (%s): %w", source.Group, source.Instance, err))
+			continue
+		}
+
+		stored := 0
+		for _, event := range events {
+			sourceID := event.SourceID(source.Instance)
+			sourceIDs = append(sourceIDs, sourceID)
+
+			startTime, err := event.WallClock(event.BeginsOn)
+			if err != nil {
+				log.Printf("Failed to parse start time for event %s: %v", event.Title, err)
+				continue
+			}
+
+			endTime := sql.NullTime{}
+			if event.EndsOn != nil && *event.EndsOn != "" {
+				t, err := event.WallClock(*event.EndsOn)
+				if err != nil {
+					log.Printf("Failed to parse end time for event %s: %v", event.Title, err)
+					continue
+				}
+				endTime = sql.NullTime{Time: t, Valid: true}
+			}
+
+			allDay := event.HidesTimes()
+			if endTime.Valid && isAllDay(startTime, endTime.Time) {
+				allDay = true
+			}
+
+			dbEvent := &database.Event{
+				OrganizationID: orgID,
+				Title:          event.Title,
+				Description:    toNullString(htmlToText(event.Description)),
+				DatetimeStart:  startTime,
+				DatetimeEnd:    endTime,
+				URL:            event.URL,
+				Location:       toNullString(event.LocationText()),
+				Scraper:        "mobilizon",
+				AllDay:         allDay,
+				SourceID:       toNullString(sourceID),
+			}
+
+			if err := s.db.UpsertEvent(dbEvent); errors.Is(err, database.ErrDuplicateURL) {
+				// Nothing is stored under this source ID, so it is not counted
+				// and not kept by reconcile.
+				log.Printf("Skipping Mobilizon event %s, another event has its link %s", event.Title, dbEvent.URL)
+				sourceIDs = sourceIDs[:len(sourceIDs)-1]
+				continue
+			} else if err != nil {
+				log.Printf("Failed to upsert Mobilizon event %s: %v", event.Title, err)
+				continue
+			}
+			stored++
+		}
+		totalEvents += stored
+
+		log.Printf("Scraped %d events from Mobilizon group %s for organization %d", stored, source.Group, orgID)
+	}
+
+	// Events of a failed source would look deleted, so only reconcile after
+	// every source was fetched.
+	if len(errs) == 0 {
+		s.reconcile(orgID, "mobilizon", sourceIDs)
+	}
+
+	return totalEvents, errs
+}
+
+// reconcile deletes upcoming events of a scraper that the source no longer
+// returns, e.g. because they were cancelled.
+func (s *Scraper) reconcile(orgID int, scraper string, sourceIDs []string) {
+	deleted, err := s.db.DeleteStaleEvents(orgID, scraper, sourceIDs, database.WallClock(time.Now()))
+	if err != nil {
+		log.Printf("Failed to reconcile %s events for organization %d: %v", scraper, orgID, err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("Deleted %d stale %s events from organization %d", deleted, scraper, orgID)
+	}
+}
+
+// isAllDay detects events that span whole days, as sources without an
+// explicit all-day flag encode them as 00:00 to 23:59 or 00:00 to 00:00.
+func isAllDay(start, end time.Time) bool {
+	if start.Hour() != 0 || start.Minute() != 0 || !end.After(start) {
+		return false
+	}
+	if end.Hour() == 23 && end.Minute() == 59 {
+		return true
+	}
+	return end.Hour() == 0 && end.Minute() == 0
 }
 
 func toNullString(s string) sql.NullString {
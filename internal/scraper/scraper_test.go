@@ -0,0 +1,35 @@
+package scraper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsAllDay(t *testing.T) {
+	day := func(d, h, m int) time.Time {
+		return time.Date(2025, 3, d, h, m, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		want       bool
+	}{
+		{"midnight to 23:59", day(1, 0, 0), day(1, 23, 59), true},
+		{"midnight to next midnight", day(1, 0, 0), day(2, 0, 0), true},
+		{"several days", day(1, 0, 0), day(3, 23, 59), true},
+		{"evening event", day(1, 19, 0), day(1, 21, 0), false},
+		{"starts at midnight, ends at noon", day(1, 0, 0), day(1, 12, 0), false},
+		{"starts at 00:30", day(1, 0, 30), day(1, 23, 59), false},
+		{"no duration", day(1, 0, 0), day(1, 0, 0), false},
+		{"ends before start", day(2, 0, 0), day(1, 0, 0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isAllDay(tt.start, tt.end); got != tt.want {
+				t.Errorf("isAllDay(%s, %s) = %v, want %v", tt.start, tt.end, got, tt.want)
+			}
+		})
+	}
+}
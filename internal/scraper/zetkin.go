@@ -28,6 +28,7 @@ type ZetkinEvent struct {
 	InfoText     string             `json:"info_text"`
 	URL          string             `json:"url"`
 	Activity     *ZetkinActivity    `json:"activity"`
+	Campaign     *ZetkinCampaign    `json:"campaign"`
 	Location     *ZetkinLocation    `json:"location"`
 	Contact      *ZetkinContact     `json:"contact"`
 	Organization ZetkinOrganization `json:"organization"`
@@ -39,6 +40,11 @@ type ZetkinActivity struct {
 	Title string `json:"title"`
 }
 
+type ZetkinCampaign struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
 type ZetkinLocation struct {
 	ID    int     `json:"id"`
 	Lat   float64 `json:"lat"`
@@ -9,6 +9,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/romanzipp/linke-calendar/internal/auth"
 	"github.com/romanzipp/linke-calendar/internal/config"
 	"github.com/romanzipp/linke-calendar/internal/database"
 	"github.com/romanzipp/linke-calendar/internal/handlers"
@@ -43,16 +44,24 @@ func main() {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
-	scheduler := scraper.NewScheduler(db, cfg)
-	if err := scheduler.Start(); err != nil {
-		log.Fatalf("Failed to start scraper scheduler: %v", err)
+	if cfg.AdminEnabled() {
+		if err := auth.EnsureOperator(db, cfg.GetAdminUsername(), cfg.Admin.Password); err != nil {
+			log.Fatalf("Failed to set up admin account: %v", err)
+		}
 	}
 
-	h, err := handlers.New(db, scheduler.GetScraper(), Version)
+	scheduler := scraper.NewScheduler(db, cfg)
+
+	h, err := handlers.New(db, scheduler.GetScraper(), cfg, Version)
 	if err != nil {
 		log.Fatalf("Failed to create handlers: %v", err)
 	}
 
+	scheduler.GetScraper().OnScraped(h.InvalidateOrganization)
+	if err := scheduler.Start(); err != nil {
+		log.Fatalf("Failed to start scraper scheduler: %v", err)
+	}
+
 	r := chi.NewRouter()
 
 	r.Use(middleware.Logger)
@@ -66,10 +75,100 @@ func main() {
 	})
 
 	r.Get("/health", h.Health)
+	r.Get("/oembed", h.OEmbed)
 	r.Get("/org/{org}/calendar", h.Calendar)
+	r.Get("/org/{org}/year", h.Year)
 	r.Get("/org/{org}/list", h.List)
+	r.Get("/org/{org}/search", h.Search)
 	r.Get("/org/{org}/ical", h.ICalendar)
+	r.Get("/org/{org}/feed.rss", h.FeedRSS)
+	r.Get("/org/{org}/feed.atom", h.FeedAtom)
+	r.Get("/org/{org}/feed.json", h.FeedJSON)
+	r.Get("/org/{org}/submit", h.Submit)
+	r.Post("/org/{org}/submit", h.SubmitPost)
 	r.Get("/event/{eventID}", h.EventDetail)
+	r.Get("/event/{eventID}/ics", h.EventICS)
+
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Use(h.CORS)
+
+		r.Get("/openapi.yaml", h.APIOpenAPI)
+		r.Get("/orgs/{org}", h.APIOrganization)
+		r.Get("/orgs/{org}/events", h.APIEvents)
+		r.Get("/orgs/{org}/search", h.APISearch)
+		r.Get("/events/{eventID}", h.APIEvent)
+		r.Post("/orgs/{org}/refresh", h.APIRefresh)
+		r.Get("/orgs/{org}/runs/{runID}", h.APIScrapeRun)
+	})
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Get("/login", h.AdminLogin)
+		r.Post("/login", h.AdminLoginPost)
+		r.Get("/invite", h.AdminInvite)
+		r.Get("/invite/{code}", h.AdminInvite)
+		r.Post("/invite/{code}", h.AdminAcceptInvite)
+
+		r.Group(func(r chi.Router) {
+			r.Use(h.RequireAdmin)
+
+			r.Get("/", h.AdminDashboard)
+			r.Post("/logout", h.AdminLogout)
+			r.Get("/account", h.AdminAccount)
+			r.Post("/account", h.AdminUpdateAccount)
+			r.Get("/event/{eventID}", h.AdminEditEvent)
+			r.Post("/event/{eventID}", h.AdminUpdateEvent)
+			r.Post("/event/{eventID}/delete", h.AdminDeleteEvent)
+			r.Get("/event/{eventID}/override", h.AdminEditOverride)
+			r.Post("/event/{eventID}/override", h.AdminUpdateOverride)
+			r.Post("/event/{eventID}/override/delete", h.AdminDeleteOverride)
+			r.Get("/submission/{submissionID}", h.AdminSubmission)
+			r.Post("/submission/{submissionID}", h.AdminModerateSubmission)
+			r.Put("/api/event/{eventID}", h.AdminAPIUpdateEvent)
+			r.Delete("/api/event/{eventID}", h.AdminAPIDeleteEvent)
+
+			r.Group(func(r chi.Router) {
+				r.Use(h.RequireRole(database.RoleEditor))
+
+				r.Get("/org/{org}/events", h.AdminEvents)
+				r.Post("/org/{org}/events", h.AdminCreateEvent)
+				r.Get("/org/{org}/submissions", h.AdminSubmissions)
+				r.Get("/api/org/{org}/events", h.AdminAPIListEvents)
+				r.Post("/api/org/{org}/events", h.AdminAPICreateEvent)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(h.RequireRole(database.RoleOrgAdmin))
+
+				r.Post("/org/{org}/scrape", h.AdminScrapeOrganization)
+				r.Get("/org/{org}/rules", h.AdminRules)
+				r.Post("/org/{org}/rules", h.AdminCreateRule)
+				r.Post("/org/{org}/rules/{ruleID}/delete", h.AdminDeleteRule)
+				r.Get("/org/{org}/theme", h.AdminTheme)
+				r.Post("/org/{org}/theme", h.AdminUpdateTheme)
+				r.Get("/org/{org}/members", h.AdminMembers)
+				r.Post("/org/{org}/members/{userID}", h.AdminUpdateMember)
+				r.Post("/org/{org}/members/{userID}/delete", h.AdminRemoveMember)
+				r.Post("/org/{org}/invites", h.AdminCreateInvite)
+				r.Post("/org/{org}/invites/{inviteID}/delete", h.AdminRevokeInvite)
+				r.Get("/org/{org}/tokens", h.AdminTokens)
+				r.Post("/org/{org}/tokens", h.AdminCreateToken)
+				r.Post("/org/{org}/tokens/{tokenID}/delete", h.AdminRevokeToken)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(h.RequireRole(database.RoleOperator))
+
+				r.Post("/org/{org}/pause", h.AdminPauseOrganization)
+				r.Post("/org/{org}/resume", h.AdminResumeOrganization)
+				r.Post("/org/{org}/delete", h.AdminDeleteOrganization)
+				r.Get("/users", h.AdminUsers)
+				r.Post("/users/invites", h.AdminCreateOperatorInvite)
+				r.Post("/users/invites/{inviteID}/delete", h.AdminRevokeOperatorInvite)
+				r.Post("/users/{userID}/delete", h.AdminDeleteUser)
+				r.Get("/api/cache", h.AdminCacheStats)
+			})
+		})
+	})
 
 	fileServer := http.FileServer(http.Dir("web/static"))
 	r.Handle("/static/*", http.StripPrefix("/static/", fileServer))
@@ -0,0 +1,83 @@
+// Embeds the calendar or event list of an organization as an iframe that
+// grows with its content:
+//
+//   <div data-linke-calendar data-org="123" data-view="list"></div>
+//   <script src="https://linke-calendar.romanzipp.com/static/js/embed.js" async></script>
+//
+// data-view is one of month (default), week, day, agenda, year, list or
+// search. The other data attributes are passed on as query parameters.
+(function () {
+    const origin = new URL(document.currentScript.src).origin;
+    const params = ["date", "lang", "primary", "text", "font", "density", "mode", "color", "activity", "campaign", "q"];
+    const frames = [];
+
+    function source(element) {
+        const org = element.dataset.org;
+        const view = element.dataset.view || "month";
+        const query = new URLSearchParams();
+        let path = "calendar";
+
+        if (view === "year" || view === "list" || view === "search") {
+            path = view;
+        } else if (view !== "month") {
+            query.set("view", view);
+        }
+
+        params.forEach(function (name) {
+            const value = element.dataset[name];
+            if (value) {
+                query.set(name, value.replace(/^#/, ""));
+            }
+        });
+
+        const search = query.toString();
+        return origin + "/org/" + encodeURIComponent(org) + "/" + path + (search ? "?" + search : "");
+    }
+
+    function embed(element) {
+        if (element.dataset.linkeCalendarEmbedded) {
+            return;
+        }
+        if (!/^\d+$/.test(element.dataset.org || "")) {
+            console.error("linke-calendar: data-org must be the organization ID", element);
+            return;
+        }
+        element.dataset.linkeCalendarEmbedded = "true";
+
+        const frame = document.createElement("iframe");
+        frame.src = source(element);
+        frame.title = element.dataset.title || "Termine";
+        frame.scrolling = "no";
+        frame.style.width = "100%";
+        frame.style.height = (parseInt(element.dataset.height, 10) || 600) + "px";
+        frame.style.border = "0";
+        frame.style.display = "block";
+        element.appendChild(frame);
+        frames.push(frame);
+    }
+
+    function embedAll() {
+        document.querySelectorAll("[data-linke-calendar]").forEach(embed);
+    }
+
+    window.addEventListener("message", function (event) {
+        if (event.origin !== origin || !event.data || event.data.type !== "linke-calendar:resize") {
+            return;
+        }
+        const height = parseInt(event.data.height, 10);
+        if (!(height > 0)) {
+            return;
+        }
+        frames.forEach(function (frame) {
+            if (frame.contentWindow === event.source) {
+                frame.style.height = height + "px";
+            }
+        });
+    });
+
+    if (document.readyState === "loading") {
+        document.addEventListener("DOMContentLoaded", embedAll);
+    } else {
+        embedAll();
+    }
+})();
@@ -0,0 +1,29 @@
+// Reports the content height of an embedded page to the embedding page, so
+// embed.js can size the iframe without scrollbars or gaps.
+(function () {
+    if (window.parent === window) {
+        return;
+    }
+
+    let lastHeight = 0;
+
+    function postHeight() {
+        const height = Math.ceil(document.documentElement.getBoundingClientRect().height);
+        if (height === lastHeight) {
+            return;
+        }
+        lastHeight = height;
+        window.parent.postMessage({ type: "linke-calendar:resize", height: height }, "*");
+    }
+
+    window.addEventListener("load", postHeight);
+    document.addEventListener("htmx:afterSettle", postHeight);
+
+    if ("ResizeObserver" in window) {
+        new ResizeObserver(postHeight).observe(document.documentElement);
+    } else {
+        window.addEventListener("resize", postHeight);
+    }
+
+    postHeight();
+})();